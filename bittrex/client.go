@@ -0,0 +1,69 @@
+package bittrex
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+const LIVE_URL = "https://bittrex.com"
+const LIVE_CREDENTIALS_PATH = "./.bittrex-creds.json"
+
+// Bittrex reports dates in UTC without a time zone.
+const DATE_FORMAT = "2006-01-02T15:04:05"
+
+type Client struct {
+	BaseUrl         string
+	CredentialsPath string
+}
+
+func NewClient(baseUrl, credentialsPath string) *Client {
+	return &Client{
+		BaseUrl:         baseUrl,
+		CredentialsPath: credentialsPath,
+	}
+}
+
+func NewLiveClient() *Client {
+	return NewClient(LIVE_URL, LIVE_CREDENTIALS_PATH)
+}
+
+func (client *Client) ApiUrl() string {
+	return client.BaseUrl + "/api/v1.1"
+}
+
+// Candles are only available from version 2 of the API.
+func (client *Client) ApiV2Url() string {
+	return client.BaseUrl + "/Api/v2.0"
+}
+
+// Every response wraps its result in an envelope that reports errors with a
+// 200 status.
+type envelope struct {
+	Success bool
+	Message string
+	Result  json.RawMessage
+}
+
+func decodeResult(resp *http.Response, result interface{}) error {
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode != 200 {
+		fmt.Printf("HTTP ERROR %s\n%s\n", resp.Status, string(body))
+		return fmt.Errorf("request failed: %s", resp.Status)
+	}
+
+	env := &envelope{}
+	err := json.Unmarshal(body, env)
+	if err != nil {
+		return err
+	}
+
+	if !env.Success {
+		return fmt.Errorf("bittrex error: %s", env.Message)
+	}
+
+	return json.Unmarshal(env.Result, result)
+}
@@ -0,0 +1,67 @@
+package bittrex
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Candle intervals accepted by GetTicks.
+const FIVE_MINUTES = "fiveMin"
+const ONE_HOUR = "hour"
+const ONE_DAY = "day"
+
+type MarketSummary struct {
+	MarketName     string
+	High           float64
+	Low            float64
+	Volume         float64
+	Last           float64
+	BaseVolume     float64
+	TimeStamp      string
+	Bid            float64
+	Ask            float64
+	OpenBuyOrders  int64
+	OpenSellOrders int64
+	PrevDay        float64
+}
+
+func (client *Client) GetMarketSummaries() (summaries []*MarketSummary, err error) {
+	resp, err := http.Get(client.ApiUrl() + "/public/getmarketsummaries")
+	if err != nil {
+		return nil, err
+	}
+
+	summaries = make([]*MarketSummary, 0)
+	err = decodeResult(resp, &summaries)
+
+	return summaries, err
+}
+
+// Tick is a candle. T is when it opened.
+type Tick struct {
+	O  float64
+	H  float64
+	L  float64
+	C  float64
+	V  float64
+	BV float64
+	T  string
+}
+
+// GetTicks returns the candles of the last few days, oldest first.
+func (client *Client) GetTicks(marketName, interval string) (ticks []*Tick, err error) {
+	values := url.Values{}
+	values.Set("marketName", marketName)
+	values.Set("tickInterval", interval)
+
+	resp, err := http.Get(fmt.Sprintf("%s/pub/market/GetTicks?%s", client.ApiV2Url(), values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	ticks = make([]*Tick, 0)
+	err = decodeResult(resp, &ticks)
+
+	return ticks, err
+}
@@ -0,0 +1,148 @@
+package bittrex
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/jbgo/sftbot/data"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type Balance struct {
+	Currency  string
+	Balance   data.Decimal
+	Available data.Decimal
+	Pending   data.Decimal
+}
+
+func (client *Client) GetBalance(currency string) (balance *Balance, err error) {
+	values := &url.Values{}
+	values.Set("currency", currency)
+
+	balance = &Balance{}
+	err = client.TradingApiRequest("/account/getbalance", values, balance)
+
+	return balance, err
+}
+
+// Order is both an open order and the summary of a closed one. Bittrex does
+// not report the individual fills of an order: Quantity - QuantityRemaining
+// has been filled, at PricePerUnit on average, for a total of Price plus
+// CommissionPaid.
+type Order struct {
+	OrderUuid         string
+	Exchange          string
+	OrderType         string
+	Type              string
+	Quantity          data.Decimal
+	QuantityRemaining data.Decimal
+	Limit             data.Decimal
+	CommissionPaid    data.Decimal
+	Price             data.Decimal
+	PricePerUnit      data.Decimal
+	Opened            string
+	Closed            string
+	IsOpen            bool
+}
+
+func (client *Client) GetOpenOrders(marketName string) (orders []*Order, err error) {
+	values := &url.Values{}
+	values.Set("market", marketName)
+
+	orders = make([]*Order, 0)
+	err = client.TradingApiRequest("/market/getopenorders", values, &orders)
+
+	return orders, err
+}
+
+func (client *Client) GetOrder(uuid string) (order *Order, err error) {
+	values := &url.Values{}
+	values.Set("uuid", uuid)
+
+	order = &Order{}
+	err = client.TradingApiRequest("/account/getorder", values, order)
+
+	return order, err
+}
+
+type OrderResult struct {
+	Uuid string `json:"uuid"`
+}
+
+func (client *Client) BuyLimit(marketName string, rate, quantity data.Decimal) (*OrderResult, error) {
+	return client.PlaceLimitOrder("/market/buylimit", marketName, rate, quantity)
+}
+
+func (client *Client) SellLimit(marketName string, rate, quantity data.Decimal) (*OrderResult, error) {
+	return client.PlaceLimitOrder("/market/selllimit", marketName, rate, quantity)
+}
+
+func (client *Client) PlaceLimitOrder(path, marketName string, rate, quantity data.Decimal) (result *OrderResult, err error) {
+	values := &url.Values{}
+	values.Set("market", marketName)
+	values.Set("rate", rate.String())
+	values.Set("quantity", quantity.String())
+
+	result = &OrderResult{}
+	err = client.TradingApiRequest(path, values, result)
+
+	return result, err
+}
+
+// TradingApiRequest signs the full request URI, which includes the API key
+// and a nonce, with the API secret.
+func (client *Client) TradingApiRequest(path string, values *url.Values, result interface{}) error {
+	apiKey, apiSecret, err := client.ReadTradingApiCredentials()
+	if err != nil {
+		return err
+	}
+
+	values.Set("apikey", apiKey)
+	values.Set("nonce", strconv.FormatInt(time.Now().UnixNano(), 10))
+
+	uri := client.ApiUrl() + path + "?" + values.Encode()
+
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("apisign", client.Sign(apiSecret, uri))
+	req.Header.Set("Accept", "application/json")
+
+	httpClient := http.Client{}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, result)
+}
+
+func (client *Client) Sign(apiSecret, uri string) string {
+	mac := hmac.New(sha512.New, []byte(apiSecret))
+	mac.Write([]byte(uri))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (client *Client) ReadTradingApiCredentials() (key, secret string, err error) {
+	creds := make(map[string]string)
+
+	fileContent, err := ioutil.ReadFile(client.CredentialsPath)
+	if err != nil {
+		return key, secret, err
+	}
+
+	err = json.Unmarshal(fileContent, &creds)
+	if err != nil {
+		return key, secret, err
+	}
+
+	return creds["key"], creds["secret"], nil
+}
@@ -0,0 +1,165 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"github.com/jbgo/sftbot/data"
+	"github.com/jbgo/sftbot/db"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+type ChartDataExportCommand struct {
+	Flags *flag.FlagSet
+
+	Market     string
+	Resolution int64
+	Format     string
+	Output     string
+
+	StartTimeVar string
+	StartTime    time.Time
+
+	EndTimeVar string
+	EndTime    time.Time
+}
+
+func (c *ChartDataExportCommand) Synopsis() string {
+	return "export imported chart data as CSV or JSON lines"
+}
+
+func (c *ChartDataExportCommand) Help() string {
+	return formatHelpText(`
+Usage: sftbot chart-data export [options]
+
+  Export imported chart data (a.k.a. candlesticks) as CSV or JSON lines. The
+  files can be loaded again with "chart-data import -from-file".
+
+` + helpOptions(c))
+}
+
+func (c *ChartDataExportCommand) InitFlags() *flag.FlagSet {
+	c.Flags = flag.NewFlagSet("chart-data export", flag.ContinueOnError)
+
+	c.Flags.StringVar(&c.Market, "market", "", "Only export this market (e.g. BTC_XYZ). Defaults to all markets")
+	c.Flags.Int64Var(&c.Resolution, "resolution", 0, "Only export this resolution in seconds. Defaults to all resolutions")
+	c.Flags.StringVar(&c.Format, "format", "", "Output format. Choices: csv, jsonl. Defaults to the -out extension, or csv")
+	c.Flags.StringVar(&c.Output, "out", "", "Output file. Defaults to stdout")
+	c.Flags.StringVar(&c.StartTimeVar, "start-time", "", "Start of time range. YYYY-MM-DD HH:MM:SS (TZ)")
+	c.Flags.StringVar(&c.EndTimeVar, "end-time", "", "End of time range. YYYY-MM-DD HH:MM:SS (TZ)")
+
+	return c.Flags
+}
+
+func (c *ChartDataExportCommand) Validate() (err error) {
+	c.StartTime = time.Unix(0, 0)
+	c.EndTime = time.Now()
+
+	if len(c.StartTimeVar) > 0 {
+		c.StartTime, err = time.Parse(TIME_VAR_FORMAT, c.StartTimeVar)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(c.EndTimeVar) > 0 {
+		c.EndTime, err = time.Parse(TIME_VAR_FORMAT, c.EndTimeVar)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(c.Format) == 0 && len(c.Output) > 0 {
+		c.Format, err = data.FileFormat(c.Output)
+		return err
+	}
+
+	if len(c.Format) == 0 {
+		c.Format = data.FORMAT_CSV
+	}
+
+	return nil
+}
+
+func (c *ChartDataExportCommand) Run(args []string) int {
+	c.InitFlags()
+	c.Flags.Parse(args)
+
+	err := c.Validate()
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	var out io.Writer = os.Stdout
+
+	if len(c.Output) > 0 {
+		file, err := os.Create(c.Output)
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+
+		defer file.Close()
+		out = file
+	}
+
+	writer, err := data.NewCandleWriter(out, c.Format)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	count, err := c.Export(writer)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	log.Printf("exported %d candles", count)
+
+	return 0
+}
+
+func (c *ChartDataExportCommand) Export(writer data.CandleWriter) (count int, err error) {
+	chartData := db.Default().ChartData()
+
+	summaries, err := chartData.ChartDataSummaries()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, s := range summaries {
+		// legacy buckets mix resolutions, so they cannot be exported as one series
+		if s.Resolution == 0 {
+			log.Printf(`skipping legacy chart data for %s, run "chart-data migrate"`, s.Market)
+			continue
+		}
+
+		if (len(c.Market) > 0 && s.Market != c.Market) || (c.Resolution > 0 && s.Resolution != c.Resolution) {
+			continue
+		}
+
+		periods, err := chartData.GetPeriods(s.Market, s.Resolution, c.StartTime.Unix(), c.EndTime.Unix())
+		if err != nil {
+			return count, err
+		}
+
+		for _, p := range periods {
+			err = writer.Write(&data.CandleRecord{Market: s.Market, Resolution: s.Resolution, ChartData: *p})
+			if err != nil {
+				return count, err
+			}
+
+			count += 1
+		}
+	}
+
+	if count == 0 && len(c.Market) > 0 {
+		return 0, fmt.Errorf("no chart data found for %s", c.Market)
+	}
+
+	return count, writer.Flush()
+}
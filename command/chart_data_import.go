@@ -4,11 +4,16 @@ import (
 	"flag"
 	"fmt"
 	"github.com/jbgo/sftbot/data"
+	"github.com/jbgo/sftbot/db"
 	"github.com/jbgo/sftbot/plx"
+	"github.com/jbgo/sftbot/trading"
 	"log"
+	"os"
 	"time"
 )
 
+const SECONDS_PER_DAY = 24 * 60 * 60
+
 type ChartDataImportCommand struct {
 	Flags *flag.FlagSet
 
@@ -17,6 +22,14 @@ type ChartDataImportCommand struct {
 	Continuous bool
 	Days       int64
 	Resolution int64
+
+	FromFile     string
+	StartTimeVar string
+	EndTimeVar   string
+
+	// Gaps before this time have already been requested once. The exchange
+	// has no candles for them, so -continuous does not request them again.
+	gapsCheckedUntil int64
 }
 
 func (c *ChartDataImportCommand) Synopsis() string {
@@ -27,7 +40,13 @@ func (c *ChartDataImportCommand) Help() string {
 	return formatHelpText(`
 Usage: sftbot chart-data import [options]
 
-  Import Poloniex chart data (a.k.a. candlesticks).
+  Import Poloniex chart data (a.k.a. candlesticks). Only candles that are not
+  stored yet are downloaded, and any gaps that remain are reported.
+
+  With -from-file, candles are read from a CSV or JSON lines file instead (see
+  "chart-data export" for the columns). -market and -resolution then filter
+  the records in the file, or fill them in for files without those columns.
+  Records with inconsistent prices or volumes are rejected.
 
 ` + helpOptions(c))
 }
@@ -35,10 +54,13 @@ Usage: sftbot chart-data import [options]
 func (c *ChartDataImportCommand) InitFlags() *flag.FlagSet {
 	c.Flags = flag.NewFlagSet("chart-data import", flag.PanicOnError)
 
-	c.Flags.StringVar(&c.CurrencyPair, "market", "", "PLX market as a currency pair. Must be in the format BTC_XYZ")
+	c.Flags.StringVar(&c.CurrencyPair, "market", "", "PLX market as a currency pair (e.g. BTC_XYZ or USDT_XYZ)")
 	c.Flags.BoolVar(&c.Continuous, "continuous", false, "If true, continuously run and import new chart data at the specified resolution.")
 	c.Flags.Int64Var(&c.Days, "days", 7, "The number of days worth of chart data to import.")
-	c.Flags.Int64Var(&c.Resolution, "resolution", 300, "Resolution of chart data in seconds. Choices: 300, 900, 1800, 7200, 1440, 86400")
+	c.Flags.Int64Var(&c.Resolution, "resolution", 300, "Resolution of chart data in seconds. Choices: 300, 900, 1800, 7200, 14400, 86400")
+	c.Flags.StringVar(&c.FromFile, "from-file", "", "Import from a .csv or .jsonl file instead of Poloniex")
+	c.Flags.StringVar(&c.StartTimeVar, "start-time", "", "With -from-file, skip candles before this time. YYYY-MM-DD HH:MM:SS (TZ)")
+	c.Flags.StringVar(&c.EndTimeVar, "end-time", "", "With -from-file, skip candles after this time. YYYY-MM-DD HH:MM:SS (TZ)")
 
 	return c.Flags
 }
@@ -46,11 +68,21 @@ func (c *ChartDataImportCommand) InitFlags() *flag.FlagSet {
 func (c *ChartDataImportCommand) Parse(args []string) error {
 	c.Flags.Parse(args)
 
+	if len(c.FromFile) > 0 {
+		return nil
+	}
+
 	if len(c.CurrencyPair) == 0 {
-		return fmt.Errorf("-currency-pair is required")
+		return fmt.Errorf("-market is required")
 	}
 
-	return nil
+	for _, resolution := range data.RESOLUTIONS {
+		if c.Resolution == resolution {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unsupported -resolution: %d", c.Resolution)
 }
 
 func (c *ChartDataImportCommand) Run(args []string) int {
@@ -63,55 +95,302 @@ func (c *ChartDataImportCommand) Run(args []string) int {
 		return 1
 	}
 
-	db, err := data.OpenDB()
+	if len(c.FromFile) > 0 {
+		err = c.ImportFile()
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+
+		return 0
+	}
+
+	client := plx.NewLiveClient()
+
+	err = c.ValidateMarket(client)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
 
+	err = c.ImportMissing(client)
 	if err != nil {
 		log.Println(err)
 		return 1
 	}
 
-	defer db.Close()
+	if !c.Continuous {
+		return 0
+	}
 
-	client := plx.NewLiveClient()
+	for {
+		// wait for the current period to close before fetching it
+		now := time.Now().Unix()
+		nextPeriod := (now/c.Resolution+1)*c.Resolution + 10
+		time.Sleep(time.Duration(nextPeriod-now) * time.Second)
+
+		err = c.ImportMissing(client)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// ValidateMarket accepts any market in the ticker, as well as delisted
+// markets whose currencies are still known to the exchange, since their chart
+// data can still be downloaded.
+func (c *ChartDataImportCommand) ValidateMarket(client *plx.Client) error {
+	ticker, err := client.GetTickerMap()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := ticker[c.CurrencyPair]; ok {
+		return nil
+	}
 
+	currencies, err := client.GetCurrencies()
+	if err != nil {
+		return err
+	}
+
+	symbol, err := trading.PLX_SYMBOLS.ParseExchangeSymbol(c.CurrencyPair)
+	if err != nil {
+		return err
+	}
+
+	_, baseOk := currencies[symbol.Base]
+	_, currencyOk := currencies[symbol.Currency]
+
+	if baseOk && currencyOk {
+		return nil
+	}
+
+	return fmt.Errorf("unknown market: %s", c.CurrencyPair)
+}
+
+// ImportMissing imports every candle in the last -days days that is not
+// stored yet, then reports any gaps that remain.
+func (c *ChartDataImportCommand) ImportMissing(client *plx.Client) error {
+	chartData := db.Default().ChartData()
+
+	endTime := time.Now().Unix()
+	startTime := endTime - c.Days*SECONDS_PER_DAY
+
+	missing, err := c.MissingRanges(chartData, startTime, endTime)
+	if err != nil {
+		return err
+	}
+
+	for _, gap := range missing {
+		err = c.ImportRange(chartData, client, gap.Start, gap.End)
+		if err != nil {
+			return err
+		}
+	}
+
+	last, err := chartData.LastPeriod(c.CurrencyPair, c.Resolution)
+	if err != nil {
+		return err
+	}
+
+	if last != nil {
+		c.gapsCheckedUntil = last.Date
+	}
+
+	return c.ReportGaps(chartData, startTime, endTime-c.Resolution)
+}
+
+// MissingRanges returns the gaps up to the newest stored candle plus the range
+// from that candle until endTime. The newest candle is fetched again because
+// it may have been stored before its period closed. Gaps that an earlier
+// import already requested are left out.
+func (c *ChartDataImportCommand) MissingRanges(chartData *db.ChartDataRepository, startTime, endTime int64) ([]data.Gap, error) {
+	last, err := chartData.LastPeriod(c.CurrencyPair, c.Resolution)
+	if err != nil {
+		return nil, err
+	}
+
+	if last == nil || last.Date < startTime {
+		return []data.Gap{data.Gap{Start: startTime, End: endTime}}, nil
+	}
+
+	if c.gapsCheckedUntil > startTime {
+		startTime = c.gapsCheckedUntil
+	}
+
+	dates, err := chartData.PeriodDates(c.CurrencyPair, c.Resolution, startTime, last.Date)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := data.FindGaps(dates, c.Resolution, startTime, last.Date)
+	missing = append(missing, data.Gap{Start: last.Date, End: endTime})
+
+	return missing, nil
+}
+
+func (c *ChartDataImportCommand) ImportRange(chartData *db.ChartDataRepository, client *plx.Client, startTime, endTime int64) error {
 	params := plx.ChartDataParams{
 		CurrencyPair: c.CurrencyPair,
 		Period:       c.Resolution,
 	}
 
-	referenceTime := time.Now().Unix()
-	oneDay, _ := time.ParseDuration("24h")
-	secondsPerDay := int64(oneDay.Seconds())
-
-	var numDays int64 = 0
-
-	for numDays < c.Days {
-		params.Start = referenceTime - ((c.Days - numDays) * secondsPerDay)
-		params.End = params.Start + secondsPerDay
+	// both ends of a request are inclusive, so a request never needs to start
+	// at the end of the previous one, but a gap of a single candle is a range
+	// with startTime == endTime
+	for params.Start = startTime; params.Start < endTime || params.Start == startTime; params.Start += SECONDS_PER_DAY {
+		params.End = params.Start + SECONDS_PER_DAY
+		if params.End > endTime {
+			params.End = endTime
+		}
 
 		log.Printf("loading chart data for %v - %v", time.Unix(params.Start, 0), time.Unix(params.End, 0))
 
-		chartData, err := client.GetChartData(&params)
+		periods, err := client.GetChartData(&params)
 
 		if err != nil {
-			log.Println(err)
-			return 1
+			return err
 		}
 
-		for _, p := range chartData {
-			err = db.Write("chart_data."+c.CurrencyPair, p.Date, p)
+		valid := make([]*data.ChartData, 0, len(periods))
 
-			if err != nil {
-				log.Println(err)
-				return 1
+		for i := range periods {
+			// the API returns a single empty candle when there is no data
+			if periods[i].Date != 0 {
+				valid = append(valid, &periods[i])
 			}
 		}
 
-		numDays += 1
+		err = chartData.WritePeriods(c.CurrencyPair, c.Resolution, valid)
+
+		if err != nil {
+			return err
+		}
 
 		// avoid public API rate limits
 		time.Sleep(250 * time.Millisecond)
 	}
 
-	return 0
+	return nil
+}
+
+func (c *ChartDataImportCommand) ReportGaps(chartData *db.ChartDataRepository, startTime, endTime int64) error {
+	dates, err := chartData.PeriodDates(c.CurrencyPair, c.Resolution, startTime, endTime)
+	if err != nil {
+		return err
+	}
+
+	for _, gap := range data.FindGaps(dates, c.Resolution, startTime, endTime) {
+		log.Printf("evt=gap bucket=%s start=%v end=%v periods=%d",
+			db.ChartDataBucket(c.CurrencyPair, c.Resolution), time.Unix(gap.Start, 0), time.Unix(gap.End, 0), gap.Periods(c.Resolution))
+	}
+
+	return nil
+}
+
+// ImportFile stores the valid candles in -from-file that match the filters.
+func (c *ChartDataImportCommand) ImportFile() error {
+	format, err := data.FileFormat(c.FromFile)
+	if err != nil {
+		return err
+	}
+
+	startTime, endTime := int64(0), time.Now().Unix()
+
+	if len(c.StartTimeVar) > 0 {
+		t, err := time.Parse(TIME_VAR_FORMAT, c.StartTimeVar)
+		if err != nil {
+			return err
+		}
+		startTime = t.Unix()
+	}
+
+	if len(c.EndTimeVar) > 0 {
+		t, err := time.Parse(TIME_VAR_FORMAT, c.EndTimeVar)
+		if err != nil {
+			return err
+		}
+		endTime = t.Unix()
+	}
+
+	// only filter by resolution when it was given explicitly
+	filterResolution := false
+	c.Flags.Visit(func(f *flag.Flag) {
+		filterResolution = filterResolution || f.Name == "resolution"
+	})
+
+	file, err := os.Open(c.FromFile)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	series := make(map[string][]*data.ChartData)
+	imported, rejected := 0, 0
+
+	err = data.ReadCandles(file, format, func(line int, record *data.CandleRecord, err error) error {
+		if err == nil {
+			if len(record.Market) == 0 {
+				record.Market = c.CurrencyPair
+			}
+
+			if record.Resolution == 0 {
+				record.Resolution = c.Resolution
+			}
+
+			if len(record.Market) == 0 {
+				err = fmt.Errorf("missing market, use -market for files without a market column")
+			} else {
+				err = record.Validate()
+			}
+		}
+
+		if err != nil {
+			log.Printf(`evt=rejected file=%s line=%d msg="%s"`, c.FromFile, line, err.Error())
+			rejected += 1
+			return nil
+		}
+
+		if len(c.CurrencyPair) > 0 && record.Market != c.CurrencyPair {
+			return nil
+		}
+
+		if filterResolution && record.Resolution != c.Resolution {
+			return nil
+		}
+
+		if record.Date < startTime || record.Date > endTime {
+			return nil
+		}
+
+		bucketName := db.ChartDataBucket(record.Market, record.Resolution)
+		chartData := record.ChartData
+		series[bucketName] = append(series[bucketName], &chartData)
+		imported += 1
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	chartData := db.Default().ChartData()
+
+	for bucketName, periods := range series {
+		market, resolution, _ := db.ParseChartDataBucket(bucketName)
+
+		err = chartData.WritePeriods(market, resolution, periods)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("imported %d candles into %s", len(periods), bucketName)
+	}
+
+	log.Printf("imported=%d rejected=%d", imported, rejected)
+
+	return nil
 }
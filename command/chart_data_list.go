@@ -4,13 +4,15 @@ import (
 	"flag"
 	"fmt"
 	"github.com/jbgo/sftbot/data"
+	"github.com/jbgo/sftbot/db"
 	"log"
 	"time"
 )
 
 type ChartDataListCommand struct {
-	Flags  *flag.FlagSet
-	Market string
+	Flags      *flag.FlagSet
+	Market     string
+	Resolution int64
 
 	StartTimeVar string
 	StartTime    time.Time
@@ -35,7 +37,8 @@ Usage: sftbot chart-data list [options]
 func (c *ChartDataListCommand) InitFlags() *flag.FlagSet {
 	c.Flags = flag.NewFlagSet("chart-data list", flag.PanicOnError)
 
-	c.Flags.StringVar(&c.Market, "market", "", "PLX market as a currency pair. Must be in the format BTC_XYZ")
+	c.Flags.StringVar(&c.Market, "market", "", "PLX market as a currency pair (e.g. BTC_XYZ or USDT_XYZ)")
+	c.Flags.Int64Var(&c.Resolution, "resolution", data.BASE_RESOLUTION, "Resolution of chart data in seconds. Resolutions that are not stored are resampled from 300 second candles.")
 	c.Flags.StringVar(&c.StartTimeVar, "start-time", "", "Start of time range. YYYY-MM-DD HH:MM:SS")
 	c.Flags.StringVar(&c.EndTimeVar, "end-time", "", "End of time range. YYYY-MM-DD HH:MM:SS)")
 
@@ -73,19 +76,13 @@ func (c *ChartDataListCommand) Run(args []string) int {
 	c.Flags.Parse(args)
 	c.Validate()
 
-	db, err := data.OpenDB()
-
+	periods, err := db.Default().ChartData().GetPeriods(c.Market, c.Resolution, c.StartTime.Unix(), c.EndTime.Unix())
 	if err != nil {
-		log.Fatal(err)
+		log.Println(err)
+		return 1
 	}
 
-	defer db.Close()
-
-	err = db.ForEachPeriod(c.Market, func(d *data.ChartData) {
-		if d.Date < c.StartTime.Unix() || d.Date > c.EndTime.Unix() {
-			return
-		}
-
+	for _, d := range periods {
 		timestamp := time.Unix(d.Date, 0).Format("2006-01-02 15:04:05 MST")
 
 		fmt.Printf("%s [%s] vol=%0.9f wavg=%0.9f open=%0.9f close=%0.9f high=%0.9f low=%0.9f qvol=%0.9f\n",
@@ -98,7 +95,7 @@ func (c *ChartDataListCommand) Run(args []string) int {
 			d.High,
 			d.Low,
 			d.QuoteVolume)
-	})
+	}
 
 	if err != nil {
 		log.Println(err)
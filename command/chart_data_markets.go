@@ -0,0 +1,49 @@
+package command
+
+import (
+	"fmt"
+	"github.com/jbgo/sftbot/db"
+	"log"
+	"time"
+)
+
+type ChartDataMarketsCommand struct {
+}
+
+func (c *ChartDataMarketsCommand) Synopsis() string {
+	return "list markets with imported chart data"
+}
+
+func (c *ChartDataMarketsCommand) Help() string {
+	return formatHelpText(`
+Usage: sftbot chart-data markets
+
+  List every market and resolution with imported chart data, with the date
+  range and number of candles stored.
+`)
+}
+
+func (c *ChartDataMarketsCommand) Run(args []string) int {
+	summaries, err := db.Default().ChartData().ChartDataSummaries()
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	for _, s := range summaries {
+		resolution := fmt.Sprintf("%d", s.Resolution)
+		if s.Resolution == 0 {
+			resolution = "legacy"
+			log.Printf(`%s is stored in a legacy bucket, run "chart-data migrate"`, s.Market)
+		}
+
+		fmt.Printf("%-12s resolution=%-6s candles=%-8d first=%s    last=%s\n",
+			s.Market,
+			resolution,
+			s.Count,
+			time.Unix(s.First, 0).Format(TIME_VAR_FORMAT),
+			time.Unix(s.Last, 0).Format(TIME_VAR_FORMAT))
+	}
+
+	return 0
+}
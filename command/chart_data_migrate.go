@@ -0,0 +1,82 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"github.com/jbgo/sftbot/db"
+	"log"
+)
+
+type ChartDataMigrateCommand struct {
+	Flags      *flag.FlagSet
+	Market     string
+	Resolution int64
+}
+
+func (c *ChartDataMigrateCommand) Synopsis() string {
+	return "move legacy chart data into buckets per resolution"
+}
+
+func (c *ChartDataMigrateCommand) Help() string {
+	return formatHelpText(`
+Usage: sftbot chart-data migrate [options]
+
+  Move the candles of legacy "chart_data.<market>" buckets, written before
+  candles were stored per resolution, into "chart_data.<market>.<resolution>"
+  buckets so they can be listed, exported and simulated.
+
+  Unless -resolution is given, the resolution of each legacy bucket is the most
+  common spacing between its candles. Candles already stored at that
+  resolution are kept, and candles at other times are moved to a
+  "quarantine.<bucket>" bucket.
+
+` + helpOptions(c))
+}
+
+func (c *ChartDataMigrateCommand) InitFlags() *flag.FlagSet {
+	c.Flags = flag.NewFlagSet("chart-data migrate", flag.ContinueOnError)
+
+	c.Flags.StringVar(&c.Market, "market", "", "Only migrate this market (e.g. BTC_XYZ)")
+	c.Flags.Int64Var(&c.Resolution, "resolution", 0, "Resolution of the legacy candles in seconds. Defaults to the most common spacing")
+
+	return c.Flags
+}
+
+func (c *ChartDataMigrateCommand) Run(args []string) int {
+	c.InitFlags()
+	c.Flags.Parse(args)
+
+	chartData := db.Default().ChartData()
+
+	summaries, err := chartData.ChartDataSummaries()
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	for _, s := range summaries {
+		if s.Resolution != 0 || (len(c.Market) > 0 && s.Market != c.Market) {
+			continue
+		}
+
+		resolution := c.Resolution
+		if resolution == 0 {
+			resolution, err = chartData.LegacyResolution(s.Market)
+			if err != nil {
+				log.Println(err)
+				return 1
+			}
+		}
+
+		migrated, quarantined, err := chartData.MigrateLegacy(s.Market, resolution)
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+
+		fmt.Printf("%-12s resolution=%-6d candles=%-8d migrated=%-8d quarantined=%d\n",
+			s.Market, resolution, s.Count, migrated, quarantined)
+	}
+
+	return 0
+}
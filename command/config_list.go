@@ -0,0 +1,52 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jbgo/sftbot/db"
+	"github.com/jbgo/sftbot/trading"
+	"log"
+)
+
+type ConfigListCommand struct {
+}
+
+func (c *ConfigListCommand) Synopsis() string {
+	return "list saved trader configs"
+}
+
+func (c *ConfigListCommand) Help() string {
+	return formatHelpText(`
+Usage: sftbot config list
+
+  Print every trader config saved with "config save".
+`)
+}
+
+func (c *ConfigListCommand) Run(args []string) int {
+	configs := trading.NewTraderConfigRepository(db.Default().Store(db.CONFIG_BUCKET))
+
+	names, err := configs.Names()
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	for _, name := range names {
+		config, err := configs.Load(name)
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+
+		encoded, err := json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+
+		fmt.Printf("%s:\n%s\n\n", name, encoded)
+	}
+
+	return 0
+}
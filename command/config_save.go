@@ -0,0 +1,70 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"github.com/jbgo/sftbot/db"
+	"github.com/jbgo/sftbot/trading"
+	"log"
+)
+
+type ConfigSaveCommand struct {
+	Flags *flag.FlagSet
+
+	Name string
+	File string
+}
+
+func (c *ConfigSaveCommand) Synopsis() string {
+	return "save a trader config under a name"
+}
+
+func (c *ConfigSaveCommand) Help() string {
+	return formatHelpText(`
+Usage: sftbot config save [options]
+
+  Store a trader config file in the database. Commands that take -config
+  accept the name instead of a file.
+
+` + helpOptions(c))
+}
+
+func (c *ConfigSaveCommand) InitFlags() *flag.FlagSet {
+	c.Flags = flag.NewFlagSet("config save", flag.ContinueOnError)
+	c.Flags.StringVar(&c.Name, "name", "", "Name to save the config under")
+	c.Flags.StringVar(&c.File, "file", "", "Trader config file (JSON)")
+	return c.Flags
+}
+
+func (c *ConfigSaveCommand) Validate() error {
+	if len(c.Name) == 0 || len(c.File) == 0 {
+		return fmt.Errorf("-name and -file are required")
+	}
+
+	return nil
+}
+
+func (c *ConfigSaveCommand) Run(args []string) int {
+	c.InitFlags()
+	c.Flags.Parse(args)
+
+	err := c.Validate()
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	config, err := trading.LoadTraderConfigFile(c.File)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	err = trading.NewTraderConfigRepository(db.Default().Store(db.CONFIG_BUCKET)).Save(c.Name, config)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,107 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"github.com/jbgo/sftbot/db"
+	"log"
+	"time"
+)
+
+type DBCheckCommand struct {
+	Flags *flag.FlagSet
+
+	Repair  bool
+	Verbose bool
+}
+
+func (c *DBCheckCommand) Synopsis() string {
+	return "check stored chart data and ticks for bad records"
+}
+
+func (c *DBCheckCommand) Help() string {
+	return formatHelpText(`
+Usage: sftbot db check [options]
+
+  Scan every bucket of the database and report records that cannot be decoded,
+  candles with zero or negative prices or a high below the low, duplicate or
+  out-of-order timestamps, records stored under the wrong key, and gaps in the
+  chart data.
+
+  With -repair, bad records are moved to a "quarantine.<bucket>" bucket so they
+  no longer break reads. Gaps are only reported; fill them with
+  "chart-data import".
+
+  Exits with status 1 if problems remain.
+
+` + helpOptions(c))
+}
+
+func (c *DBCheckCommand) InitFlags() *flag.FlagSet {
+	c.Flags = flag.NewFlagSet("db check", flag.ContinueOnError)
+
+	c.Flags.BoolVar(&c.Repair, "repair", false, "If true, quarantine bad records")
+	c.Flags.BoolVar(&c.Verbose, "verbose", false, "If true, list every problem and gap instead of a summary per bucket")
+
+	return c.Flags
+}
+
+func (c *DBCheckCommand) Run(args []string) int {
+	c.InitFlags()
+	c.Flags.Parse(args)
+
+	store := db.Default()
+
+	report, err := store.Check()
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	for _, b := range report.Buckets {
+		if !b.Checked {
+			fmt.Printf("%-32s records=%-8d (not checked)\n", b.Bucket, b.Records)
+			continue
+		}
+
+		fmt.Printf("%-32s records=%-8d problems=%-6d gaps=%d\n", b.Bucket, b.Records, len(b.Problems), len(b.Gaps))
+
+		if !c.Verbose {
+			continue
+		}
+
+		for _, p := range b.Problems {
+			fmt.Printf("  %s\n", p)
+		}
+
+		for _, gap := range b.Gaps {
+			fmt.Printf("  gap: %s - %s (%d candles)\n",
+				time.Unix(gap.Start, 0).Format(TIME_VAR_FORMAT),
+				time.Unix(gap.End, 0).Format(TIME_VAR_FORMAT),
+				gap.Periods(b.Resolution))
+		}
+	}
+
+	problems := report.Problems()
+
+	fmt.Printf("\n%d problems, %d gaps\n", len(problems), report.GapCount())
+
+	if len(problems) == 0 {
+		return 0
+	}
+
+	if !c.Repair {
+		log.Println(`run with -repair to quarantine bad records`)
+		return 1
+	}
+
+	moved, err := store.Quarantine(problems)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	log.Printf("quarantined %d records", moved)
+
+	return 0
+}
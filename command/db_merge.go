@@ -0,0 +1,53 @@
+package command
+
+import (
+	"flag"
+	"github.com/jbgo/sftbot/db"
+	"log"
+)
+
+type DBMergeCommand struct {
+	Flags *flag.FlagSet
+
+	From string
+}
+
+func (c *DBMergeCommand) Synopsis() string {
+	return "copy the buckets of another database file into the database"
+}
+
+func (c *DBMergeCommand) Help() string {
+	return formatHelpText(`
+Usage: sftbot db merge [options]
+
+  Copy every bucket of another bolt file into the database at $` + db.PATH_ENV + `
+  (default ` + db.DEFAULT_PATH + `). Records that already exist are kept, except
+  that the trades of both ledgers are merged.
+
+  Trader state, the ledger and import cursors used to be kept in
+  ` + db.LEGACY_LIVE_PATH + `; merge it once to keep using them. "plx trade"
+  refuses to start until it has been merged.
+
+` + helpOptions(c))
+}
+
+func (c *DBMergeCommand) InitFlags() *flag.FlagSet {
+	c.Flags = flag.NewFlagSet("db merge", flag.ContinueOnError)
+	c.Flags.StringVar(&c.From, "from", db.LEGACY_LIVE_PATH, "Database file to copy from")
+	return c.Flags
+}
+
+func (c *DBMergeCommand) Run(args []string) int {
+	c.InitFlags()
+	c.Flags.Parse(args)
+
+	copied, err := db.Default().Merge(c.From)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	log.Printf("copied %d records from %s into %s", copied, c.From, db.Path())
+
+	return 0
+}
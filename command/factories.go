@@ -17,10 +17,42 @@ func ChartDataList() (cli.Command, error) {
 	return &ChartDataListCommand{}, nil
 }
 
+func ChartDataExport() (cli.Command, error) {
+	return &ChartDataExportCommand{}, nil
+}
+
 func ChartDataImport() (cli.Command, error) {
 	return &ChartDataImportCommand{}, nil
 }
 
+func ChartDataMarkets() (cli.Command, error) {
+	return &ChartDataMarketsCommand{}, nil
+}
+
+func ChartDataMigrate() (cli.Command, error) {
+	return &ChartDataMigrateCommand{}, nil
+}
+
+func ConfigList() (cli.Command, error) {
+	return &ConfigListCommand{}, nil
+}
+
+func ConfigSave() (cli.Command, error) {
+	return &ConfigSaveCommand{}, nil
+}
+
+func DBCheck() (cli.Command, error) {
+	return &DBCheckCommand{}, nil
+}
+
+func DBMerge() (cli.Command, error) {
+	return &DBMergeCommand{}, nil
+}
+
+func ImportTrades() (cli.Command, error) {
+	return &ImportTradesCommand{}, nil
+}
+
 func MyTrades() (cli.Command, error) {
 	return &MyTradesCommand{}, nil
 }
@@ -29,18 +61,46 @@ func OpenOrders() (cli.Command, error) {
 	return &OpenOrdersCommand{}, nil
 }
 
+func Optimize() (cli.Command, error) {
+	return &OptimizeCommand{}, nil
+}
+
+func PnL() (cli.Command, error) {
+	return &PnLCommand{}, nil
+}
+
 func Prospect() (cli.Command, error) {
 	return &ProspectCommand{}, nil
 }
 
+func ReportHTML() (cli.Command, error) {
+	return &ReportHTMLCommand{}, nil
+}
+
+func ReportTax() (cli.Command, error) {
+	return &ReportTaxCommand{}, nil
+}
+
 func Simulate() (cli.Command, error) {
 	return &SimulateCommand{}, nil
 }
 
+func SimulatePortfolio() (cli.Command, error) {
+	return &SimulatePortfolioCommand{}, nil
+}
+
 func Ticker() (cli.Command, error) {
 	return &TickerCommand{}, nil
 }
 
+func TicksCandles() (cli.Command, error) {
+	return &TicksCandlesCommand{}, nil
+}
+
+func TicksImport() (cli.Command, error) {
+	return &TicksImportCommand{}, nil
+}
+
 func Trade() (cli.Command, error) {
 	return &TradeCommand{}, nil
 }
@@ -48,3 +108,7 @@ func Trade() (cli.Command, error) {
 func TradeHistory() (cli.Command, error) {
 	return &TradeHistoryCommand{}, nil
 }
+
+func WalkForward() (cli.Command, error) {
+	return &WalkForwardCommand{}, nil
+}
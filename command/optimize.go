@@ -0,0 +1,166 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"github.com/jbgo/sftbot/trading"
+	"log"
+	"os"
+	"strings"
+)
+
+type OptimizeCommand struct {
+	Flags *flag.FlagSet
+	BacktestOptions
+	OptimizeOptions
+
+	Top    int
+	Output string
+}
+
+// OptimizeOptions are the flags shared by the commands that search for the
+// best trader config.
+type OptimizeOptions struct {
+	Params    stringsFlag
+	Objective string
+	Workers   int
+}
+
+// stringsFlag collects the values of a flag that can be repeated.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, " ")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func (c *OptimizeCommand) Synopsis() string {
+	return "grid search trader config parameters with backtests"
+}
+
+func (c *OptimizeCommand) Help() string {
+	return formatHelpText(`
+Usage: sftbot optimize [options]
+
+  Backtest every combination of the -param ranges on top of the trader
+  config, in parallel, and rank the results by the objective. For example:
+
+    sftbot optimize -currency-pair BTC_XRP \
+      -param ProfitFactor=1.02:1.1:0.02 -param BuyThresholdStart=30:50:10
+
+` + helpOptions(c))
+}
+
+func (c *OptimizeCommand) InitFlags() *flag.FlagSet {
+	c.Flags = flag.NewFlagSet("optimize", flag.ContinueOnError)
+
+	c.BacktestOptions.InitFlags(c.Flags)
+	c.OptimizeOptions.InitFlags(c.Flags)
+	c.Flags.IntVar(&c.Top, "top", 10, "Number of results to print")
+	c.Flags.StringVar(&c.Output, "output", "", "Write every result to this CSV file")
+
+	return c.Flags
+}
+
+func (c *OptimizeCommand) Run(args []string) int {
+	c.InitFlags()
+	c.Flags.Parse(args)
+
+	optimizer, err := c.Optimizer(&c.BacktestOptions)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	report, err := optimizer.Run()
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	fmt.Printf("backtested %d combinations of %d parameters, ranked by %s\n", len(report.Results), len(report.Ranges), report.Objective)
+	report.WriteTable(os.Stdout, c.Top)
+
+	if len(c.Output) > 0 {
+		file, err := os.Create(c.Output)
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+		defer file.Close()
+
+		err = report.WriteCSV(file)
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func (o *OptimizeOptions) InitFlags(flags *flag.FlagSet) {
+	flags.Var(&o.Params, "param", "Range of a numeric TraderConfig field to search, as FIELD=MIN:MAX:STEP or FIELD=VALUE. Repeat for each field.")
+	flags.StringVar(&o.Objective, "objective", trading.OBJECTIVE_RETURN_DRAWDOWN, "Rank results by return, sharpe or return-drawdown (return divided by max drawdown)")
+	flags.IntVar(&o.Workers, "workers", 0, "Backtests to run at once. Defaults to the number of CPUs.")
+}
+
+// Optimizer parses the ranges and loads the backtest they are applied to.
+func (o *OptimizeOptions) Optimizer(backtestOptions *BacktestOptions) (*trading.Optimizer, error) {
+	if len(o.Params) == 0 {
+		return nil, fmt.Errorf("missing -param")
+	}
+
+	if !trading.ValidObjective(o.Objective) {
+		return nil, fmt.Errorf("unknown -objective: %s", o.Objective)
+	}
+
+	ranges := make([]*trading.ParameterRange, 0, len(o.Params))
+	for _, param := range o.Params {
+		r, err := trading.ParseParameterRange(param)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+	}
+
+	backtest, err := backtestOptions.Backtest()
+	if err != nil {
+		return nil, err
+	}
+
+	err = extendWarmUp(backtest, ranges)
+	if err != nil {
+		return nil, err
+	}
+
+	return &trading.Optimizer{
+		Backtest:  backtest,
+		Ranges:    ranges,
+		Objective: o.Objective,
+		Workers:   o.Workers,
+	}, nil
+}
+
+// extendWarmUp loads more chart data before the start time when a range
+// tries a longer TimeWindow than the config the candles were loaded for.
+func extendWarmUp(backtest *trading.Backtest, ranges []*trading.ParameterRange) error {
+	for _, r := range ranges {
+		if r.Field != "TimeWindow" || int64(r.Max) <= backtest.Config.TimeWindow {
+			continue
+		}
+
+		candles, err := loadBacktestCandles(backtest.Market, backtest.Resolution, backtest.StartTime-int64(r.Max), backtest.EndTime)
+		if err != nil {
+			return err
+		}
+
+		backtest.Candles = candles
+	}
+
+	return nil
+}
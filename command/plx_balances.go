@@ -2,6 +2,7 @@ package command
 
 import (
 	"fmt"
+	"github.com/jbgo/sftbot/data"
 	"github.com/jbgo/sftbot/plx"
 	"log"
 )
@@ -26,19 +27,19 @@ func (c *BalancesCommand) Run(args []string) int {
 		log.Fatal(err)
 	}
 
-	totalBtcValue := 0.0
+	totalBtcValue := data.Decimal(0)
 
 	fmt.Println("---")
 
 	for _, balance := range balances {
 		totalBtcValue += balance.BtcValue
 		if balance.BtcValue > 0.0 {
-			fmt.Printf("%-8s available=%0.9f    on_orders=%0.9f    btc_value=%0.9f\n",
-				balance.Currency, balance.Available, balance.OnOrders, balance.BtcValue)
+			fmt.Printf("%-8s available=%s    on_orders=%s    btc_value=%s\n",
+				balance.Currency, balance.Available.Format(), balance.OnOrders.Format(), balance.BtcValue.Format())
 		}
 	}
 
-	fmt.Printf("---\nTotal BTC value: %0.9f\n", totalBtcValue)
+	fmt.Printf("---\nTotal BTC value: %s\n", totalBtcValue.Format())
 
 	return 0
 }
@@ -0,0 +1,84 @@
+package command
+
+import (
+	"flag"
+	"github.com/jbgo/sftbot/db"
+	"github.com/jbgo/sftbot/plx"
+	"github.com/jbgo/sftbot/trading"
+	"log"
+	"time"
+)
+
+type ImportTradesCommand struct {
+	Flags *flag.FlagSet
+
+	StartTimeVar string
+}
+
+func (c *ImportTradesCommand) Synopsis() string {
+	return "import my trade history for all markets into the trade ledger"
+}
+
+func (c *ImportTradesCommand) Help() string {
+	return formatHelpText(`
+Usage: sftbot plx import-trades [options]
+
+  Import the private trade history of every market into the local trade
+  ledger. Trades are deduplicated, and each run resumes from where the
+  previous import finished.
+
+` + helpOptions(c))
+}
+
+func (c *ImportTradesCommand) InitFlags() *flag.FlagSet {
+	c.Flags = flag.NewFlagSet("plx import-trades", flag.ContinueOnError)
+
+	c.Flags.StringVar(&c.StartTimeVar, "start-time", "", "Import from this time instead of resuming. YYYY-MM-DD HH:MM:SS (TZ)")
+
+	return c.Flags
+}
+
+func (c *ImportTradesCommand) Run(args []string) int {
+	c.InitFlags()
+	c.Flags.Parse(args)
+
+	store := db.Default()
+	ledger := trading.NewLedger(store.Store(db.LEDGER_BUCKET))
+
+	importer := trading.NewTradeImporter(plx.NewLiveClient(), ledger, store.Store(db.IMPORTS_BUCKET))
+
+	startTime, err := c.StartTime(importer)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	endTime := time.Now().Unix()
+
+	log.Printf("importing trades for %v - %v", time.Unix(startTime, 0), time.Unix(endTime, 0))
+
+	fetched, err := importer.Import(startTime, endTime)
+
+	log.Printf("fetched %d trades", fetched)
+
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	return 0
+}
+
+func (c *ImportTradesCommand) StartTime(importer *trading.TradeImporter) (int64, error) {
+	if len(c.StartTimeVar) > 0 {
+		startTime, err := time.Parse(TIME_VAR_FORMAT, c.StartTimeVar)
+		return startTime.Unix(), err
+	}
+
+	cursor, err := importer.Cursor()
+	if err != nil || cursor > 0 {
+		return cursor, err
+	}
+
+	return PLX_INCEPTION, nil
+}
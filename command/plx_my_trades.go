@@ -86,8 +86,8 @@ func (c *MyTradesCommand) Run(args []string) int {
 	for market, trades := range tradeHistory {
 		fmt.Printf("=== %s ===\n", market)
 		for _, t := range trades {
-			fmt.Printf("%-4s %s    rate=%0.9f    amount=%0.9f    fee=%0.9f    total=%0.9f    order=%s\n",
-				t.Type, t.Date, t.Rate, t.Amount, t.Fee, t.Total, t.OrderNumber)
+			fmt.Printf("%-4s %s    rate=%s    amount=%s    fee=%0.9f    total=%s    order=%s\n",
+				t.Type, t.Date, t.Rate.Format(), t.Amount.Format(), t.Fee, t.Total.Format(), t.OrderNumber)
 		}
 	}
 
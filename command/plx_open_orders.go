@@ -36,11 +36,11 @@ func (c *OpenOrdersCommand) Run(args []string) int {
 
 		for _, order := range orders {
 			fmt.Printf(
-				"%s rate=%0.9f amount=%0.9f total=%0.9f\n orderNumber=%d",
+				"%s rate=%s amount=%s total=%s\n orderNumber=%d",
 				strings.ToUpper(order.Type),
-				order.Rate,
-				order.Amount,
-				order.Total,
+				order.Rate.Format(),
+				order.Amount.Format(),
+				order.Total.Format(),
 				order.Number)
 		}
 	}
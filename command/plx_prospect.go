@@ -1,13 +1,11 @@
 package command
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
 	"github.com/jbgo/sftbot/db"
 	"github.com/jbgo/sftbot/plx"
 	"github.com/jbgo/sftbot/trading"
-	"io/ioutil"
 	"log"
 	"sort"
 	"strings"
@@ -39,7 +37,7 @@ Usage: sftbot plx ticker [options]
 func (c *ProspectCommand) InitFlags() *flag.FlagSet {
 	c.Flags = flag.NewFlagSet("plx ticker", flag.ContinueOnError)
 
-	c.Flags.StringVar(&c.Config, "config", "", "Trader config file (JSON)")
+	c.Flags.StringVar(&c.Config, "config", "", "Trader config file (JSON) or name of a saved config")
 
 	return c.Flags
 }
@@ -93,6 +91,7 @@ func (c *ProspectCommand) Run(args []string) int {
 				fmt.Sprintf("price=%0.9f   ", marketData.CurrentPrice),
 				fmt.Sprintf("bid=%0.9f   ", bid),
 				fmt.Sprintf("volatility=%0.4f   ", marketData.VolatilityIndex),
+				fmt.Sprintf("rsi=%0.1f   ", marketData.Indicators["rsi"]),
 				fmt.Sprintf("volume=%0.4f   ", t.BaseVolume),
 				"\n",
 			}, " "))
@@ -103,14 +102,7 @@ func (c *ProspectCommand) Run(args []string) int {
 }
 
 func (c *ProspectCommand) InitDB() {
-	dbPath := LIVE_DB
-
-	dbStore, err := db.NewBoltStore("prospect", dbPath)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	c.DBStore = dbStore
+	c.DBStore = db.Default().Store(db.PROSPECT_BUCKET)
 }
 
 func (c *ProspectCommand) InitTrader(marketName string) (*trading.Trader, error) {
@@ -131,17 +123,5 @@ func (c *ProspectCommand) InitTrader(marketName string) (*trading.Trader, error)
 }
 
 func (c *ProspectCommand) LoadTraderConfig() (*trading.TraderConfig, error) {
-	if len(c.Config) == 0 {
-		return nil, fmt.Errorf("-config is required")
-	}
-
-	data, err := ioutil.ReadFile(c.Config)
-	if err != nil {
-		return nil, err
-	}
-
-	traderConfig := &trading.TraderConfig{}
-	err = json.Unmarshal(data, traderConfig)
-
-	return traderConfig, err
+	return loadTraderConfig(c.Config)
 }
@@ -1,21 +1,19 @@
 package command
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
+	"github.com/jbgo/sftbot/data"
 	"github.com/jbgo/sftbot/db"
 	"github.com/jbgo/sftbot/plx"
 	"github.com/jbgo/sftbot/trading"
-	"io/ioutil"
 	"log"
+	"os"
 	"runtime"
 	"sort"
-	"strings"
 	"time"
 )
 
-const LIVE_DB = "sftbot-live.db"
 const TRADE_INTERVAL = 300 // 5 minutes
 
 type TradeCommand struct {
@@ -25,7 +23,15 @@ type TradeCommand struct {
 	Config string
 	Offset int64
 
-	DBStore db.Store
+	Paper        bool
+	PaperBalance float64
+	Feed         string
+
+	ExchangeConfig string
+
+	DBStore  db.Store
+	Ledger   *trading.Ledger
+	Exchange trading.Exchange
 }
 
 func (c *TradeCommand) Synopsis() string {
@@ -38,19 +44,47 @@ Usage: sftbot plx ticker [options]
 
   ` + c.Synopsis() + `
 
+  -exchange names the exchange adapter to trade on (poloniex or bittrex), or a
+  JSON file such as {"Name": "bittrex", "CredentialsPath": "./creds.json"}
+  that can also override the BaseUrl of its API. Markets are always named
+  BASE_CURRENCY, e.g. BTC_XYZ, whatever the exchange calls them.
+
+  With -paper, orders go to a virtual exchange instead of Poloniex. Balances
+  start at -paper-balance BTC, and resting orders fill only when a later public
+  trade reaches their price. Public data comes from the Poloniex API
+  (-feed plx) or from ticks and chart data recorded in the database
+  (-feed recorded). Paper trader state, orders and balances are saved apart
+  from live trading and survive restarts, so a config can be forward-tested
+  for weeks. Report its trades with "sftbot pnl -paper".
+
 ` + helpOptions(c))
 }
 
 func (c *TradeCommand) InitFlags() *flag.FlagSet {
 	c.Flags = flag.NewFlagSet("plx ticker", flag.ContinueOnError)
-	c.Flags.StringVar(&c.Config, "config", "", "Trader config file (JSON)")
+	c.Flags.StringVar(&c.Config, "config", "", "Trader config file (JSON) or name of a saved config")
+	c.Flags.StringVar(&c.ExchangeConfig, "exchange", trading.EXCHANGE_POLONIEX, "Exchange name or exchange config file (JSON)")
+	c.Flags.BoolVar(&c.Paper, "paper", false, "Trade on a virtual exchange with public data")
+	c.Flags.Float64Var(&c.PaperBalance, "paper-balance", 1.0, "Starting BTC balance of the paper exchange")
+	c.Flags.StringVar(&c.Feed, "feed", "plx", "Public data of the paper exchange. Choices: plx, recorded")
 	return c.Flags
 }
 
 func (c *TradeCommand) Run(args []string) int {
 	c.InitFlags()
 	c.Flags.Parse(args)
-	c.InitDB()
+
+	err := c.InitDB()
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	err = c.InitExchange()
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
 
 	c.TradeContinuously(TRADE_INTERVAL)
 
@@ -91,30 +125,95 @@ func (c *TradeCommand) TradeContinuously(interval int64) {
 	}
 }
 
-func (c *TradeCommand) InitDB() {
-	dbPath := LIVE_DB
+func (c *TradeCommand) InitDB() error {
+	store := db.Default()
+
+	if c.Paper {
+		c.DBStore = store.Store(db.PAPER_BUCKET)
+		c.Ledger = trading.NewLedger(store.Store(db.PAPER_LEDGER_BUCKET))
+		return nil
+	}
+
+	err := store.CheckLegacyMerged()
+	if err != nil {
+		return err
+	}
 
-	dbStore, err := db.NewBoltStore("trading", dbPath)
+	config, err := loadExchangeConfig(c.ExchangeConfig)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	c.DBStore = dbStore
+	c.DBStore = store.Store(exchangeBucket(db.TRADING_BUCKET, config.Name))
+	c.Ledger = trading.NewLedger(store.Store(exchangeBucket(db.LEDGER_BUCKET, config.Name)))
+
+	return nil
 }
-func (c *TradeCommand) TradeOnce() error {
-	client := plx.NewLiveClient()
-	ticker, err := client.GetTicker()
 
+// InitExchange connects to the exchange given by -exchange, or to a paper
+// exchange with -paper.
+func (c *TradeCommand) InitExchange() error {
+	if !c.Paper {
+		config, err := loadExchangeConfig(c.ExchangeConfig)
+		if err != nil {
+			return err
+		}
+
+		c.Exchange, err = trading.NewExchange(config)
+
+		return err
+	}
+
+	var feed trading.PaperFeed
+
+	switch c.Feed {
+	case "plx":
+		feed = &trading.PlxPaperFeed{Client: plx.NewLiveClient()}
+	case "recorded":
+		store := db.Default()
+
+		summaries, err := store.ChartData().ChartDataSummaries()
+		if err != nil {
+			return err
+		}
+
+		recorded := &trading.RecordedPaperFeed{DB: store}
+		for _, s := range summaries {
+			if s.Resolution == data.BASE_RESOLUTION {
+				recorded.Markets = append(recorded.Markets, s.Market)
+			}
+		}
+
+		feed = recorded
+	default:
+		return fmt.Errorf("unknown -feed: %s", c.Feed)
+	}
+
+	exchange, err := trading.NewPaperExchange(feed, c.DBStore, map[string]float64{"BTC": c.PaperBalance})
 	if err != nil {
 		return err
 	}
 
-	sort.Sort(ByVolumeDesc(ticker))
+	c.Exchange = exchange
 
-	for _, t := range ticker {
-		interesting := strings.Contains(t.Market, "BTC_") && t.BaseVolume >= 500
+	return nil
+}
+
+func (c *TradeCommand) TradeOnce() error {
+	tickerMap, err := c.Exchange.GetTicker()
+	if err != nil {
+		return err
+	}
 
-		if !interesting {
+	ticker := make([]*trading.TickerEntry, 0, len(tickerMap))
+	for _, t := range tickerMap {
+		ticker = append(ticker, t)
+	}
+
+	sort.Sort(ByBaseVolumeDesc(ticker))
+
+	for _, t := range ticker {
+		if !trading.ShouldTradeMarket(t.Market, t.BaseVolume) {
 			continue
 		}
 
@@ -133,34 +232,64 @@ func (c *TradeCommand) TradeOnce() error {
 }
 
 func (c *TradeCommand) InitTrader(marketName string) (*trading.Trader, error) {
-	plxClient := plx.NewLiveClient()
-	plxExchange := trading.NewPlxExchange(plxClient)
-
 	traderConfig, err := c.LoadTraderConfig()
 	if err != nil {
 		return nil, err
 	}
 
-	trader, err := trading.NewTrader(marketName, plxExchange, c.DBStore, traderConfig)
+	// The paper exchange decides when orders fill, so the trader must not
+	// assume simulated fills.
+	if c.Paper {
+		traderConfig.Simulate = false
+	}
+
+	trader, err := trading.NewTrader(marketName, c.Exchange, c.DBStore, traderConfig)
 	if err != nil {
 		return nil, err
 	}
 
+	trader.Ledger = c.Ledger
+
 	return trader, nil
 }
 
 func (c *TradeCommand) LoadTraderConfig() (*trading.TraderConfig, error) {
-	if len(c.Config) == 0 {
+	return loadTraderConfig(c.Config)
+}
+
+// loadTraderConfig reads the given config file or, when there is no such
+// file, the config saved under that name with "config save".
+func loadTraderConfig(config string) (*trading.TraderConfig, error) {
+	if len(config) == 0 {
 		return nil, fmt.Errorf("-config is required")
 	}
 
-	data, err := ioutil.ReadFile(c.Config)
-	if err != nil {
-		return nil, err
+	_, err := os.Stat(config)
+	if err == nil {
+		return trading.LoadTraderConfigFile(config)
 	}
 
-	traderConfig := &trading.TraderConfig{}
-	err = json.Unmarshal(data, traderConfig)
+	return trading.NewTraderConfigRepository(db.Default().Store(db.CONFIG_BUCKET)).Load(config)
+}
+
+// exchangeBucket names the bucket of an exchange. Trader state and ledgers are
+// kept per market name, which is the same on every exchange, so each exchange
+// other than Poloniex gets its own buckets.
+func exchangeBucket(bucketName, exchangeName string) string {
+	if exchangeName == trading.EXCHANGE_POLONIEX {
+		return bucketName
+	}
+
+	return bucketName + "." + exchangeName
+}
+
+// loadExchangeConfig reads the given exchange config file or, when there is
+// no such file, selects the exchange of that name.
+func loadExchangeConfig(exchange string) (*trading.ExchangeConfig, error) {
+	_, err := os.Stat(exchange)
+	if err == nil {
+		return trading.LoadExchangeConfigFile(exchange)
+	}
 
-	return traderConfig, err
+	return &trading.ExchangeConfig{Name: exchange}, nil
 }
@@ -0,0 +1,126 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"github.com/jbgo/sftbot/db"
+	"github.com/jbgo/sftbot/trading"
+	"log"
+)
+
+type PnLCommand struct {
+	Flags          *flag.FlagSet
+	Market         string
+	Paper          bool
+	ExchangeConfig string
+}
+
+func (c *PnLCommand) Synopsis() string {
+	return "report realised and unrealised profit and loss from the trade ledger"
+}
+
+func (c *PnLCommand) Help() string {
+	return formatHelpText(`
+Usage: sftbot pnl [options]
+
+  Report realised P&L (FIFO), unrealised P&L at the current ticker price,
+  fees paid and win rate for each market in the trade ledger.
+
+` + helpOptions(c))
+}
+
+func (c *PnLCommand) InitFlags() *flag.FlagSet {
+	c.Flags = flag.NewFlagSet("pnl", flag.ContinueOnError)
+
+	c.Flags.StringVar(&c.Market, "market", "", "Only report this PLX market (e.g. BTC_XYZ)")
+	c.Flags.BoolVar(&c.Paper, "paper", false, "Report the ledger of paper trading (plx trade -paper)")
+	c.Flags.StringVar(&c.ExchangeConfig, "exchange", trading.EXCHANGE_POLONIEX, "Report the ledger of this exchange (name or exchange config file)")
+
+	return c.Flags
+}
+
+func (c *PnLCommand) Run(args []string) int {
+	c.InitFlags()
+	c.Flags.Parse(args)
+
+	config, err := loadExchangeConfig(c.ExchangeConfig)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	// paper trading follows Poloniex prices
+	bucket := exchangeBucket(db.LEDGER_BUCKET, config.Name)
+	if c.Paper {
+		bucket = db.PAPER_LEDGER_BUCKET
+		config = &trading.ExchangeConfig{Name: trading.EXCHANGE_POLONIEX}
+	}
+
+	ledger := trading.NewLedger(db.Default().Store(bucket))
+
+	markets, err := ledger.Markets()
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	if len(c.Market) > 0 {
+		markets = []string{c.Market}
+	}
+
+	exchange, err := trading.NewExchange(config)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	ticker, err := exchange.GetTicker()
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	total := &trading.PnL{Market: "TOTAL"}
+
+	for _, market := range markets {
+		trades, err := ledger.Trades(market)
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+
+		// delisted markets are missing from the ticker
+		price := 0.0
+		if entry, ok := ticker[market]; ok {
+			price = entry.Last
+		}
+
+		pnl := trading.CalculatePnL(market, trades, price)
+		total.Add(pnl)
+
+		printPnL(pnl)
+
+		if price <= 0 && pnl.OpenAmount > 0 {
+			fmt.Printf("%-12s WARNING no ticker price, %0.9f open is not included in unrealised\n", market, pnl.OpenAmount)
+		}
+
+		if pnl.Unmatched > 0 {
+			fmt.Printf("%-12s WARNING sold %0.9f more than the ledger bought\n", market, pnl.Unmatched)
+		}
+	}
+
+	fmt.Println("---")
+	printPnL(total)
+
+	return 0
+}
+
+func printPnL(pnl *trading.PnL) {
+	fmt.Printf("%-12s realised=%0.9f    unrealised=%0.9f    fees=%0.9f    trades=%d    win_rate=%0.2f%%\n",
+		pnl.Market,
+		pnl.Realised,
+		pnl.Unrealised,
+		pnl.Fees,
+		pnl.Wins+pnl.Losses,
+		pnl.WinRate()*100)
+}
@@ -0,0 +1,177 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"github.com/jbgo/sftbot/db"
+	"github.com/jbgo/sftbot/trading"
+	"log"
+	"os"
+	"time"
+)
+
+type ReportHTMLCommand struct {
+	Flags *flag.FlagSet
+	BacktestOptions
+
+	Source   string
+	Output   string
+	Exchange string
+}
+
+func (c *ReportHTMLCommand) Synopsis() string {
+	return "render a backtest or live trading as a self-contained HTML report"
+}
+
+func (c *ReportHTMLCommand) Help() string {
+	return formatHelpText(`
+Usage: sftbot report html [options]
+
+  Write a single HTML file, with no external resources, charting the price of
+  the given currency pair with the trader's percentile bands and every buy and
+  sell, the equity curve, the drawdown and a table of performance metrics.
+
+  With -source backtest, the fills and equity curve come from backtesting the
+  trader on stored chart data, as in simulate. With -source ledger, they come
+  from the trades recorded in the ledger by live trading between the start and
+  end times, valued with stored chart data from -starting-balance.
+
+` + helpOptions(c))
+}
+
+func (c *ReportHTMLCommand) InitFlags() *flag.FlagSet {
+	c.Flags = flag.NewFlagSet("report html", flag.ContinueOnError)
+
+	c.BacktestOptions.InitFlags(c.Flags)
+	c.Flags.StringVar(&c.Source, "source", "backtest", "Where the fills come from. Choices: backtest, ledger")
+	c.Flags.StringVar(&c.Output, "out", "report.html", "HTML output file")
+	c.Flags.StringVar(&c.Exchange, "exchange", trading.EXCHANGE_POLONIEX, "With -source ledger, the exchange whose ledger to report")
+
+	return c.Flags
+}
+
+func (c *ReportHTMLCommand) Run(args []string) int {
+	c.InitFlags()
+	c.Flags.Parse(args)
+
+	report, err := c.Report()
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	file, err := os.Create(c.Output)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	defer file.Close()
+
+	err = report.WriteHTML(file)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	log.Printf("wrote %s", c.Output)
+
+	return 0
+}
+
+func (c *ReportHTMLCommand) Report() (*trading.HTMLReport, error) {
+	var result *trading.BacktestResult
+	var candles []*trading.SummaryData
+	var config *trading.TraderConfig
+	var title string
+
+	switch c.Source {
+	case "backtest":
+		backtest, err := c.Backtest()
+		if err != nil {
+			return nil, err
+		}
+
+		result, err = backtest.Run()
+		if err != nil {
+			return nil, err
+		}
+
+		candles, config = backtest.Candles, backtest.Config
+		title = fmt.Sprintf("%s backtest", c.CurrencyPair)
+	case "ledger":
+		var err error
+
+		result, candles, config, err = c.ledgerResult()
+		if err != nil {
+			return nil, err
+		}
+
+		title = fmt.Sprintf("%s live trading", c.CurrencyPair)
+	default:
+		return nil, fmt.Errorf("unknown -source: %s", c.Source)
+	}
+
+	return &trading.HTMLReport{
+		Title:       title,
+		Result:      result,
+		Candles:     candles,
+		Window:      config.TimeWindow,
+		Percentiles: []int64{config.BuyThresholdMin, config.BuyThresholdMax},
+		GeneratedAt: time.Now(),
+	}, nil
+}
+
+// ledgerResult replays the ledger's trades between the start and end times
+// over stored chart data.
+func (c *ReportHTMLCommand) ledgerResult() (*trading.BacktestResult, []*trading.SummaryData, *trading.TraderConfig, error) {
+	if len(c.CurrencyPair) == 0 {
+		return nil, nil, nil, fmt.Errorf("missing -currency-pair")
+	}
+
+	err := c.Validate()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	config, err := c.TraderConfig()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	startTime, endTime := c.StartTime.Unix(), c.EndTime.Unix()
+
+	candles, err := loadBacktestCandles(c.CurrencyPair, c.Resolution, startTime-config.TimeWindow, endTime)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	charted := make([]*trading.SummaryData, 0, len(candles))
+	for _, candle := range candles {
+		if candle.Date >= startTime {
+			charted = append(charted, candle)
+		}
+	}
+
+	if len(charted) == 0 {
+		return nil, nil, nil, fmt.Errorf("no chart data for %s between %s and %s", c.CurrencyPair, c.StartTimeVar, c.EndTimeVar)
+	}
+
+	ledger := trading.NewLedger(db.Default().Store(exchangeBucket(db.LEDGER_BUCKET, c.Exchange)))
+
+	allTrades, err := ledger.Trades(c.CurrencyPair)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	trades := make([]*trading.Trade, 0)
+	for _, trade := range allTrades {
+		if trade.Date >= startTime && trade.Date <= endTime {
+			trades = append(trades, trade)
+		}
+	}
+
+	equity := trading.LedgerEquity(charted, trades, c.StartingBalance)
+	result := trading.NewBacktestResult(c.CurrencyPair, c.Resolution, c.StartingBalance, equity, trades)
+
+	return result, candles, config, nil
+}
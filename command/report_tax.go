@@ -0,0 +1,149 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"github.com/jbgo/sftbot/db"
+	"github.com/jbgo/sftbot/plx"
+	"github.com/jbgo/sftbot/trading"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// Poloniex opened in January 2014, so no account has trades before this.
+const PLX_INCEPTION = 1388534400
+
+type ReportTaxCommand struct {
+	Flags *flag.FlagSet
+
+	Year     int
+	Method   string
+	Source   string
+	Output   string
+	Exchange string
+}
+
+func (c *ReportTaxCommand) Synopsis() string {
+	return "export acquisitions and disposals with cost basis for a tax year"
+}
+
+func (c *ReportTaxCommand) Help() string {
+	return formatHelpText(`
+Usage: sftbot report tax [options]
+
+  Write a CSV of every acquisition and disposal in the given year (UTC) with
+  proceeds, cost basis and gain in BTC. Lots from earlier years are used for
+  the cost basis of disposals.
+
+` + helpOptions(c))
+}
+
+func (c *ReportTaxCommand) InitFlags() *flag.FlagSet {
+	c.Flags = flag.NewFlagSet("report tax", flag.ContinueOnError)
+
+	c.Flags.IntVar(&c.Year, "year", time.Now().Year()-1, "Tax year")
+	c.Flags.StringVar(&c.Method, "method", trading.LOT_METHOD_FIFO, "Lot matching method. Choices: fifo, lifo, hifo")
+	c.Flags.StringVar(&c.Source, "source", "ledger", "Where to read trades from. Choices: ledger, plx")
+	c.Flags.StringVar(&c.Output, "out", "", "CSV output file. Defaults to stdout")
+	c.Flags.StringVar(&c.Exchange, "exchange", trading.EXCHANGE_POLONIEX, "With -source ledger, the exchange whose ledger to report")
+
+	return c.Flags
+}
+
+func (c *ReportTaxCommand) Validate() error {
+	if !trading.ValidLotMethod(c.Method) {
+		return fmt.Errorf("unknown -method: %s", c.Method)
+	}
+
+	if c.Source != "ledger" && c.Source != "plx" {
+		return fmt.Errorf("unknown -source: %s", c.Source)
+	}
+
+	return nil
+}
+
+func (c *ReportTaxCommand) Run(args []string) int {
+	c.InitFlags()
+	c.Flags.Parse(args)
+
+	err := c.Validate()
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	trades, err := c.LoadTrades()
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	report, err := trading.BuildTaxReport(trades, c.Year, c.Method)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	var out io.Writer = os.Stdout
+
+	if len(c.Output) > 0 {
+		file, err := os.Create(c.Output)
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+
+		defer file.Close()
+		out = file
+	}
+
+	err = report.WriteCSV(out)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	log.Printf("year=%d method=%s acquisitions=%d disposals=%d gain=%s\n",
+		c.Year, c.Method, len(report.Acquisitions), len(report.Disposals), report.TotalGain())
+
+	return 0
+}
+
+// LoadTrades reads the trade ledger. With -source plx, the complete trade
+// history is paged into a ledger in memory instead, since a single request for
+// the full history is truncated.
+func (c *ReportTaxCommand) LoadTrades() ([]*trading.Trade, error) {
+	ledger := trading.NewLedger(db.Default().Store(exchangeBucket(db.LEDGER_BUCKET, c.Exchange)))
+
+	if c.Source == "plx" {
+		ledger = trading.NewLedger(db.NewMemoryStore())
+		importer := trading.NewTradeImporter(plx.NewLiveClient(), ledger, db.NewMemoryStore())
+
+		endTime := time.Date(c.Year+1, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+
+		_, err := importer.Import(PLX_INCEPTION, endTime)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	markets, err := ledger.Markets()
+	if err != nil {
+		return nil, err
+	}
+
+	trades := make([]*trading.Trade, 0)
+
+	for _, market := range markets {
+		marketTrades, err := ledger.Trades(market)
+		if err != nil {
+			return nil, err
+		}
+
+		trades = append(trades, marketTrades...)
+	}
+
+	return trades, nil
+}
@@ -1,42 +1,48 @@
 package command
 
 import (
-	"bytes"
 	"flag"
 	"fmt"
-	"github.com/boltdb/bolt"
 	"github.com/jbgo/sftbot/data"
+	"github.com/jbgo/sftbot/db"
+	"github.com/jbgo/sftbot/trading"
+	"io"
 	"log"
-	"math"
-	"sort"
+	"os"
+	"path/filepath"
 	"time"
 )
 
-const WINDOW = 24 * 60 * 60
-const BUY_COOLOFF = 0
-const SELL_COOLOFF = 0
-
-const BTC_BUY_AMOUNT = 0.01
-const XRP_SELL_AMOUNT = 90
-const XRP_SELL_PCT = 0.5
-const PLX_FEE = 0.00025
-const VOLATILITY = 1.02
-const PROFIT_FACTOR = 1.06
+const TIME_VAR_FORMAT = "2006-01-02 15:04:05 MST"
 
 type SimulateCommand struct {
-	DB    *data.Store
 	Flags *flag.FlagSet
+	BacktestOptions
+	JournalOptions
 
-	BTC_Balance   float64
-	XRP_Balance   float64
-	PLX_Balance   float64
-	Bids          []float64
-	LastBuyTime   int64
-	LastSellTime  int64
-	BuyThreshold  int
-	SellThreshold float64
+	JSONOutput string
+
+	MonteCarloPaths int
+	MonteCarlo      string
+	BlockSize       int
+	RuinLevel       float64
+	Seed            int64
+	Workers         int
+}
 
-	CurrencyPair string
+// BacktestOptions are the flags shared by the commands that run backtests.
+type BacktestOptions struct {
+	Config          string
+	CurrencyPair    string
+	Resolution      int64
+	StartingBalance float64
+	MakerFee        float64
+	TakerFee        float64
+
+	Fill          string
+	Latency       int
+	Slippage      float64
+	Participation float64
 
 	StartTimeVar string
 	StartTime    time.Time
@@ -45,7 +51,12 @@ type SimulateCommand struct {
 	EndTime    time.Time
 }
 
-const TIME_VAR_FORMAT = "2006-01-02 15:04:05 MST"
+// JournalOptions are the flags of the commands that can write a backtest's
+// orders, fills and daily NAV to files.
+type JournalOptions struct {
+	JournalDir    string
+	JournalFormat string
+}
 
 func (c *SimulateCommand) Synopsis() string {
 	return "simulate trading the given currency pair"
@@ -54,7 +65,17 @@ func (c *SimulateCommand) Help() string {
 	return formatHelpText(`
 Usage: sftbot simulate [options]
 
-  Simulate trading the given currency pair.
+  Backtest the trader on stored chart data for the given currency pair and
+  report its return against buy and hold, max drawdown, Sharpe and Sortino
+  ratios, trades, win rate, holding time, fees and exposure.
+
+  With -paths, also run it on that many synthetic price paths resampled from
+  the same chart data and report the distribution of returns and drawdowns
+  and the probability of ruin.
+
+  With -journal, also write every order with the thresholds and indicator
+  values it was based on, every fill and the NAV at the end of each day to
+  orders, fills and nav files in that directory.
 
 ` + helpOptions(c))
 }
@@ -62,230 +83,284 @@ Usage: sftbot simulate [options]
 func (c *SimulateCommand) InitFlags() *flag.FlagSet {
 	c.Flags = flag.NewFlagSet("simulate", flag.PanicOnError)
 
-	c.Flags.StringVar(&c.CurrencyPair, "currency-pair", "", "PLX currency pair. Must be in the format BTC_XYZ")
-	c.Flags.StringVar(&c.StartTimeVar, "start-time", "2017-05-01 00:00:00 CST", "Simulation start time. YYYY-MM-DD HH:MM:SS (TZ)")
-	c.Flags.StringVar(&c.EndTimeVar, "end-time", "2017-06-21 00:00:00 CST", "Simulation end time. YYYY-MM-DD HH:MM:SS (TZ)")
+	c.BacktestOptions.InitFlags(c.Flags)
+	c.JournalOptions.InitFlags(c.Flags)
+	c.Flags.StringVar(&c.JSONOutput, "json", "", "Also write the results as JSON to this file")
+	c.Flags.IntVar(&c.MonteCarloPaths, "paths", 0, "Number of synthetic price paths for Monte Carlo testing, 0 to only backtest the history")
+	c.Flags.StringVar(&c.MonteCarlo, "monte-carlo", trading.MONTE_CARLO_BOOTSTRAP, "How synthetic paths are resampled: bootstrap draws blocks of consecutive candle returns, shuffle-days reorders whole days")
+	c.Flags.IntVar(&c.BlockSize, "block-size", 12, "Candles per block when bootstrapping")
+	c.Flags.Float64Var(&c.RuinLevel, "ruin-level", 0.5, "A path is ruined when its NAV falls to this fraction of the starting balance")
+	c.Flags.Int64Var(&c.Seed, "seed", 1, "Random seed of the synthetic paths")
+	c.Flags.IntVar(&c.Workers, "workers", 0, "Number of paths to backtest in parallel. Defaults to the number of CPUs.")
 
 	return c.Flags
 }
 
-func (c *SimulateCommand) Validate() error {
-	if len(c.CurrencyPair) == 0 {
-		return fmt.Errorf("missing -currency-pair")
-	}
-
-	var err error
+func (c *SimulateCommand) Run(args []string) int {
+	c.InitFlags()
+	c.Flags.Parse(args)
 
-	c.StartTime, err = time.Parse(TIME_VAR_FORMAT, c.StartTimeVar)
+	backtest, err := c.Backtest()
 	if err != nil {
-		return err
+		log.Println(err)
+		return 1
 	}
 
-	c.EndTime, err = time.Parse(TIME_VAR_FORMAT, c.EndTimeVar)
+	err = c.JournalOptions.Validate()
 	if err != nil {
-		return err
+		log.Println(err)
+		return 1
 	}
+	backtest.Journal = c.Journal()
 
-	return nil
-}
-
-func (c *SimulateCommand) ForTimeRange(startTime, endTime time.Time, callback func(d *data.ChartData)) error {
-	db, err := data.OpenDB()
+	if c.MonteCarloPaths > 0 {
+		return c.runMonteCarlo(backtest)
+	}
 
+	result, err := backtest.Run()
 	if err != nil {
 		log.Println(err)
-		return err
+		return 1
 	}
 
-	defer db.Close()
+	result.WriteTable(os.Stdout)
 
-	return db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte("chart_data." + c.CurrencyPair))
+	err = c.WriteJournal(backtest.Journal, result)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
 
-		if b == nil {
-			return fmt.Errorf("unsupported currency pair: %s", c.CurrencyPair)
+	if len(c.JSONOutput) > 0 {
+		file, err := os.Create(c.JSONOutput)
+		if err != nil {
+			log.Println(err)
+			return 1
 		}
+		defer file.Close()
 
-		minTime := db.EncodeKey(startTime.Unix())
-		maxTime := db.EncodeKey(endTime.Unix())
-
-		cursor := b.Cursor()
-
-		for k, v := cursor.Seek(minTime); k != nil && bytes.Compare(k, maxTime) <= 0; k, v = cursor.Next() {
-			d := data.ChartData{}
-			db.DecodeValue(v, &d)
-			callback(&d)
+		err = result.WriteJSON(file)
+		if err != nil {
+			log.Println(err)
+			return 1
 		}
+	}
 
-		return nil
-	})
-}
-
-func (c *SimulateCommand) GetPrices(startTime, endTime time.Time) []float64 {
-	prices := []float64{}
-
-	c.ForTimeRange(startTime, endTime, func(d *data.ChartData) {
-		prices = append(prices, d.WeightedAverage)
-	})
-
-	return prices
+	return 0
 }
 
-func CalcPercentiles(prices []float64) map[int]float64 {
-	percentiles := make(map[int]float64)
-	count := len(prices)
-
-	sortedPrices := make([]float64, len(prices))
-	copy(sortedPrices, prices)
-	sort.Float64s(sortedPrices)
-
-	for i := 1; i <= 99; i += 1 {
-		percentiles[i] = sortedPrices[i*count/100]
+func (c *SimulateCommand) runMonteCarlo(backtest *trading.Backtest) int {
+	mc := &trading.MonteCarlo{
+		Backtest:  backtest,
+		Method:    c.MonteCarlo,
+		Paths:     c.MonteCarloPaths,
+		BlockSize: c.BlockSize,
+		RuinLevel: c.RuinLevel,
+		Seed:      c.Seed,
+		Workers:   c.Workers,
 	}
 
-	return percentiles
-}
+	report, err := mc.Run()
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
 
-func (c *SimulateCommand) Run(args []string) int {
-	c.InitFlags()
-	c.Flags.Parse(args)
+	report.WriteTable(os.Stdout)
 
-	err := c.Validate()
+	err = c.WriteJournal(backtest.Journal, report.Historical)
 	if err != nil {
 		log.Println(err)
 		return 1
 	}
 
-	c.BTC_Balance = 0.1
-	c.XRP_Balance = 0.00
-	c.PLX_Balance = 0.00
+	if len(c.JSONOutput) > 0 {
+		file, err := os.Create(c.JSONOutput)
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+		defer file.Close()
+
+		err = report.WriteJSON(file)
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+	}
 
-	c.BuyThreshold = 50
-	c.SellThreshold = PROFIT_FACTOR
+	return 0
+}
 
-	fmt.Printf("Starting Balances: BTC=%f XRP=%f PLX=%f\n", c.BTC_Balance, c.XRP_Balance, c.PLX_Balance)
+func (o *BacktestOptions) InitFlags(flags *flag.FlagSet) {
+	flags.StringVar(&o.CurrencyPair, "currency-pair", "", "PLX currency pair (e.g. BTC_XYZ or USDT_XYZ)")
+	o.InitSimulationFlags(flags)
+}
 
-	chartData := make([]*data.ChartData, 0, 8640)
-	c.ForTimeRange(c.StartTime, c.EndTime, func(d *data.ChartData) {
-		chartData = append(chartData, d)
-	})
+// InitSimulationFlags registers every flag except -currency-pair, for
+// backtests that are not of a single market.
+func (o *BacktestOptions) InitSimulationFlags(flags *flag.FlagSet) {
+	flags.StringVar(&o.Config, "config", "", "Trader config file (JSON) or name of a saved config. Defaults to the default trader config.")
+	flags.Int64Var(&o.Resolution, "resolution", data.BASE_RESOLUTION, "Resolution of chart data in seconds. Resolutions that are not stored are resampled from 300 second candles.")
+	flags.StringVar(&o.StartTimeVar, "start-time", "2017-05-01 00:00:00 CST", "Simulation start time. YYYY-MM-DD HH:MM:SS (TZ)")
+	flags.StringVar(&o.EndTimeVar, "end-time", "2017-06-21 00:00:00 CST", "Simulation end time. YYYY-MM-DD HH:MM:SS (TZ)")
+	flags.Float64Var(&o.StartingBalance, "starting-balance", 0.1, "Starting balance in the base currency")
+	flags.Float64Var(&o.MakerFee, "maker-fee", trading.SIMULATED_MAKER_FEE, "Fee charged on fills of orders that rested on the book, as a fraction of the total")
+	flags.Float64Var(&o.TakerFee, "taker-fee", trading.SIMULATED_TAKER_FEE, "Fee charged on fills of orders that crossed the book, as a fraction of the total")
+	flags.StringVar(&o.Fill, "fill", trading.FILL_CANDLE, "Fill model: candle fills limit orders when the candle's range reaches their price, immediate fills every order at its price as soon as it is placed")
+	flags.IntVar(&o.Latency, "latency", 0, "Candles an order takes to reach the book after the one it was placed in (candle fill model)")
+	flags.Float64Var(&o.Slippage, "slippage", 0, "Fraction of the price by which taker fills are worse than the candle open (candle fill model)")
+	flags.Float64Var(&o.Participation, "participation", 0, "Largest fraction of a candle's volume an order can fill in that candle, 0 for no limit (candle fill model)")
+}
 
-	fmt.Printf("found %d chart data items\n", len(chartData))
+func (o *BacktestOptions) Validate() error {
+	if o.Fill != trading.FILL_CANDLE && o.Fill != trading.FILL_IMMEDIATE {
+		return fmt.Errorf("unknown -fill model: %s", o.Fill)
+	}
 
-	var d *data.ChartData
-	var currentPrice float64
+	var err error
 
-	for _, d = range chartData {
-		currentPrice = d.WeightedAverage
+	o.StartTime, err = time.Parse(TIME_VAR_FORMAT, o.StartTimeVar)
+	if err != nil {
+		return err
+	}
 
-		// age the sell threshold in case it gets pumped up too high
-		if len(c.Bids) > 0 && c.SellThreshold > PROFIT_FACTOR {
-			c.SellThreshold -= 0.001
-		}
+	o.EndTime, err = time.Parse(TIME_VAR_FORMAT, o.EndTimeVar)
+	if err != nil {
+		return err
+	}
 
-		pStart := time.Unix(d.Date-WINDOW, 0)
-		pEnd := time.Unix(d.Date, 0)
-		prices := c.GetPrices(pStart, pEnd)
-		percentiles := CalcPercentiles(prices)
+	return nil
+}
 
-		if c.ShouldBuy(currentPrice, prices, percentiles) {
-			if c.Buy(currentPrice, d) {
-				fmt.Printf("BUY price=%f date=%v BTC=%f XRP=%f NAV=%f bt=%d st=%f\n", currentPrice, time.Unix(d.Date, 0), c.BTC_Balance, c.XRP_Balance, c.BTC_Balance+(currentPrice*c.XRP_Balance), c.BuyThreshold, c.SellThreshold)
-			}
-		}
+// Backtest validates the options and loads the trader config and chart data,
+// including one extra TimeWindow before the start time to warm up the trader.
+func (o *BacktestOptions) Backtest() (*trading.Backtest, error) {
+	if len(o.CurrencyPair) == 0 {
+		return nil, fmt.Errorf("missing -currency-pair")
+	}
 
-		if c.ShouldSell(currentPrice, prices, percentiles) {
-			if c.Sell(currentPrice, d, false) {
-				fmt.Printf("SELL price=%f date=%v BTC=%f XRP=%f NAV=%f bt=%d st=%f\n", currentPrice, time.Unix(d.Date, 0), c.BTC_Balance, c.XRP_Balance, c.BTC_Balance+(currentPrice*c.XRP_Balance), c.BuyThreshold, c.SellThreshold)
-			}
-		}
+	err := o.Validate()
+	if err != nil {
+		return nil, err
 	}
 
-	// Sell remaining XRP to exit position
-	if c.XRP_Balance > 0 {
-		c.Sell(currentPrice, d, true)
-		fmt.Printf("SELL price=%f date=%v BTC=%f XRP=%f NAV=%f bt=%d st=%f\n", currentPrice, time.Unix(d.Date, 0), c.BTC_Balance, c.XRP_Balance, c.BTC_Balance+(currentPrice*c.XRP_Balance), c.BuyThreshold, c.SellThreshold)
+	config, err := o.TraderConfig()
+	if err != nil {
+		return nil, err
 	}
 
-	fmt.Printf("Ending Balances: BTC=%f XRP=%f PLX=%f\n", c.BTC_Balance, c.XRP_Balance, c.PLX_Balance)
+	candles, err := loadBacktestCandles(o.CurrencyPair, o.Resolution, o.StartTime.Unix()-config.TimeWindow, o.EndTime.Unix())
+	if err != nil {
+		return nil, err
+	}
 
-	return 0
+	return &trading.Backtest{
+		Market:          o.CurrencyPair,
+		Config:          config,
+		Candles:         candles,
+		Resolution:      o.Resolution,
+		StartTime:       o.StartTime.Unix(),
+		EndTime:         o.EndTime.Unix(),
+		StartingBalance: o.StartingBalance,
+		Fees:            trading.FeeSchedule{Maker: o.MakerFee, Taker: o.TakerFee},
+		FillModel:       o.FillModel(),
+	}, nil
 }
 
-func (c *SimulateCommand) ShouldBuy(price float64, prices []float64, percentiles map[int]float64) bool {
-	return price < percentiles[c.BuyThreshold] && percentiles[55]/percentiles[45] > VOLATILITY
-}
+// TraderConfig loads the -config option, or the default trader config.
+func (o *BacktestOptions) TraderConfig() (*trading.TraderConfig, error) {
+	if len(o.Config) > 0 {
+		return loadTraderConfig(o.Config)
+	}
 
-func (c *SimulateCommand) ShouldSell(price float64, prices []float64, percentiles map[int]float64) bool {
-	return len(c.Bids) > 0 && price > c.Bids[len(c.Bids)-1]*c.SellThreshold
+	return trading.DefaultTraderConfig(), nil
 }
 
-func (c *SimulateCommand) Buy(price float64, d *data.ChartData) bool {
-	xrpAmount := BTC_BUY_AMOUNT / (price + (price * PLX_FEE))
-	btcAmount := xrpAmount * price
-	commission := btcAmount * PLX_FEE
+func (o *BacktestOptions) FillModel() trading.FillModel {
+	if o.Fill == trading.FILL_IMMEDIATE {
+		return &trading.ImmediateFill{}
+	}
 
-	if c.BTC_Balance-btcAmount-commission > 0 && d.Date-c.LastBuyTime > BUY_COOLOFF {
-		c.BTC_Balance -= btcAmount
-		c.XRP_Balance += xrpAmount
+	return &trading.CandleFill{Latency: o.Latency, Slippage: o.Slippage, Participation: o.Participation}
+}
 
-		c.BTC_Balance -= commission
-		c.PLX_Balance += commission
+func (o *JournalOptions) InitFlags(flags *flag.FlagSet) {
+	flags.StringVar(&o.JournalDir, "journal", "", "Write the orders, fills and daily NAV of the backtest to files in this directory")
+	flags.StringVar(&o.JournalFormat, "journal-format", data.FORMAT_CSV, "Format of the journal files. Choices: csv, jsonl")
+}
 
-		c.Bids = append(c.Bids, price)
-		c.LastBuyTime = d.Date
-		if c.BuyThreshold > 10 {
-			c.BuyThreshold -= 2
-		}
+func (o *JournalOptions) Validate() error {
+	if o.JournalFormat != data.FORMAT_CSV && o.JournalFormat != data.FORMAT_JSONL {
+		return fmt.Errorf("unknown -journal-format: %s", o.JournalFormat)
+	}
 
-		if c.SellThreshold > PROFIT_FACTOR {
-			c.SellThreshold -= 0.01
-		}
+	return nil
+}
 
-		return true
+// Journal returns nil unless -journal is set.
+func (o *JournalOptions) Journal() *trading.Journal {
+	if len(o.JournalDir) == 0 {
+		return nil
 	}
 
-	return false
+	return &trading.Journal{}
 }
 
-func (c *SimulateCommand) Sell(price float64, d *data.ChartData, force bool) bool {
-	xrpAmount := c.XRP_Balance * XRP_SELL_PCT
-
-	xrpAmount = math.Max(xrpAmount, XRP_SELL_AMOUNT)
+// WriteJournal writes orders.<format>, fills.<format> and nav.<format> to the
+// journal directory, creating it if needed.
+func (o *JournalOptions) WriteJournal(journal *trading.Journal, result *trading.BacktestResult) error {
+	if journal == nil {
+		return nil
+	}
 
-	// Enforce minimum XRP sell
-	if xrpAmount < XRP_SELL_AMOUNT {
-		return false
+	err := os.MkdirAll(o.JournalDir, 0755)
+	if err != nil {
+		return err
 	}
 
-	if force {
-		xrpAmount = c.XRP_Balance
+	write := func(name string, writeFile func(w io.Writer) error) error {
+		file, err := os.Create(filepath.Join(o.JournalDir, name+"."+o.JournalFormat))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		return writeFile(file)
 	}
 
-	btcAmount := xrpAmount * price
-	commission := btcAmount * PLX_FEE
+	err = write("orders", func(w io.Writer) error { return journal.WriteOrders(w, o.JournalFormat) })
+	if err != nil {
+		return err
+	}
 
-	if force || (c.XRP_Balance > 0 && c.XRP_Balance-xrpAmount >= 0 && d.Date-c.LastSellTime > SELL_COOLOFF) {
-		c.BTC_Balance += btcAmount
-		c.XRP_Balance -= xrpAmount
+	err = write("fills", func(w io.Writer) error { return trading.WriteFills(w, o.JournalFormat, result.Fills) })
+	if err != nil {
+		return err
+	}
 
-		c.BTC_Balance -= commission
-		c.PLX_Balance += commission
+	err = write("nav", func(w io.Writer) error { return trading.WriteDailyNAV(w, o.JournalFormat, result.Equity) })
+	if err != nil {
+		return err
+	}
 
-		if len(c.Bids) > 0 {
-			i := len(c.Bids) - 1
-			c.Bids = append(c.Bids[:i], c.Bids[i+1:]...)
-		}
-		c.LastSellTime = d.Date
+	log.Printf("wrote %d orders, %d fills and daily NAV to %s", len(journal.Orders), len(result.Fills), o.JournalDir)
 
-		if c.BuyThreshold < 50 {
-			c.BuyThreshold += 2
-		}
+	return nil
+}
 
-		c.SellThreshold += 0.01
+// loadBacktestCandles reads stored chart data as trading summary data.
+func loadBacktestCandles(marketName string, resolution, startTime, endTime int64) ([]*trading.SummaryData, error) {
+	periods, err := db.Default().ChartData().GetPeriods(marketName, resolution, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
 
-		return true
+	candles := make([]*trading.SummaryData, 0, len(periods))
+	for _, d := range periods {
+		candle := trading.SummaryData(*d)
+		candles = append(candles, &candle)
 	}
 
-	return false
+	return candles, nil
 }
@@ -0,0 +1,156 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"github.com/jbgo/sftbot/db"
+	"github.com/jbgo/sftbot/trading"
+	"log"
+	"math"
+	"os"
+)
+
+type SimulatePortfolioCommand struct {
+	Flags *flag.FlagSet
+	BacktestOptions
+	JournalOptions
+
+	JSONOutput string
+}
+
+func (c *SimulatePortfolioCommand) Synopsis() string {
+	return "simulate trading every stored BTC market from one wallet"
+}
+func (c *SimulatePortfolioCommand) Help() string {
+	return formatHelpText(`
+Usage: sftbot simulate portfolio [options]
+
+  Backtest the trader on the stored chart data of every BTC market at once,
+  sharing a single BTC balance. Like plx trade, each step only trades the
+  markets whose volume over the last 24 hours passes the volume filter,
+  largest first. Reports the metrics of the whole portfolio and the profit and
+  loss of each market. With -journal, also write the orders of every market,
+  the fills and the daily NAV of the portfolio to files in that directory.
+
+` + helpOptions(c))
+}
+
+func (c *SimulatePortfolioCommand) InitFlags() *flag.FlagSet {
+	c.Flags = flag.NewFlagSet("simulate portfolio", flag.PanicOnError)
+
+	c.BacktestOptions.InitSimulationFlags(c.Flags)
+	c.JournalOptions.InitFlags(c.Flags)
+	c.Flags.StringVar(&c.JSONOutput, "json", "", "Also write the results as JSON to this file")
+
+	// a portfolio spreads its balance over several markets
+	c.Flags.Lookup("starting-balance").DefValue = "1"
+	c.StartingBalance = 1.0
+
+	return c.Flags
+}
+
+func (c *SimulatePortfolioCommand) Run(args []string) int {
+	c.InitFlags()
+	c.Flags.Parse(args)
+
+	backtest, err := c.PortfolioBacktest()
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	result, err := backtest.Run()
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	result.WriteTable(os.Stdout)
+
+	err = c.WriteJournal(backtest.Journal, result.BacktestResult)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	if len(c.JSONOutput) > 0 {
+		file, err := os.Create(c.JSONOutput)
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+		defer file.Close()
+
+		err = result.WriteJSON(file)
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// PortfolioBacktest loads the chart data of every stored market plx trade
+// could trade, including enough data before the start time to warm up both
+// the trader and the volume filter.
+func (c *SimulatePortfolioCommand) PortfolioBacktest() (*trading.PortfolioBacktest, error) {
+	err := c.BacktestOptions.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.JournalOptions.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := c.TraderConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	summaries, err := db.Default().ChartData().ChartDataSummaries()
+	if err != nil {
+		return nil, err
+	}
+
+	warmUp := config.TimeWindow
+	if warmUp < trading.TICKER_VOLUME_WINDOW {
+		warmUp = trading.TICKER_VOLUME_WINDOW
+	}
+
+	markets := make(map[string][]*trading.SummaryData)
+
+	for _, summary := range summaries {
+		_, loaded := markets[summary.Market]
+		if loaded || !trading.ShouldTradeMarket(summary.Market, math.Inf(1)) {
+			continue
+		}
+
+		candles, err := loadBacktestCandles(summary.Market, c.Resolution, c.StartTime.Unix()-warmUp, c.EndTime.Unix())
+		if err != nil {
+			return nil, err
+		}
+
+		if len(candles) > 0 {
+			markets[summary.Market] = candles
+		}
+	}
+
+	if len(markets) == 0 {
+		return nil, fmt.Errorf("no chart data for BTC markets")
+	}
+
+	return &trading.PortfolioBacktest{
+		Config:          config,
+		Markets:         markets,
+		Resolution:      c.Resolution,
+		StartTime:       c.StartTime.Unix(),
+		EndTime:         c.EndTime.Unix(),
+		StartingBalance: c.StartingBalance,
+		Fees:            trading.FeeSchedule{Maker: c.MakerFee, Taker: c.TakerFee},
+		FillModel:       c.FillModel(),
+		Journal:         c.Journal(),
+	}, nil
+}
@@ -0,0 +1,128 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"github.com/jbgo/sftbot/data"
+	"github.com/jbgo/sftbot/db"
+	"log"
+	"time"
+)
+
+type TicksCandlesCommand struct {
+	Flags *flag.FlagSet
+
+	Market     string
+	Resolution int64
+	Store      bool
+
+	StartTimeVar string
+	StartTime    time.Time
+
+	EndTimeVar string
+	EndTime    time.Time
+}
+
+func (c *TicksCandlesCommand) Synopsis() string {
+	return "build candles of any resolution from stored ticks"
+}
+
+func (c *TicksCandlesCommand) Help() string {
+	return formatHelpText(`
+Usage: sftbot ticks candles [options]
+
+  Build OHLCV candles of any resolution from stored public trades. With
+  -store, the candles are written to the chart data of the market so they can
+  be used by "simulate" and "chart-data list".
+
+` + helpOptions(c))
+}
+
+func (c *TicksCandlesCommand) InitFlags() *flag.FlagSet {
+	c.Flags = flag.NewFlagSet("ticks candles", flag.ContinueOnError)
+
+	c.Flags.StringVar(&c.Market, "market", "", "PLX market as a currency pair (e.g. BTC_XYZ)")
+	c.Flags.Int64Var(&c.Resolution, "resolution", 60, "Resolution of the candles in seconds")
+	c.Flags.BoolVar(&c.Store, "store", false, "If true, store the candles instead of printing them")
+	c.Flags.StringVar(&c.StartTimeVar, "start-time", "", "Start of time range. YYYY-MM-DD HH:MM:SS (TZ)")
+	c.Flags.StringVar(&c.EndTimeVar, "end-time", "", "End of time range. YYYY-MM-DD HH:MM:SS (TZ)")
+
+	return c.Flags
+}
+
+func (c *TicksCandlesCommand) Validate() (err error) {
+	if len(c.Market) == 0 {
+		return fmt.Errorf("-market is required")
+	}
+
+	if c.Resolution <= 0 {
+		return fmt.Errorf("-resolution must be positive")
+	}
+
+	c.StartTime = time.Unix(0, 0)
+	c.EndTime = time.Now()
+
+	if len(c.StartTimeVar) > 0 {
+		c.StartTime, err = time.Parse(TIME_VAR_FORMAT, c.StartTimeVar)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(c.EndTimeVar) > 0 {
+		c.EndTime, err = time.Parse(TIME_VAR_FORMAT, c.EndTimeVar)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *TicksCandlesCommand) Run(args []string) int {
+	c.InitFlags()
+	c.Flags.Parse(args)
+
+	err := c.Validate()
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	store := db.Default()
+
+	ticks, err := store.Ticks().GetTicks(c.Market, c.StartTime.Unix(), c.EndTime.Unix())
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	candles := data.BuildCandles(ticks, c.Resolution)
+
+	if c.Store {
+		err = store.ChartData().WritePeriods(c.Market, c.Resolution, candles)
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+
+		log.Printf("stored %d candles from %d ticks in %s", len(candles), len(ticks), db.ChartDataBucket(c.Market, c.Resolution))
+
+		return 0
+	}
+
+	for _, d := range candles {
+		fmt.Printf("%s [%s] vol=%0.9f wavg=%0.9f open=%0.9f close=%0.9f high=%0.9f low=%0.9f qvol=%0.9f\n",
+			c.Market,
+			time.Unix(d.Date, 0).Format(TIME_VAR_FORMAT),
+			d.Volume,
+			d.WeightedAverage,
+			d.Open,
+			d.Close,
+			d.High,
+			d.Low,
+			d.QuoteVolume)
+	}
+
+	return 0
+}
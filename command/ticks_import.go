@@ -0,0 +1,145 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"github.com/jbgo/sftbot/data"
+	"github.com/jbgo/sftbot/db"
+	"github.com/jbgo/sftbot/plx"
+	"log"
+	"time"
+)
+
+const TICK_IMPORT_WINDOW = 60 * 60
+
+type TicksImportCommand struct {
+	Flags *flag.FlagSet
+
+	Market string
+	Days   int64
+}
+
+func (c *TicksImportCommand) Synopsis() string {
+	return "import public trades (ticks) for a market"
+}
+
+func (c *TicksImportCommand) Help() string {
+	return formatHelpText(`
+Usage: sftbot ticks import [options]
+
+  Import the public trade history of a market. Each run resumes from the
+  newest stored trade.
+
+` + helpOptions(c))
+}
+
+func (c *TicksImportCommand) InitFlags() *flag.FlagSet {
+	c.Flags = flag.NewFlagSet("ticks import", flag.ContinueOnError)
+
+	c.Flags.StringVar(&c.Market, "market", "", "PLX market as a currency pair (e.g. BTC_XYZ)")
+	c.Flags.Int64Var(&c.Days, "days", 1, "The number of days worth of trades to import.")
+
+	return c.Flags
+}
+
+func (c *TicksImportCommand) Run(args []string) int {
+	c.InitFlags()
+	c.Flags.Parse(args)
+
+	if len(c.Market) == 0 {
+		fmt.Println(c.Help())
+		return 1
+	}
+
+	endTime := time.Now().Unix()
+	startTime := endTime - c.Days*SECONDS_PER_DAY
+
+	last, err := c.LastTick()
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	if last != nil && last.Date > startTime {
+		startTime = last.Date
+	}
+
+	client := plx.NewLiveClient()
+
+	for windowStart := startTime; windowStart < endTime; windowStart += TICK_IMPORT_WINDOW {
+		windowEnd := windowStart + TICK_IMPORT_WINDOW
+		if windowEnd > endTime {
+			windowEnd = endTime
+		}
+
+		log.Printf("loading trades for %v - %v", time.Unix(windowStart, 0), time.Unix(windowEnd, 0))
+
+		err = c.ImportWindow(client, windowStart, windowEnd)
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func (c *TicksImportCommand) LastTick() (*data.Tick, error) {
+	return db.Default().Ticks().LastTick(c.Market)
+}
+
+// ImportWindow pages backwards through the window whenever the API returns
+// as many trades as it allows in a single response.
+func (c *TicksImportCommand) ImportWindow(client *plx.Client, startTime, endTime int64) error {
+	params := &plx.TradeHistoryParams{Market: c.Market, StartTime: startTime, EndTime: endTime}
+
+	for {
+		trades, err := client.GetTradeHistory(params)
+		if err != nil {
+			return err
+		}
+
+		ticks := make([]*data.Tick, 0, len(trades))
+		oldest := params.EndTime
+
+		for _, t := range trades {
+			tick := &data.Tick{
+				GlobalTradeId: t.GlobalTradeId,
+				TradeId:       t.TradeId,
+				Date:          t.Date.Unix(),
+				Type:          t.Type,
+				Rate:          t.Rate,
+				Amount:        t.Amount,
+				Total:         t.Total,
+			}
+
+			if tick.Date < oldest {
+				oldest = tick.Date
+			}
+
+			ticks = append(ticks, tick)
+		}
+
+		err = c.WriteTicks(ticks)
+		if err != nil {
+			return err
+		}
+
+		// avoid public API rate limits
+		time.Sleep(250 * time.Millisecond)
+
+		if len(trades) < plx.PUBLIC_TRADE_HISTORY_LIMIT {
+			return nil
+		}
+
+		if oldest >= params.EndTime {
+			return fmt.Errorf("more than %d trades at %d, cannot page further", plx.PUBLIC_TRADE_HISTORY_LIMIT, params.EndTime)
+		}
+
+		params.EndTime = oldest
+	}
+}
+
+func (c *TicksImportCommand) WriteTicks(ticks []*data.Tick) error {
+	return db.Default().Ticks().WriteTicks(c.Market, ticks)
+}
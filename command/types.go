@@ -2,6 +2,7 @@ package command
 
 import (
 	"github.com/jbgo/sftbot/plx"
+	"github.com/jbgo/sftbot/trading"
 )
 
 type ByVolumeDesc []plx.TickerEntry
@@ -9,3 +10,9 @@ type ByVolumeDesc []plx.TickerEntry
 func (a ByVolumeDesc) Len() int           { return len(a) }
 func (a ByVolumeDesc) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a ByVolumeDesc) Less(i, j int) bool { return a[i].BaseVolume > a[j].BaseVolume }
+
+type ByBaseVolumeDesc []*trading.TickerEntry
+
+func (a ByBaseVolumeDesc) Len() int           { return len(a) }
+func (a ByBaseVolumeDesc) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a ByBaseVolumeDesc) Less(i, j int) bool { return a[i].BaseVolume > a[j].BaseVolume }
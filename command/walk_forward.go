@@ -0,0 +1,72 @@
+package command
+
+import (
+	"flag"
+	"github.com/jbgo/sftbot/trading"
+	"log"
+	"os"
+)
+
+type WalkForwardCommand struct {
+	Flags *flag.FlagSet
+	BacktestOptions
+	OptimizeOptions
+
+	InSampleDays    float64
+	OutOfSampleDays float64
+}
+
+func (c *WalkForwardCommand) Synopsis() string {
+	return "validate optimised trader configs on data they were not tuned on"
+}
+
+func (c *WalkForwardCommand) Help() string {
+	return formatHelpText(`
+Usage: sftbot walk-forward [options]
+
+  Split the date range into rolling windows. On each in-sample slice, grid
+  search the -param ranges like optimize does, then backtest the best config
+  on the out-of-sample slice that follows. Reports the out-of-sample results
+  stitched together and how much the chosen parameters moved between
+  windows.
+
+` + helpOptions(c))
+}
+
+func (c *WalkForwardCommand) InitFlags() *flag.FlagSet {
+	c.Flags = flag.NewFlagSet("walk-forward", flag.ContinueOnError)
+
+	c.BacktestOptions.InitFlags(c.Flags)
+	c.OptimizeOptions.InitFlags(c.Flags)
+	c.Flags.Float64Var(&c.InSampleDays, "in-sample-days", 28, "Length of each in-sample slice in days")
+	c.Flags.Float64Var(&c.OutOfSampleDays, "out-of-sample-days", 7, "Length of each out-of-sample slice in days, which is also how far the windows move")
+
+	return c.Flags
+}
+
+func (c *WalkForwardCommand) Run(args []string) int {
+	c.InitFlags()
+	c.Flags.Parse(args)
+
+	optimizer, err := c.Optimizer(&c.BacktestOptions)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	walkForward := &trading.WalkForward{
+		Optimizer:   optimizer,
+		InSample:    int64(c.InSampleDays * 24 * 60 * 60),
+		OutOfSample: int64(c.OutOfSampleDays * 24 * 60 * 60),
+	}
+
+	report, err := walkForward.Run()
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	report.WriteTable(os.Stdout)
+
+	return 0
+}
@@ -0,0 +1,259 @@
+package data
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const FORMAT_CSV = "csv"
+const FORMAT_JSONL = "jsonl"
+
+var CSV_COLUMNS = []string{
+	"market", "resolution", "date", "open", "high", "low", "close",
+	"volume", "quote_volume", "weighted_average",
+}
+
+// A candle together with the series it belongs to, as stored in data files.
+type CandleRecord struct {
+	Market     string
+	Resolution int64
+	ChartData
+}
+
+// Validate checks that a candle is internally consistent.
+func (d *ChartData) Validate() error {
+	if d.Date <= 0 {
+		return fmt.Errorf("invalid date: %d", d.Date)
+	}
+
+	if d.Open <= 0 || d.Close <= 0 || d.High <= 0 || d.Low <= 0 {
+		return fmt.Errorf("prices must be positive: open=%v high=%v low=%v close=%v", d.Open, d.High, d.Low, d.Close)
+	}
+
+	if d.High < d.Low {
+		return fmt.Errorf("high %v is below low %v", d.High, d.Low)
+	}
+
+	if d.Open > d.High || d.Open < d.Low || d.Close > d.High || d.Close < d.Low {
+		return fmt.Errorf("open %v and close %v must be between low %v and high %v", d.Open, d.Close, d.Low, d.High)
+	}
+
+	if d.Volume < 0 || d.QuoteVolume < 0 {
+		return fmt.Errorf("volume must not be negative: volume=%v quote_volume=%v", d.Volume, d.QuoteVolume)
+	}
+
+	if d.Volume > 0 && (d.WeightedAverage > d.High || d.WeightedAverage < d.Low) {
+		return fmt.Errorf("weighted average %v must be between low %v and high %v", d.WeightedAverage, d.Low, d.High)
+	}
+
+	return nil
+}
+
+// FileFormat guesses the format of a data file from its extension.
+func FileFormat(path string) (string, error) {
+	switch {
+	case strings.HasSuffix(path, ".csv"):
+		return FORMAT_CSV, nil
+	case strings.HasSuffix(path, ".jsonl"), strings.HasSuffix(path, ".json"):
+		return FORMAT_JSONL, nil
+	}
+
+	return "", fmt.Errorf("cannot tell the format of %s, use .csv or .jsonl", path)
+}
+
+type CandleWriter interface {
+	Write(record *CandleRecord) error
+	Flush() error
+}
+
+func NewCandleWriter(w io.Writer, format string) (CandleWriter, error) {
+	switch format {
+	case FORMAT_CSV:
+		writer := &csvCandleWriter{out: csv.NewWriter(w)}
+		return writer, writer.out.Write(CSV_COLUMNS)
+	case FORMAT_JSONL:
+		return &jsonlCandleWriter{out: bufio.NewWriter(w)}, nil
+	}
+
+	return nil, fmt.Errorf("unknown format: %s", format)
+}
+
+type csvCandleWriter struct {
+	out *csv.Writer
+}
+
+func (writer *csvCandleWriter) Write(record *CandleRecord) error {
+	formatFloat := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+
+	return writer.out.Write([]string{
+		record.Market,
+		strconv.FormatInt(record.Resolution, 10),
+		strconv.FormatInt(record.Date, 10),
+		formatFloat(record.Open),
+		formatFloat(record.High),
+		formatFloat(record.Low),
+		formatFloat(record.Close),
+		formatFloat(record.Volume),
+		formatFloat(record.QuoteVolume),
+		formatFloat(record.WeightedAverage),
+	})
+}
+
+func (writer *csvCandleWriter) Flush() error {
+	writer.out.Flush()
+	return writer.out.Error()
+}
+
+type jsonlCandleWriter struct {
+	out *bufio.Writer
+}
+
+func (writer *jsonlCandleWriter) Write(record *CandleRecord) error {
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.out.Write(append(encoded, '\n'))
+
+	return err
+}
+
+func (writer *jsonlCandleWriter) Flush() error {
+	return writer.out.Flush()
+}
+
+// ReadCandles calls callback for every record in r. CSV files must start with
+// a header row naming the CSV_COLUMNS they contain; the market and resolution
+// columns are optional. line is the line number of the record, for reporting.
+// Records that cannot be parsed are passed to callback with a non-nil error.
+func ReadCandles(r io.Reader, format string, callback func(line int, record *CandleRecord, err error) error) error {
+	switch format {
+	case FORMAT_CSV:
+		return readCandlesCSV(r, callback)
+	case FORMAT_JSONL:
+		return readCandlesJSONL(r, callback)
+	}
+
+	return fmt.Errorf("unknown format: %s", format)
+}
+
+func readCandlesCSV(r io.Reader, callback func(int, *CandleRecord, error) error) error {
+	in := csv.NewReader(r)
+	in.FieldsPerRecord = -1
+
+	header, err := in.Read()
+	if err != nil {
+		return err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.ToLower(name))] = i
+	}
+
+	for _, name := range CSV_COLUMNS[2:] {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("missing CSV column: %s", name)
+		}
+	}
+
+	line := 1
+
+	for {
+		row, err := in.Read()
+		line += 1
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		record, err := parseCSVRecord(columns, row)
+
+		err = callback(line, record, err)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func parseCSVRecord(columns map[string]int, row []string) (*CandleRecord, error) {
+	var err error
+
+	field := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	parseFloat := func(name string) float64 {
+		f, parseErr := strconv.ParseFloat(field(name), 64)
+		if parseErr != nil && err == nil {
+			err = fmt.Errorf("invalid %s: %q", name, field(name))
+		}
+		return f
+	}
+
+	record := &CandleRecord{Market: field("market")}
+
+	if len(field("resolution")) > 0 {
+		record.Resolution, err = strconv.ParseInt(field("resolution"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resolution: %q", field("resolution"))
+		}
+	}
+
+	record.Date, err = strconv.ParseInt(field("date"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date: %q", field("date"))
+	}
+
+	record.Open = parseFloat("open")
+	record.High = parseFloat("high")
+	record.Low = parseFloat("low")
+	record.Close = parseFloat("close")
+	record.Volume = parseFloat("volume")
+	record.QuoteVolume = parseFloat("quote_volume")
+	record.WeightedAverage = parseFloat("weighted_average")
+
+	return record, err
+}
+
+func readCandlesJSONL(r io.Reader, callback func(int, *CandleRecord, error) error) error {
+	scanner := bufio.NewScanner(r)
+	line := 0
+
+	for scanner.Scan() {
+		line += 1
+
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 {
+			continue
+		}
+
+		record := &CandleRecord{}
+		err := json.Unmarshal([]byte(text), record)
+
+		if err != nil {
+			record = nil
+		}
+
+		err = callback(line, record, err)
+		if err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,95 @@
+package data
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestChartDataValidate(t *testing.T) {
+	valid := ChartData{Date: 300, Open: 1.0, High: 2.0, Low: 0.5, Close: 1.5, Volume: 3.0, QuoteVolume: 2.0, WeightedAverage: 1.5}
+	assert.Nil(t, valid.Validate())
+
+	invalid := map[string]func(d *ChartData){
+		"zero date":        func(d *ChartData) { d.Date = 0 },
+		"negative price":   func(d *ChartData) { d.Low = -1.0 },
+		"high below low":   func(d *ChartData) { d.High = 0.4 },
+		"close above high": func(d *ChartData) { d.Close = 2.1 },
+		"negative volume":  func(d *ChartData) { d.Volume = -1.0 },
+		"average too high": func(d *ChartData) { d.WeightedAverage = 2.5 },
+	}
+
+	for name, mutate := range invalid {
+		d := valid
+		mutate(&d)
+		assert.NotNil(t, d.Validate(), name)
+	}
+}
+
+func TestCandleFiles(t *testing.T) {
+	records := []*CandleRecord{
+		&CandleRecord{"BTC_ABC", 300, ChartData{Date: 300, Open: 1.0, High: 2.0, Low: 0.5, Close: 1.5, Volume: 3.0, QuoteVolume: 2.0, WeightedAverage: 1.5}},
+		&CandleRecord{"USDT_BTC", 900, ChartData{Date: 900, Open: 0.00001, High: 0.00002, Low: 0.00001, Close: 0.00002}},
+	}
+
+	for _, format := range []string{FORMAT_CSV, FORMAT_JSONL} {
+		buf := &bytes.Buffer{}
+
+		writer, err := NewCandleWriter(buf, format)
+		require.Nil(t, err)
+
+		for _, r := range records {
+			require.Nil(t, writer.Write(r))
+		}
+
+		require.Nil(t, writer.Flush())
+
+		read := make([]*CandleRecord, 0)
+		err = ReadCandles(buf, format, func(line int, record *CandleRecord, err error) error {
+			require.Nil(t, err)
+			read = append(read, record)
+			return nil
+		})
+
+		require.Nil(t, err, format)
+		assert.Equal(t, records, read, format)
+	}
+
+	t.Run("CSV without market columns", func(t *testing.T) {
+		in := strings.NewReader("date,open,high,low,close,volume,quote_volume,weighted_average\n" +
+			"300,1,2,0.5,1.5,3,2,1.5\n" +
+			"600,1,2,0.5,abc,3,2,1.5\n")
+
+		lines := make([]int, 0)
+		errors := 0
+
+		err := ReadCandles(in, FORMAT_CSV, func(line int, record *CandleRecord, err error) error {
+			lines = append(lines, line)
+			if err != nil {
+				errors += 1
+			}
+			return nil
+		})
+
+		require.Nil(t, err)
+		assert.Equal(t, []int{2, 3}, lines)
+		assert.Equal(t, 1, errors)
+	})
+
+	t.Run("CSV missing columns", func(t *testing.T) {
+		err := ReadCandles(strings.NewReader("date,open\n"), FORMAT_CSV, nil)
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "missing CSV column")
+	})
+
+	t.Run("FileFormat", func(t *testing.T) {
+		format, err := FileFormat("candles.jsonl")
+		require.Nil(t, err)
+		assert.Equal(t, FORMAT_JSONL, format)
+
+		_, err = FileFormat("candles.xls")
+		require.NotNil(t, err)
+	})
+}
@@ -0,0 +1,256 @@
+package data
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an amount of money, or a price, as a whole number of satoshis
+// (0.00000001). Every currency sftbot trades is handled with this precision,
+// which is what Poloniex accepts, so sums and differences are exact and
+// balances reconcile with the exchange to the satoshi.
+type Decimal int64
+
+const DECIMAL_PLACES = 8
+const SATOSHI Decimal = 1
+const ONE Decimal = 100000000
+
+// Products of values below this fit in an int64, so they need no big.Int.
+const maxSafeFactor = 3000000000
+
+// NewDecimal rounds f to the nearest satoshi.
+func NewDecimal(f float64) Decimal {
+	if f < 0 {
+		return -Decimal(-f*float64(ONE) + 0.5)
+	}
+
+	return Decimal(f*float64(ONE) + 0.5)
+}
+
+// ParseDecimal reads a decimal number such as "0.00012000" exactly. Numbers
+// with more than eight decimals, such as float64 values written by older
+// versions, are rounded to the nearest satoshi like NewDecimal. Numbers in
+// exponent form, which is how encoding/json writes small float64 values, are
+// read as float64.
+func ParseDecimal(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, err
+		}
+
+		return NewDecimal(f), nil
+	}
+
+	negative := strings.HasPrefix(s, "-")
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, fraction := digits, ""
+	if i := strings.Index(digits, "."); i >= 0 {
+		whole, fraction = digits[:i], digits[i+1:]
+	}
+
+	if len(whole) == 0 && len(fraction) == 0 {
+		return 0, fmt.Errorf("invalid decimal: %q", s)
+	}
+
+	rest := ""
+	if len(fraction) > DECIMAL_PLACES {
+		fraction, rest = fraction[:DECIMAL_PLACES], fraction[DECIMAL_PLACES:]
+	}
+
+	fraction += strings.Repeat("0", DECIMAL_PLACES-len(fraction))
+
+	value, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil || strings.ContainsAny(whole+fraction, "+-") || strings.Trim(rest, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid decimal: %q", s)
+	}
+
+	if len(rest) > 0 && rest[0] >= '5' {
+		value += 1
+	}
+
+	if negative {
+		value = -value
+	}
+
+	return Decimal(value), nil
+}
+
+func (d Decimal) Float64() float64 {
+	return float64(d) / float64(ONE)
+}
+
+// String writes d without trailing zeros, e.g. "0.3" or "12".
+func (d Decimal) String() string {
+	s := d.Format()
+
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+
+	return s
+}
+
+// Format writes d with all 8 decimals, e.g. "0.30000000".
+func (d Decimal) Format() string {
+	sign := ""
+	value := int64(d)
+
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	return fmt.Sprintf("%s%d.%08d", sign, value/int64(ONE), value%int64(ONE))
+}
+
+// Mul returns d * o rounded to the nearest satoshi, e.g. the total of an order
+// from its price and amount.
+func (d Decimal) Mul(o Decimal) Decimal {
+	if abs(d) < maxSafeFactor && abs(o) < maxSafeFactor {
+		return divRound(int64(d)*int64(o), int64(ONE))
+	}
+
+	product := new(big.Int).Mul(big.NewInt(int64(d)), big.NewInt(int64(o)))
+	return bigDivRound(product, big.NewInt(int64(ONE)))
+}
+
+// MulFloor returns d * o rounded down to the satoshi, as exchanges compute the
+// total of an order.
+func (d Decimal) MulFloor(o Decimal) Decimal {
+	product := new(big.Int).Mul(big.NewInt(int64(d)), big.NewInt(int64(o)))
+	return Decimal(product.Div(product, big.NewInt(int64(ONE))).Int64())
+}
+
+// Div returns d / o rounded to the nearest satoshi, e.g. the amount an order
+// can buy from its total and price.
+func (d Decimal) Div(o Decimal) Decimal {
+	if o == 0 {
+		panic("data.Decimal: division by zero")
+	}
+
+	if abs(d) < maxSafeFactor/10 {
+		return divRound(int64(d)*int64(ONE), int64(o))
+	}
+
+	scaled := new(big.Int).Mul(big.NewInt(int64(d)), big.NewInt(int64(ONE)))
+	return bigDivRound(scaled, big.NewInt(int64(o)))
+}
+
+// MulDiv returns d * n / m rounded to the nearest satoshi, without rounding
+// the product first, e.g. the share of a cost that a part of a lot carries.
+func (d Decimal) MulDiv(n, m Decimal) Decimal {
+	if m == 0 {
+		panic("data.Decimal: division by zero")
+	}
+
+	product := new(big.Int).Mul(big.NewInt(int64(d)), big.NewInt(int64(n)))
+	return bigDivRound(product, big.NewInt(int64(m)))
+}
+
+// MulFloat returns d * f rounded to the nearest satoshi. It is for rates and
+// ratios, such as fees, that are not amounts of money themselves.
+func (d Decimal) MulFloat(f float64) Decimal {
+	return NewDecimal(d.Float64() * f)
+}
+
+// Floor rounds d down to the given number of decimals.
+func (d Decimal) Floor(places int) Decimal {
+	unit := decimalUnit(places)
+	remainder := d % unit
+
+	if remainder < 0 {
+		remainder += unit
+	}
+
+	return d - remainder
+}
+
+// Ceil rounds d up to the given number of decimals.
+func (d Decimal) Ceil(places int) Decimal {
+	floor := d.Floor(places)
+
+	if floor == d {
+		return d
+	}
+
+	return floor + decimalUnit(places)
+}
+
+// MarshalJSON writes d as a JSON number with no more decimals than needed.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON reads a JSON number, or a number in a string as the Poloniex
+// API sends them.
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	text := string(bytes.Trim(b, `"`))
+
+	if text == "null" || len(text) == 0 {
+		*d = 0
+		return nil
+	}
+
+	value, err := ParseDecimal(text)
+	if err != nil {
+		return err
+	}
+
+	*d = value
+
+	return nil
+}
+
+func abs(d Decimal) Decimal {
+	if d < 0 {
+		return -d
+	}
+
+	return d
+}
+
+func decimalUnit(places int) Decimal {
+	if places >= DECIMAL_PLACES {
+		return SATOSHI
+	}
+
+	return Decimal(math.Pow10(DECIMAL_PLACES - places))
+}
+
+// divRound divides, rounding halves away from zero.
+func divRound(n, d int64) Decimal {
+	if d < 0 {
+		n, d = -n, -d
+	}
+
+	if n < 0 {
+		return -Decimal((-n + d/2) / d)
+	}
+
+	return Decimal((n + d/2) / d)
+}
+
+func bigDivRound(n, d *big.Int) Decimal {
+	if d.Sign() < 0 {
+		n, d = new(big.Int).Neg(n), new(big.Int).Neg(d)
+	}
+
+	half := new(big.Int).Quo(d, big.NewInt(2))
+	quotient := new(big.Int)
+
+	if n.Sign() < 0 {
+		quotient.Quo(new(big.Int).Sub(n, half), d)
+	} else {
+		quotient.Quo(new(big.Int).Add(n, half), d)
+	}
+
+	return Decimal(quotient.Int64())
+}
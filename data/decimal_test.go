@@ -0,0 +1,119 @@
+package data
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDecimal(t *testing.T) {
+	t.Run("NewDecimal rounds to the satoshi", func(t *testing.T) {
+		assert.Equal(t, Decimal(30000000), NewDecimal(0.1+0.2))
+		assert.Equal(t, Decimal(29000000), NewDecimal(0.29))
+		assert.Equal(t, Decimal(-12345679), NewDecimal(-0.123456789))
+		assert.Equal(t, 0.3, NewDecimal(0.1+0.2).Float64())
+	})
+
+	t.Run("ParseDecimal", func(t *testing.T) {
+		for text, expected := range map[string]Decimal{
+			"0.00012000":   12000,
+			"12":           12 * ONE,
+			".5":           ONE / 2,
+			"-1.5":         -3 * ONE / 2,
+			"0.123456789":  12345679,
+			"0.123456784":  12345678,
+			"-0.123456789": -12345679,
+			"0.999999995":  ONE,
+			// float64 values written by versions before Decimal
+			"0.049749999999999996": NewDecimal(0.04975),
+			"1e-07":                10,
+			"92233720368":          92233720368 * ONE,
+			" 0.00000001 ":         SATOSHI,
+		} {
+			d, err := ParseDecimal(text)
+			require.Nil(t, err, text)
+			assert.Equal(t, expected, d, text)
+		}
+
+		for _, text := range []string{"", ".", "abc", "1.2.3", "--1", "1-2", "0.123456789x"} {
+			_, err := ParseDecimal(text)
+			assert.NotNil(t, err, text)
+		}
+	})
+
+	t.Run("String and Format", func(t *testing.T) {
+		d := NewDecimal(0.1) + NewDecimal(0.2)
+		assert.Equal(t, "0.3", d.String())
+		assert.Equal(t, "0.30000000", d.Format())
+		assert.Equal(t, "12", (12 * ONE).String())
+		assert.Equal(t, "-0.00000001", (-SATOSHI).String())
+		assert.Equal(t, "0", Decimal(0).String())
+	})
+
+	t.Run("Mul and Div round to the nearest satoshi", func(t *testing.T) {
+		price := NewDecimal(0.00390040)
+		amount := NewDecimal(3.20479849)
+
+		assert.Equal(t, NewDecimal(0.0125), price.Mul(amount))
+		assert.Equal(t, NewDecimal(3.20479951), NewDecimal(0.0125).Div(price))
+		assert.Equal(t, Decimal(-2), Decimal(-15).Mul(ONE/10))
+
+		// products that overflow int64
+		btcPrice := NewDecimal(7000)
+		assert.Equal(t, NewDecimal(70000), btcPrice.Mul(NewDecimal(10)))
+		assert.Equal(t, NewDecimal(10), NewDecimal(70000).Div(btcPrice))
+
+		assert.Equal(t, NewDecimal(0.0025), NewDecimal(1).MulFloat(0.0025))
+
+		assert.Equal(t, Decimal(1249999), price.MulFloor(amount))
+		assert.Equal(t, NewDecimal(0.00009999), NewDecimal(0.01).MulFloor(NewDecimal(0.00999999)))
+		assert.Equal(t, Decimal(-2), Decimal(-15).MulFloor(ONE/10))
+
+		// a third of 0.0001 BTC for one satoshi out of three
+		assert.Equal(t, Decimal(3333), NewDecimal(0.0001).MulDiv(SATOSHI, 3))
+		assert.Equal(t, Decimal(0), NewDecimal(0.0001).Mul(SATOSHI).Div(3))
+	})
+
+	t.Run("Floor and Ceil", func(t *testing.T) {
+		d := NewDecimal(1.23456789)
+		assert.Equal(t, NewDecimal(1.2345), d.Floor(4))
+		assert.Equal(t, NewDecimal(1.2346), d.Ceil(4))
+		assert.Equal(t, d, d.Floor(8))
+		assert.Equal(t, NewDecimal(-1.2346), (-d).Floor(4))
+		assert.Equal(t, NewDecimal(1.2), NewDecimal(1.2).Ceil(1))
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var value struct {
+			Number Decimal
+			String Decimal
+			Float  Decimal
+		}
+
+		err := json.Unmarshal([]byte(`{"Number":0.3,"String":"0.00012000","Float":1e-07}`), &value)
+		require.Nil(t, err)
+		assert.Equal(t, NewDecimal(0.3), value.Number)
+		assert.Equal(t, Decimal(12000), value.String)
+		assert.Equal(t, Decimal(10), value.Float)
+
+		out, err := json.Marshal(value)
+		require.Nil(t, err)
+		assert.Equal(t, `{"Number":0.3,"String":0.00012,"Float":0.0000001}`, string(out))
+	})
+
+	t.Run("legacy float JSON", func(t *testing.T) {
+		// a trade stored as float64 before amounts were Decimal
+		var trade struct {
+			Price  Decimal
+			Amount Decimal
+			Total  Decimal
+		}
+
+		err := json.Unmarshal([]byte(`{"Price":0.049749999999999996,"Amount":12.999999999999998,"Total":0.6467249999999999}`), &trade)
+		require.Nil(t, err)
+		assert.Equal(t, NewDecimal(0.04975), trade.Price)
+		assert.Equal(t, NewDecimal(13), trade.Amount)
+		assert.Equal(t, NewDecimal(0.646725), trade.Total)
+	})
+}
@@ -0,0 +1,52 @@
+package data
+
+// A range of missing candles. Start is the first missing period and End is
+// the last one, so a single missing candle has Start == End.
+type Gap struct {
+	Start int64
+	End   int64
+}
+
+func (gap Gap) Periods(resolution int64) int64 {
+	return (gap.End-gap.Start)/resolution + 1
+}
+
+// FindGaps returns the ranges between startTime and endTime (inclusive) that
+// are not covered by dates, which must be sorted in ascending order.
+func FindGaps(dates []int64, resolution, startTime, endTime int64) []Gap {
+	gaps := make([]Gap, 0)
+	expected := alignTime(startTime, resolution)
+
+	for _, date := range dates {
+		if date < expected {
+			continue
+		}
+
+		if date > endTime {
+			break
+		}
+
+		if date-resolution >= expected {
+			gaps = append(gaps, Gap{Start: expected, End: date - resolution})
+		}
+
+		expected = date + resolution
+	}
+
+	last := endTime - endTime%resolution
+
+	if expected <= last {
+		gaps = append(gaps, Gap{Start: expected, End: last})
+	}
+
+	return gaps
+}
+
+// alignTime rounds t up to the next multiple of resolution.
+func alignTime(t, resolution int64) int64 {
+	if t%resolution == 0 {
+		return t
+	}
+
+	return t - t%resolution + resolution
+}
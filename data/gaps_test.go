@@ -0,0 +1,30 @@
+package data
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFindGaps(t *testing.T) {
+	t.Run("no data", func(t *testing.T) {
+		gaps := FindGaps([]int64{}, 300, 0, 900)
+		assert.Equal(t, []Gap{Gap{0, 900}}, gaps)
+		assert.Equal(t, int64(4), gaps[0].Periods(300))
+	})
+
+	t.Run("complete", func(t *testing.T) {
+		gaps := FindGaps([]int64{300, 600, 900}, 300, 250, 1000)
+		assert.Equal(t, []Gap{}, gaps)
+	})
+
+	t.Run("leading, inner and trailing gaps", func(t *testing.T) {
+		dates := []int64{0, 600, 900, 1800, 2100}
+		gaps := FindGaps(dates, 300, 300, 3000)
+
+		assert.Equal(t, []Gap{
+			Gap{300, 300},
+			Gap{1200, 1500},
+			Gap{2400, 3000},
+		}, gaps)
+	})
+}
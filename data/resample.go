@@ -0,0 +1,64 @@
+package data
+
+// Resample aggregates candles, which must be sorted by date, into candles of
+// a higher resolution. Each resampled candle starts at a multiple of
+// resolution. The weighted average is weighted by quote volume, falling back
+// to a plain average for periods without any trades.
+func Resample(candles []*ChartData, resolution int64) []*ChartData {
+	resampled := make([]*ChartData, 0)
+
+	var current *ChartData
+	var weightedSum, averageSum float64
+	var count int
+
+	closeCurrent := func() {
+		if current == nil {
+			return
+		}
+
+		if current.QuoteVolume > 0 {
+			current.WeightedAverage = weightedSum / current.QuoteVolume
+		} else {
+			current.WeightedAverage = averageSum / float64(count)
+		}
+
+		resampled = append(resampled, current)
+	}
+
+	for _, c := range candles {
+		date := c.Date - c.Date%resolution
+
+		if current == nil || current.Date != date {
+			closeCurrent()
+
+			current = &ChartData{
+				Date: date,
+				High: c.High,
+				Low:  c.Low,
+				Open: c.Open,
+			}
+
+			weightedSum, averageSum, count = 0.0, 0.0, 0
+		}
+
+		if c.High > current.High {
+			current.High = c.High
+		}
+
+		if c.Low < current.Low {
+			current.Low = c.Low
+		}
+
+		current.Close = c.Close
+		current.Volume += c.Volume
+		current.QuoteVolume += c.QuoteVolume
+
+		weightedSum += c.WeightedAverage * c.QuoteVolume
+		averageSum += c.WeightedAverage
+		count += 1
+	}
+
+	closeCurrent()
+
+	return resampled
+}
@@ -0,0 +1,36 @@
+package data
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestResample(t *testing.T) {
+	candles := []*ChartData{
+		&ChartData{Date: 300, Open: 1.0, Close: 2.0, High: 2.5, Low: 0.5, Volume: 2.0, QuoteVolume: 1.0, WeightedAverage: 2.0},
+		&ChartData{Date: 600, Open: 2.0, Close: 3.0, High: 3.5, Low: 1.5, Volume: 9.0, QuoteVolume: 3.0, WeightedAverage: 3.0},
+		&ChartData{Date: 900, Open: 3.0, Close: 1.0, High: 3.0, Low: 0.9, Volume: 0.0, QuoteVolume: 0.0, WeightedAverage: 1.0},
+		&ChartData{Date: 1200, Open: 1.0, Close: 1.0, High: 1.0, Low: 1.0, Volume: 0.0, QuoteVolume: 0.0, WeightedAverage: 1.0},
+	}
+
+	resampled := Resample(candles, 900)
+	require.Equal(t, 2, len(resampled))
+
+	first := resampled[0]
+	assert.Equal(t, int64(0), first.Date)
+	assert.Equal(t, 1.0, first.Open)
+	assert.Equal(t, 3.0, first.Close)
+	assert.Equal(t, 3.5, first.High)
+	assert.Equal(t, 0.5, first.Low)
+	assert.Equal(t, 11.0, first.Volume)
+	assert.Equal(t, 4.0, first.QuoteVolume)
+	assert.Equal(t, 2.75, first.WeightedAverage)
+
+	second := resampled[1]
+	assert.Equal(t, int64(900), second.Date)
+	assert.Equal(t, 3.0, second.Open)
+	assert.Equal(t, 1.0, second.Close)
+	assert.Equal(t, 0.9, second.Low)
+	assert.Equal(t, 1.0, second.WeightedAverage)
+}
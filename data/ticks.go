@@ -0,0 +1,76 @@
+package data
+
+// A single public trade. Rate is in the base currency, Amount in the quote
+// currency and Total = Rate * Amount in the base currency.
+type Tick struct {
+	GlobalTradeId int64
+	TradeId       int64
+	Date          int64
+	Type          string
+	Rate          float64
+	Amount        float64
+	Total         float64
+}
+
+// BuildCandles aggregates ticks, which must be in the order they happened,
+// into candles of any resolution. Periods without trades between the first
+// and last tick get a flat candle at the previous close with zero volume, the
+// same as the exchange's own chart data.
+func BuildCandles(ticks []*Tick, resolution int64) []*ChartData {
+	candles := make([]*ChartData, 0)
+
+	var current *ChartData
+
+	for _, t := range ticks {
+		date := t.Date - t.Date%resolution
+
+		if current != nil && current.Date != date {
+			closeCandle(current)
+			candles = append(candles, current)
+
+			for flat := current.Date + resolution; flat < date; flat += resolution {
+				candles = append(candles, &ChartData{
+					Date:            flat,
+					High:            current.Close,
+					Low:             current.Close,
+					Open:            current.Close,
+					Close:           current.Close,
+					WeightedAverage: current.Close,
+				})
+			}
+
+			current = nil
+		}
+
+		if current == nil {
+			current = &ChartData{Date: date, High: t.Rate, Low: t.Rate, Open: t.Rate}
+		}
+
+		if t.Rate > current.High {
+			current.High = t.Rate
+		}
+
+		if t.Rate < current.Low {
+			current.Low = t.Rate
+		}
+
+		current.Close = t.Rate
+		current.Volume += t.Total
+		current.QuoteVolume += t.Amount
+	}
+
+	if current != nil {
+		closeCandle(current)
+		candles = append(candles, current)
+	}
+
+	return candles
+}
+
+func closeCandle(candle *ChartData) {
+	if candle.QuoteVolume > 0 {
+		candle.WeightedAverage = candle.Volume / candle.QuoteVolume
+	} else {
+		candle.WeightedAverage = candle.Close
+	}
+}
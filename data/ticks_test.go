@@ -0,0 +1,43 @@
+package data
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestBuildCandles(t *testing.T) {
+	ticks := []*Tick{
+		&Tick{Date: 61, Rate: 2.0, Amount: 1.0, Total: 2.0},
+		&Tick{Date: 75, Rate: 4.0, Amount: 1.0, Total: 4.0},
+		&Tick{Date: 119, Rate: 1.0, Amount: 2.0, Total: 2.0},
+		&Tick{Date: 245, Rate: 3.0, Amount: 1.0, Total: 3.0},
+	}
+
+	candles := BuildCandles(ticks, 60)
+	require.Equal(t, 4, len(candles))
+
+	first := candles[0]
+	assert.Equal(t, int64(60), first.Date)
+	assert.Equal(t, 2.0, first.Open)
+	assert.Equal(t, 4.0, first.High)
+	assert.Equal(t, 1.0, first.Low)
+	assert.Equal(t, 1.0, first.Close)
+	assert.Equal(t, 8.0, first.Volume)
+	assert.Equal(t, 4.0, first.QuoteVolume)
+	assert.Equal(t, 2.0, first.WeightedAverage)
+
+	// no trades in 120 - 240
+	flat := candles[1]
+	assert.Equal(t, int64(120), flat.Date)
+	assert.Equal(t, 1.0, flat.Open)
+	assert.Equal(t, 1.0, flat.Close)
+	assert.Equal(t, 0.0, flat.Volume)
+	assert.Equal(t, int64(180), candles[2].Date)
+
+	last := candles[3]
+	assert.Equal(t, int64(240), last.Date)
+	assert.Equal(t, 3.0, last.WeightedAverage)
+
+	assert.Equal(t, 0, len(BuildCandles([]*Tick{}, 60)))
+}
@@ -1,5 +1,12 @@
 package data
 
+// Candle resolutions (in seconds) supported by the Poloniex chart data API.
+var RESOLUTIONS = []int64{300, 900, 1800, 7200, 14400, 86400}
+
+// The finest resolution available. Any multiple of it can be built by
+// resampling.
+const BASE_RESOLUTION = 300
+
 type Account struct {
 	Name    string
 	Balance float64
@@ -0,0 +1,332 @@
+package db
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/jbgo/sftbot/data"
+	"strconv"
+	"strings"
+)
+
+const CHART_DATA_PREFIX = "chart_data."
+
+// ChartDataRepository stores candles keyed by their date (big-endian), in a
+// bucket per market and resolution.
+type ChartDataRepository struct {
+	DB *DB
+}
+
+// WritePeriods stores candles in a single transaction.
+func (repo *ChartDataRepository) WritePeriods(currencyPair string, resolution int64, periods []*data.ChartData) error {
+	return repo.DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(ChartDataBucket(currencyPair, resolution)))
+		if err != nil {
+			return err
+		}
+
+		for _, p := range periods {
+			err = b.Put(encodeKey(p.Date), encodeValue(p))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Candles of each market and resolution are kept in their own bucket so
+// series of different resolutions never interleave.
+func ChartDataBucket(currencyPair string, resolution int64) string {
+	return fmt.Sprintf("%s%s.%d", CHART_DATA_PREFIX, currencyPair, resolution)
+}
+
+// ParseChartDataBucket is the inverse of ChartDataBucket. Buckets written
+// before candles were split by resolution have a resolution of zero.
+func ParseChartDataBucket(bucketName string) (currencyPair string, resolution int64, ok bool) {
+	if !strings.HasPrefix(bucketName, CHART_DATA_PREFIX) {
+		return "", 0, false
+	}
+
+	name := strings.TrimPrefix(bucketName, CHART_DATA_PREFIX)
+	i := strings.LastIndex(name, ".")
+
+	if i < 0 {
+		return name, 0, true
+	}
+
+	resolution, err := strconv.ParseInt(name[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return name[:i], resolution, true
+}
+
+type ChartDataSummary struct {
+	Market     string
+	Resolution int64
+	Count      int
+	First      int64
+	Last       int64
+}
+
+// ChartDataSummaries describes every non-empty chart data bucket.
+func (repo *ChartDataRepository) ChartDataSummaries() (summaries []*ChartDataSummary, err error) {
+	err = repo.DB.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			currencyPair, resolution, ok := ParseChartDataBucket(string(name))
+			if !ok {
+				return nil
+			}
+
+			cursor := b.Cursor()
+			first, _ := cursor.First()
+			last, _ := cursor.Last()
+
+			if first == nil {
+				return nil
+			}
+
+			summaries = append(summaries, &ChartDataSummary{
+				Market:     currencyPair,
+				Resolution: resolution,
+				Count:      b.Stats().KeyN,
+				First:      int64(binary.BigEndian.Uint64(first)),
+				Last:       int64(binary.BigEndian.Uint64(last)),
+			})
+
+			return nil
+		})
+	})
+
+	return summaries, err
+}
+
+// chartDataBucket returns nil when nothing has been stored for the market and
+// resolution yet. Readers treat that the same as an empty bucket.
+func chartDataBucket(tx *bolt.Tx, currencyPair string, resolution int64) *bolt.Bucket {
+	return tx.Bucket([]byte(ChartDataBucket(currencyPair, resolution)))
+}
+
+func (repo *ChartDataRepository) ForEachPeriod(currencyPair string, resolution int64, callback func(chartData *data.ChartData)) error {
+	return repo.DB.View(func(tx *bolt.Tx) error {
+		b := chartDataBucket(tx, currencyPair, resolution)
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			chartData := &data.ChartData{}
+
+			err := decodeRecord(ChartDataBucket(currencyPair, resolution), k, v, chartData)
+			if err != nil {
+				return err
+			}
+
+			callback(chartData)
+
+			return nil
+		})
+	})
+}
+
+// LastPeriod returns the newest stored candle, or nil if there is none.
+func (repo *ChartDataRepository) LastPeriod(currencyPair string, resolution int64) (chartData *data.ChartData, err error) {
+	err = repo.DB.View(func(tx *bolt.Tx) error {
+		b := chartDataBucket(tx, currencyPair, resolution)
+		if b == nil {
+			return nil
+		}
+
+		k, v := b.Cursor().Last()
+		if v == nil {
+			return nil
+		}
+
+		chartData = &data.ChartData{}
+
+		return decodeRecord(ChartDataBucket(currencyPair, resolution), k, v, chartData)
+	})
+
+	return chartData, err
+}
+
+// PeriodDates returns the dates of the stored candles between startTime and
+// endTime (inclusive) in ascending order.
+func (repo *ChartDataRepository) PeriodDates(currencyPair string, resolution, startTime, endTime int64) (dates []int64, err error) {
+	err = repo.DB.View(func(tx *bolt.Tx) error {
+		b := chartDataBucket(tx, currencyPair, resolution)
+		if b == nil {
+			return nil
+		}
+
+		minTime := encodeKey(startTime)
+		maxTime := encodeKey(endTime)
+
+		cursor := b.Cursor()
+
+		for k, _ := cursor.Seek(minTime); k != nil && bytes.Compare(k, maxTime) <= 0; k, _ = cursor.Next() {
+			dates = append(dates, int64(binary.BigEndian.Uint64(k)))
+		}
+
+		return nil
+	})
+
+	return dates, err
+}
+
+// GetPeriods returns the candles between startTime and endTime (inclusive).
+// When no candles of the requested resolution are stored for that range, they
+// are built by resampling base resolution candles.
+func (repo *ChartDataRepository) GetPeriods(currencyPair string, resolution, startTime, endTime int64) ([]*data.ChartData, error) {
+	periods, err := repo.readPeriods(currencyPair, resolution, startTime, endTime)
+
+	if len(periods) > 0 || resolution <= data.BASE_RESOLUTION || resolution%data.BASE_RESOLUTION != 0 {
+		return periods, err
+	}
+
+	// include every base candle of the first resampled period
+	baseStart := startTime - startTime%resolution
+
+	basePeriods, err := repo.readPeriods(currencyPair, data.BASE_RESOLUTION, baseStart, endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	periods = make([]*data.ChartData, 0)
+
+	for _, p := range data.Resample(basePeriods, resolution) {
+		if p.Date >= startTime {
+			periods = append(periods, p)
+		}
+	}
+
+	return periods, nil
+}
+
+func (repo *ChartDataRepository) readPeriods(currencyPair string, resolution, startTime, endTime int64) (periods []*data.ChartData, err error) {
+	err = repo.DB.View(func(tx *bolt.Tx) error {
+		b := chartDataBucket(tx, currencyPair, resolution)
+		if b == nil {
+			return nil
+		}
+
+		minTime := encodeKey(startTime)
+		maxTime := encodeKey(endTime)
+
+		cursor := b.Cursor()
+
+		for k, v := cursor.Seek(minTime); k != nil && bytes.Compare(k, maxTime) <= 0; k, v = cursor.Next() {
+			d := &data.ChartData{}
+
+			err = decodeRecord(ChartDataBucket(currencyPair, resolution), k, v, d)
+			if err != nil {
+				return err
+			}
+
+			periods = append(periods, d)
+		}
+
+		return nil
+	})
+
+	return periods, err
+}
+
+// Before candles were split by resolution, every candle of a market was
+// stored in a single bucket, whatever resolution it was imported at.
+func LegacyChartDataBucket(currencyPair string) string {
+	return CHART_DATA_PREFIX + currencyPair
+}
+
+// LegacyResolution guesses the resolution of a legacy bucket from the most
+// common spacing between its candles. It returns the base resolution when no
+// spacing matches a supported resolution.
+func (repo *ChartDataRepository) LegacyResolution(currencyPair string) (resolution int64, err error) {
+	spacings := make(map[int64]int)
+
+	err = repo.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(LegacyChartDataBucket(currencyPair)))
+		if b == nil {
+			return nil
+		}
+
+		previous := int64(-1)
+
+		return b.ForEach(func(k, v []byte) error {
+			date := int64(binary.BigEndian.Uint64(k))
+
+			if previous >= 0 {
+				spacings[date-previous] += 1
+			}
+
+			previous = date
+
+			return nil
+		})
+	})
+
+	resolution = data.BASE_RESOLUTION
+
+	for _, r := range data.RESOLUTIONS {
+		if spacings[r] > spacings[resolution] {
+			resolution = r
+		}
+	}
+
+	return resolution, err
+}
+
+// MigrateLegacy moves the candles of a legacy bucket into the bucket of the
+// given resolution and deletes the legacy bucket. Candles already stored at
+// that resolution are kept. Candles whose date is not a multiple of the
+// resolution are moved to the quarantine bucket of the legacy bucket.
+func (repo *ChartDataRepository) MigrateLegacy(currencyPair string, resolution int64) (migrated, quarantined int, err error) {
+	legacyBucket := LegacyChartDataBucket(currencyPair)
+
+	err = repo.DB.Update(func(tx *bolt.Tx) error {
+		legacy := tx.Bucket([]byte(legacyBucket))
+		if legacy == nil {
+			return fmt.Errorf("no legacy chart data for %s", currencyPair)
+		}
+
+		target, err := tx.CreateBucketIfNotExists([]byte(ChartDataBucket(currencyPair, resolution)))
+		if err != nil {
+			return err
+		}
+
+		err = legacy.ForEach(func(k, v []byte) error {
+			date := int64(binary.BigEndian.Uint64(k))
+
+			if date%resolution != 0 {
+				q, err := tx.CreateBucketIfNotExists([]byte(QuarantineBucket(legacyBucket)))
+				if err != nil {
+					return err
+				}
+
+				quarantined += 1
+
+				return q.Put(append([]byte{}, k...), append([]byte{}, v...))
+			}
+
+			if target.Get(k) != nil {
+				return nil
+			}
+
+			migrated += 1
+
+			return target.Put(append([]byte{}, k...), append([]byte{}, v...))
+		})
+
+		if err != nil {
+			return err
+		}
+
+		return tx.DeleteBucket([]byte(legacyBucket))
+	})
+
+	return migrated, quarantined, err
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/jbgo/sftbot/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseChartDataBucket(t *testing.T) {
+	bucketName := ChartDataBucket("USDT_BTC", 300)
+	assert.Equal(t, "chart_data.USDT_BTC.300", bucketName)
+
+	market, resolution, ok := ParseChartDataBucket(bucketName)
+	assert.True(t, ok)
+	assert.Equal(t, "USDT_BTC", market)
+	assert.Equal(t, int64(300), resolution)
+
+	market, resolution, ok = ParseChartDataBucket("chart_data.BTC_XYZ")
+	assert.True(t, ok)
+	assert.Equal(t, "BTC_XYZ", market)
+	assert.Equal(t, int64(0), resolution)
+
+	_, _, ok = ParseChartDataBucket("accounts")
+	assert.False(t, ok)
+
+	_, _, ok = ParseChartDataBucket("chart_data.BTC_XYZ.abc")
+	assert.False(t, ok)
+}
+
+func TestChartDataInFreshDB(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	chartData := db.ChartData()
+	defer cleanup()
+
+	t.Run("reads nothing before the first import", func(t *testing.T) {
+		last, err := chartData.LastPeriod("BTC_NEW", 300)
+		assert.Nil(t, err)
+		assert.Nil(t, last)
+
+		dates, err := chartData.PeriodDates("BTC_NEW", 300, 0, 3600)
+		assert.Nil(t, err)
+		assert.Empty(t, dates)
+
+		periods, err := chartData.GetPeriods("BTC_NEW", 900, 0, 3600)
+		assert.Nil(t, err)
+		assert.Empty(t, periods)
+
+		called := false
+		err = chartData.ForEachPeriod("BTC_NEW", 300, func(*data.ChartData) { called = true })
+		assert.Nil(t, err)
+		assert.False(t, called)
+	})
+
+	t.Run("creates the bucket on the first import", func(t *testing.T) {
+		require.Nil(t, chartData.WritePeriods("BTC_NEW", 300, []*data.ChartData{
+			candle(300, 1.0),
+			candle(600, 2.0),
+		}))
+
+		last, err := chartData.LastPeriod("BTC_NEW", 300)
+		require.Nil(t, err)
+		require.NotNil(t, last)
+		assert.Equal(t, int64(600), last.Date)
+
+		dates, err := chartData.PeriodDates("BTC_NEW", 300, 0, 3600)
+		assert.Nil(t, err)
+		assert.Equal(t, []int64{300, 600}, dates)
+	})
+}
+
+func TestMigrateLegacyChartData(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	chartData := db.ChartData()
+	defer cleanup()
+
+	require.Nil(t, db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(LegacyChartDataBucket("BTC_OLD")))
+		if err != nil {
+			return err
+		}
+
+		for _, c := range []*data.ChartData{candle(300, 1.0), candle(600, 2.0), candle(750, 9.0), candle(900, 3.0), candle(1200, 4.0)} {
+			err = b.Put(encodeKey(c.Date), encodeValue(c))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}))
+
+	require.Nil(t, chartData.WritePeriods("BTC_OLD", 300, []*data.ChartData{candle(1200, 5.0)}))
+
+	resolution, err := chartData.LegacyResolution("BTC_OLD")
+	require.Nil(t, err)
+	assert.Equal(t, int64(300), resolution)
+
+	migrated, quarantined, err := chartData.MigrateLegacy("BTC_OLD", resolution)
+	require.Nil(t, err)
+	assert.Equal(t, 3, migrated)
+	assert.Equal(t, 1, quarantined)
+
+	periods, err := chartData.GetPeriods("BTC_OLD", 300, 0, 3600)
+	require.Nil(t, err)
+	require.Len(t, periods, 4)
+	assert.Equal(t, 1.0, periods[0].Close)
+	assert.Equal(t, 5.0, periods[3].Close, "candles already stored are kept")
+
+	summaries, err := chartData.ChartDataSummaries()
+	require.Nil(t, err)
+	for _, s := range summaries {
+		assert.NotEqual(t, int64(0), s.Resolution)
+	}
+
+	_, _, err = chartData.MigrateLegacy("BTC_OLD", resolution)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,251 @@
+package db
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/jbgo/sftbot/data"
+	"strings"
+)
+
+const QUARANTINE_PREFIX = "quarantine."
+
+// Kinds of problems found by Check.
+const PROBLEM_UNDECODABLE = "undecodable"
+const PROBLEM_INVALID = "invalid"
+const PROBLEM_DUPLICATE = "duplicate"
+const PROBLEM_OUT_OF_ORDER = "out-of-order"
+const PROBLEM_KEY_MISMATCH = "key-mismatch"
+
+// A record that cannot be used as it is. Key is the raw bolt key of the record.
+type Problem struct {
+	Bucket string
+	Key    []byte
+	Kind   string
+	Detail string
+}
+
+func (p *Problem) String() string {
+	return fmt.Sprintf("%s %x: %s: %s", p.Bucket, p.Key, p.Kind, p.Detail)
+}
+
+// The result of checking a single bucket. Gaps are only reported for chart
+// data with a known resolution, between the first and last valid candle.
+type BucketReport struct {
+	Bucket     string
+	Records    int
+	Checked    bool
+	Resolution int64
+	Problems   []*Problem
+	Gaps       []data.Gap
+}
+
+type CheckReport struct {
+	Buckets []*BucketReport
+}
+
+func (report *CheckReport) Problems() []*Problem {
+	problems := make([]*Problem, 0)
+
+	for _, b := range report.Buckets {
+		problems = append(problems, b.Problems...)
+	}
+
+	return problems
+}
+
+func (report *CheckReport) GapCount() (n int) {
+	for _, b := range report.Buckets {
+		n += len(b.Gaps)
+	}
+	return n
+}
+
+func QuarantineBucket(bucketName string) string {
+	return QUARANTINE_PREFIX + bucketName
+}
+
+// Check scans every bucket. Chart data and tick records are decoded and
+// validated, in key order, so a record that repeats or goes back in time
+// relative to the previous valid record is reported. Other buckets are only
+// counted.
+func (d *DB) Check() (*CheckReport, error) {
+	report := &CheckReport{}
+
+	err := d.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			bucketName := string(name)
+			var bucketReport *BucketReport
+			var err error
+
+			switch {
+			case strings.HasPrefix(bucketName, CHART_DATA_PREFIX):
+				bucketReport, err = checkChartData(bucketName, b)
+			case strings.HasPrefix(bucketName, TICKS_PREFIX):
+				bucketReport, err = checkTicks(bucketName, b)
+			default:
+				bucketReport = &BucketReport{Bucket: bucketName, Records: b.Stats().KeyN}
+			}
+
+			if err != nil {
+				return err
+			}
+
+			report.Buckets = append(report.Buckets, bucketReport)
+
+			return nil
+		})
+	})
+
+	return report, err
+}
+
+func checkChartData(bucketName string, b *bolt.Bucket) (*BucketReport, error) {
+	_, resolution, _ := ParseChartDataBucket(bucketName)
+	report := &BucketReport{Bucket: bucketName, Checked: true, Resolution: resolution}
+	dates := make([]int64, 0)
+	var previous *data.ChartData
+
+	err := b.ForEach(func(k, v []byte) error {
+		report.Records += 1
+
+		problem := func(kind, detail string) {
+			key := make([]byte, len(k))
+			copy(key, k)
+			report.Problems = append(report.Problems, &Problem{Bucket: bucketName, Key: key, Kind: kind, Detail: detail})
+		}
+
+		candle := &data.ChartData{}
+
+		err := decodeValue(v, candle)
+		if err != nil {
+			problem(PROBLEM_UNDECODABLE, err.Error())
+			return nil
+		}
+
+		err = candle.Validate()
+		if err != nil {
+			problem(PROBLEM_INVALID, err.Error())
+			return nil
+		}
+
+		if previous != nil && candle.Date == previous.Date {
+			problem(PROBLEM_DUPLICATE, fmt.Sprintf("date %d already stored", candle.Date))
+			return nil
+		}
+
+		if previous != nil && candle.Date < previous.Date {
+			problem(PROBLEM_OUT_OF_ORDER, fmt.Sprintf("date %d is before %d", candle.Date, previous.Date))
+			return nil
+		}
+
+		if !bytes.Equal(k, encodeKey(candle.Date)) {
+			problem(PROBLEM_KEY_MISMATCH, fmt.Sprintf("date %d stored under key %x", candle.Date, k))
+			return nil
+		}
+
+		previous = candle
+		dates = append(dates, candle.Date)
+
+		return nil
+	})
+
+	if resolution > 0 && len(dates) > 0 {
+		report.Gaps = data.FindGaps(dates, resolution, dates[0], dates[len(dates)-1])
+	}
+
+	return report, err
+}
+
+func checkTicks(bucketName string, b *bolt.Bucket) (*BucketReport, error) {
+	report := &BucketReport{Bucket: bucketName, Checked: true}
+	seen := make(map[int64]bool)
+	var previous *data.Tick
+
+	err := b.ForEach(func(k, v []byte) error {
+		report.Records += 1
+
+		problem := func(kind, detail string) {
+			key := make([]byte, len(k))
+			copy(key, k)
+			report.Problems = append(report.Problems, &Problem{Bucket: bucketName, Key: key, Kind: kind, Detail: detail})
+		}
+
+		t := &data.Tick{}
+
+		err := decodeValue(v, t)
+		if err != nil {
+			problem(PROBLEM_UNDECODABLE, err.Error())
+			return nil
+		}
+
+		if t.Date <= 0 || t.Rate <= 0 || t.Amount <= 0 {
+			problem(PROBLEM_INVALID, fmt.Sprintf("date, rate and amount must be positive: date=%d rate=%v amount=%v", t.Date, t.Rate, t.Amount))
+			return nil
+		}
+
+		if seen[t.TradeId] {
+			problem(PROBLEM_DUPLICATE, fmt.Sprintf("trade %d already stored", t.TradeId))
+			return nil
+		}
+
+		if previous != nil && t.Date < previous.Date {
+			problem(PROBLEM_OUT_OF_ORDER, fmt.Sprintf("date %d is before %d", t.Date, previous.Date))
+			return nil
+		}
+
+		if len(k) != 16 || int64(binary.BigEndian.Uint64(k)) != t.Date || int64(binary.BigEndian.Uint64(k[8:])) != t.TradeId {
+			problem(PROBLEM_KEY_MISMATCH, fmt.Sprintf("trade %d at %d stored under key %x", t.TradeId, t.Date, k))
+			return nil
+		}
+
+		seen[t.TradeId] = true
+		previous = t
+
+		return nil
+	})
+
+	return report, err
+}
+
+// Quarantine moves the records of problems into a quarantine bucket next to
+// the bucket they were found in, keeping their key and raw value, so they no
+// longer break reads but can still be inspected or restored. It returns the
+// number of records moved.
+func (d *DB) Quarantine(problems []*Problem) (moved int, err error) {
+	err = d.Update(func(tx *bolt.Tx) error {
+		for _, p := range problems {
+			b := tx.Bucket([]byte(p.Bucket))
+			if b == nil {
+				continue
+			}
+
+			v := b.Get(p.Key)
+			if v == nil {
+				continue
+			}
+
+			q, err := tx.CreateBucketIfNotExists([]byte(QuarantineBucket(p.Bucket)))
+			if err != nil {
+				return err
+			}
+
+			err = q.Put(p.Key, append([]byte{}, v...))
+			if err != nil {
+				return err
+			}
+
+			err = b.Delete(p.Key)
+			if err != nil {
+				return err
+			}
+
+			moved += 1
+		}
+
+		return nil
+	})
+
+	return moved, err
+}
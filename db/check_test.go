@@ -0,0 +1,121 @@
+package db
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/jbgo/sftbot/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openTestDB(t *testing.T) (*DB, func()) {
+	dir, err := ioutil.TempDir("", "sftbot-db")
+	require.Nil(t, err)
+
+	return NewDB(filepath.Join(dir, "test.db")), func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func candle(date int64, price float64) *data.ChartData {
+	return &data.ChartData{Date: date, Open: price, High: price, Low: price, Close: price, WeightedAverage: price}
+}
+
+func TestCheck(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	chartData := db.ChartData()
+	defer cleanup()
+
+	bucketName := ChartDataBucket("BTC_XYZ", 300)
+
+	require.Nil(t, chartData.WritePeriods("BTC_XYZ", 300, []*data.ChartData{
+		candle(300, 1.0),
+		candle(600, 1.0),
+		candle(900, 0.0),
+		candle(1800, 1.0),
+	}))
+
+	require.Nil(t, db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketName))
+		b.Put(encodeKey(int64(2100)), []byte("{not json"))
+		b.Put(encodeKey(int64(2400)), encodeValue(candle(600, 1.0)))
+		b.Put(encodeKey(int64(2700)), encodeValue(candle(3000, 1.0)))
+		return nil
+	}))
+
+	require.Nil(t, db.Store("accounts").Write("1", "anything"))
+
+	t.Run("reports problems and gaps", func(t *testing.T) {
+		report, err := db.Check()
+		require.Nil(t, err)
+
+		var chart *BucketReport
+		for _, b := range report.Buckets {
+			if b.Bucket == bucketName {
+				chart = b
+			}
+		}
+
+		require.NotNil(t, chart)
+		assert.Equal(t, 7, chart.Records)
+
+		kinds := make([]string, 0)
+		for _, p := range chart.Problems {
+			kinds = append(kinds, p.Kind)
+		}
+
+		assert.Equal(t, []string{PROBLEM_INVALID, PROBLEM_UNDECODABLE, PROBLEM_OUT_OF_ORDER, PROBLEM_KEY_MISMATCH}, kinds)
+		assert.Equal(t, []data.Gap{data.Gap{Start: 900, End: 1500}}, chart.Gaps)
+	})
+
+	t.Run("quarantines bad records", func(t *testing.T) {
+		report, err := db.Check()
+		require.Nil(t, err)
+
+		moved, err := db.Quarantine(report.Problems())
+		require.Nil(t, err)
+		assert.Equal(t, 4, moved)
+
+		report, err = db.Check()
+		require.Nil(t, err)
+		assert.Equal(t, 0, len(report.Problems()))
+
+		periods, err := chartData.GetPeriods("BTC_XYZ", 300, 0, 3000)
+		require.Nil(t, err)
+		assert.Equal(t, 3, len(periods))
+
+		require.Nil(t, db.View(func(tx *bolt.Tx) error {
+			q := tx.Bucket([]byte(QuarantineBucket(bucketName)))
+			require.NotNil(t, q)
+			assert.Equal(t, []byte("{not json"), q.Get(encodeKey(int64(2100))))
+			return nil
+		}))
+	})
+}
+
+func TestForEachPeriodDoesNotReuseRecords(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	chartData := db.ChartData()
+	defer cleanup()
+
+	withVolume := candle(300, 1.0)
+	withVolume.Volume = 5.0
+
+	require.Nil(t, chartData.WritePeriods("BTC_XYZ", 300, []*data.ChartData{withVolume, candle(600, 1.0)}))
+	require.Nil(t, db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(ChartDataBucket("BTC_XYZ", 300)))
+		return b.Put(encodeKey(int64(600)), []byte(`{"Date":600,"Open":1,"High":1,"Low":1,"Close":1}`))
+	}))
+
+	periods := make([]*data.ChartData, 0)
+	require.Nil(t, chartData.ForEachPeriod("BTC_XYZ", 300, func(d *data.ChartData) {
+		periods = append(periods, d)
+	}))
+
+	require.Equal(t, 2, len(periods))
+	assert.Equal(t, 5.0, periods[0].Volume)
+	assert.Equal(t, 0.0, periods[1].Volume)
+}
@@ -0,0 +1,331 @@
+package db
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Everything sftbot stores lives in a single bolt file. Its path can be
+// changed with the SFTBOT_DB environment variable.
+const DEFAULT_PATH = "sftbot.db"
+const PATH_ENV = "SFTBOT_DB"
+
+// Before candles and trader state shared one file, trader state, the ledger
+// and import cursors were kept here. See "sftbot db merge".
+const LEGACY_LIVE_PATH = "sftbot-live.db"
+
+// Buckets used through the key/value Store. Exchanges other than Poloniex
+// keep trader state and trades in "trading.<exchange>" and "ledger.<exchange>".
+const TRADING_BUCKET = "trading"
+const PROSPECT_BUCKET = "prospect"
+const LEDGER_BUCKET = "ledger"
+const IMPORTS_BUCKET = "imports"
+const CONFIG_BUCKET = "config"
+
+// Files merged with Merge, by absolute path.
+const MERGES_BUCKET = "merges"
+
+// Paper trading keeps its trader state, virtual exchange and ledger apart
+// from live trading.
+const PAPER_BUCKET = "paper"
+const PAPER_LEDGER_BUCKET = "paper_ledger"
+
+func Path() string {
+	path := os.Getenv(PATH_ENV)
+
+	if len(path) == 0 {
+		return DEFAULT_PATH
+	}
+
+	return path
+}
+
+// DB is the database file. The file is only opened for the length of a
+// transaction, so long running commands such as "plx trade" never lock out
+// other commands for more than a moment.
+type DB struct {
+	Path string
+}
+
+func NewDB(path string) *DB {
+	return &DB{Path: path}
+}
+
+// Default returns the database at Path().
+func Default() *DB {
+	return NewDB(Path())
+}
+
+func (d *DB) open() (*bolt.DB, error) {
+	return bolt.Open(d.Path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+}
+
+func (d *DB) View(fn func(tx *bolt.Tx) error) error {
+	boltdb, err := d.open()
+	if err != nil {
+		return err
+	}
+
+	defer boltdb.Close()
+
+	return boltdb.View(fn)
+}
+
+func (d *DB) Update(fn func(tx *bolt.Tx) error) error {
+	boltdb, err := d.open()
+	if err != nil {
+		return err
+	}
+
+	defer boltdb.Close()
+
+	return boltdb.Update(fn)
+}
+
+// Store returns a key/value store backed by a single bucket. The bucket is
+// created the first time it is used.
+func (d *DB) Store(bucketName string) *BoltStore {
+	return &BoltStore{BucketName: bucketName, DBFile: d.Path}
+}
+
+func (d *DB) ChartData() *ChartDataRepository {
+	return &ChartDataRepository{DB: d}
+}
+
+func (d *DB) Ticks() *TickRepository {
+	return &TickRepository{DB: d}
+}
+
+// Merge copies every bucket of the bolt file at path into this database.
+// Keys that already exist are kept, so merging the same file twice is safe,
+// except for trade lists of the ledger, which are merged by trade Id. It
+// returns the number of records copied or merged.
+func (d *DB) Merge(path string) (copied int, err error) {
+	if path == d.Path {
+		return 0, fmt.Errorf("cannot merge %s into itself", path)
+	}
+
+	_, err = os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return 0, err
+	}
+
+	source := NewDB(path)
+
+	err = source.View(func(from *bolt.Tx) error {
+		return d.Update(func(to *bolt.Tx) error {
+			err := from.ForEach(func(name []byte, b *bolt.Bucket) error {
+				target, err := to.CreateBucketIfNotExists(name)
+				if err != nil {
+					return err
+				}
+
+				return b.ForEach(func(k, v []byte) error {
+					existing := target.Get(k)
+
+					if existing != nil && isLedgerBucket(string(name)) {
+						merged, changed, err := mergeTradeLists(existing, v)
+						if err != nil || !changed {
+							return err
+						}
+
+						copied += 1
+
+						return target.Put(append([]byte{}, k...), merged)
+					}
+
+					if existing != nil {
+						return nil
+					}
+
+					copied += 1
+
+					return target.Put(append([]byte{}, k...), append([]byte{}, v...))
+				})
+			})
+
+			if err != nil {
+				return err
+			}
+
+			merges, err := to.CreateBucketIfNotExists([]byte(MERGES_BUCKET))
+			if err != nil {
+				return err
+			}
+
+			return merges.Put([]byte(absPath), encodeValue(time.Now().Unix()))
+		})
+	})
+
+	return copied, err
+}
+
+// Merged tells whether the file at path has been merged into this database.
+func (d *DB) Merged(path string) (merged bool, err error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+
+	err = d.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(MERGES_BUCKET))
+		merged = b != nil && b.Get([]byte(absPath)) != nil
+		return nil
+	})
+
+	return merged, err
+}
+
+// CheckLegacyMerged returns an error when LEGACY_LIVE_PATH exists but has not
+// been merged, since trading without it would forget open positions.
+func (d *DB) CheckLegacyMerged() error {
+	_, err := os.Stat(LEGACY_LIVE_PATH)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	merged, err := d.Merged(LEGACY_LIVE_PATH)
+	if err != nil || merged {
+		return err
+	}
+
+	return fmt.Errorf(`%s holds trader state that is not in %s, run "sftbot db merge" first`, LEGACY_LIVE_PATH, d.Path)
+}
+
+func isLedgerBucket(bucketName string) bool {
+	return bucketName == LEDGER_BUCKET || bucketName == PAPER_LEDGER_BUCKET || strings.HasPrefix(bucketName, LEDGER_BUCKET+".")
+}
+
+// A ledger record is a list of trades of one market in chronological order.
+// Only the fields needed to merge them are decoded, the rest is kept as is.
+type ledgerEntry struct {
+	Id   string
+	Date int64
+}
+
+// mergeTradeLists adds the trades of from that are missing in existing.
+func mergeTradeLists(existing, from []byte) (merged []byte, changed bool, err error) {
+	var trades, fromTrades []json.RawMessage
+
+	err = json.Unmarshal(existing, &trades)
+	if err != nil {
+		return nil, false, err
+	}
+
+	err = json.Unmarshal(from, &fromTrades)
+	if err != nil {
+		return nil, false, err
+	}
+
+	entries := make([]*ledgerEntry, 0, len(trades))
+	recorded := make(map[string]bool)
+
+	for _, raw := range trades {
+		entry := &ledgerEntry{}
+
+		err = json.Unmarshal(raw, entry)
+		if err != nil {
+			return nil, false, err
+		}
+
+		entries = append(entries, entry)
+		recorded[entry.Id] = true
+	}
+
+	for _, raw := range fromTrades {
+		entry := &ledgerEntry{}
+
+		err = json.Unmarshal(raw, entry)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if recorded[entry.Id] {
+			continue
+		}
+
+		recorded[entry.Id] = true
+		changed = true
+
+		trades = append(trades, raw)
+		entries = append(entries, entry)
+	}
+
+	if !changed {
+		return existing, false, nil
+	}
+
+	sort.Stable(byLedgerDate{trades, entries})
+
+	merged, err = json.Marshal(trades)
+
+	return merged, true, err
+}
+
+type byLedgerDate struct {
+	trades  []json.RawMessage
+	entries []*ledgerEntry
+}
+
+func (a byLedgerDate) Len() int { return len(a.trades) }
+
+func (a byLedgerDate) Swap(i, j int) {
+	a.trades[i], a.trades[j] = a.trades[j], a.trades[i]
+	a.entries[i], a.entries[j] = a.entries[j], a.entries[i]
+}
+
+func (a byLedgerDate) Less(i, j int) bool { return a.entries[i].Date < a.entries[j].Date }
+
+func encodeKey(value interface{}) []byte {
+	buf := new(bytes.Buffer)
+	err := binary.Write(buf, binary.BigEndian, value)
+
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return buf.Bytes()
+}
+
+func encodeValue(value interface{}) []byte {
+	encoded, err := json.Marshal(value)
+
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return []byte(encoded)
+}
+
+func decodeValue(data []byte, out interface{}) error {
+	return json.Unmarshal(data, out)
+}
+
+// decodeRecord is decodeValue with an error that says where the bad record is.
+func decodeRecord(bucketName string, key, value []byte, out interface{}) error {
+	err := decodeValue(value, out)
+
+	if err != nil {
+		return fmt.Errorf(`cannot decode record %x in %s: %s (see "sftbot db check")`, key, bucketName, err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPath(t *testing.T) {
+	defer os.Setenv(PATH_ENV, os.Getenv(PATH_ENV))
+
+	os.Setenv(PATH_ENV, "")
+	assert.Equal(t, DEFAULT_PATH, Path())
+
+	os.Setenv(PATH_ENV, "/tmp/other.db")
+	assert.Equal(t, "/tmp/other.db", Path())
+	assert.Equal(t, "/tmp/other.db", Default().Store("foo").DBFile)
+}
+
+func TestMerge(t *testing.T) {
+	target, cleanup := openTestDB(t)
+	defer cleanup()
+
+	sourcePath := filepath.Join(filepath.Dir(target.Path), "source.db")
+	source := NewDB(sourcePath).Store("trading")
+
+	require.Nil(t, source.Write("kept", "from source"))
+	require.Nil(t, source.Write("copied", "from source"))
+	require.Nil(t, target.Store("trading").Write("kept", "from target"))
+
+	copied, err := target.Merge(sourcePath)
+	require.Nil(t, err)
+	assert.Equal(t, 1, copied)
+
+	var value string
+	require.Nil(t, target.Store("trading").Read("kept", &value))
+	assert.Equal(t, "from target", value)
+	require.Nil(t, target.Store("trading").Read("copied", &value))
+	assert.Equal(t, "from source", value)
+
+	merged, err := target.Merged(sourcePath)
+	require.Nil(t, err)
+	assert.True(t, merged)
+
+	merged, err = target.Merged(filepath.Join(filepath.Dir(target.Path), "other.db"))
+	require.Nil(t, err)
+	assert.False(t, merged)
+
+	_, err = target.Merge(target.Path)
+	assert.NotNil(t, err)
+
+	_, err = target.Merge(filepath.Join(filepath.Dir(target.Path), "missing.db"))
+	assert.NotNil(t, err)
+}
+
+func TestMergeLedger(t *testing.T) {
+	target, cleanup := openTestDB(t)
+	defer cleanup()
+
+	sourcePath := filepath.Join(filepath.Dir(target.Path), "source.db")
+
+	type trade struct {
+		Id   string
+		Date int64
+		Type string
+	}
+
+	require.Nil(t, NewDB(sourcePath).Store(LEDGER_BUCKET).Write("BTC_ABC", []*trade{
+		&trade{Id: "1", Date: 100, Type: "buy"},
+		&trade{Id: "3", Date: 300, Type: "sell"},
+	}))
+	require.Nil(t, target.Store(LEDGER_BUCKET).Write("BTC_ABC", []*trade{
+		&trade{Id: "2", Date: 200, Type: "buy"},
+		&trade{Id: "3", Date: 300, Type: "sell"},
+	}))
+
+	copied, err := target.Merge(sourcePath)
+	require.Nil(t, err)
+	assert.Equal(t, 1, copied)
+
+	var trades []*trade
+	require.Nil(t, target.Store(LEDGER_BUCKET).Read("BTC_ABC", &trades))
+	require.Len(t, trades, 3)
+	assert.Equal(t, "1", trades[0].Id)
+	assert.Equal(t, "2", trades[1].Id)
+	assert.Equal(t, "3", trades[2].Id)
+	assert.Equal(t, "sell", trades[2].Type)
+
+	copied, err = target.Merge(sourcePath)
+	require.Nil(t, err)
+	assert.Equal(t, 0, copied)
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// MemoryStore is a Store that is never written to disk, e.g. for the trader
+// state of a backtest. Values are kept JSON encoded so they behave exactly as
+// they would in a BoltStore.
+type MemoryStore struct {
+	values map[string][]byte
+	mutex  sync.Mutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{values: make(map[string][]byte)}
+}
+
+func (store *MemoryStore) Read(key string, outValue interface{}) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return json.Unmarshal(store.values[key], &outValue)
+}
+
+func (store *MemoryStore) Write(key string, value interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.values[key] = encoded
+
+	return nil
+}
+
+func (store *MemoryStore) Delete(key string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	delete(store.values, key)
+
+	return nil
+}
+
+func (store *MemoryStore) HasData(key string) (error, bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return nil, len(store.values[key]) > 0
+}
+
+func (store *MemoryStore) Keys() ([]string, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	keys := make([]string, 0, len(store.values))
+	for key := range store.values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys, nil
+}
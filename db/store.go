@@ -11,6 +11,7 @@ type Store interface {
 	Write(key string, value interface{}) error
 	Delete(key string) error
 	HasData(key string) (error, bool)
+	Keys() ([]string, error)
 }
 
 type BoltStore struct {
@@ -112,3 +113,23 @@ func (store *BoltStore) HasData(key string) (err error, hasData bool) {
 
 	return err, hasData
 }
+
+func (store *BoltStore) Keys() (keys []string, err error) {
+	boltdb, err := openBoltDB(store)
+	if err != nil {
+		return nil, err
+	}
+
+	defer boltdb.Close()
+
+	err = boltdb.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(store.BucketName))
+
+		return b.ForEach(func(k, v []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	})
+
+	return keys, err
+}
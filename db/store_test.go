@@ -22,13 +22,21 @@ func TestBoltStore(t *testing.T) {
 	assert.Equal(t, bucketName, store.BucketName)
 	assert.Equal(t, dbFile, store.DBFile)
 
+	testStore(t, store)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func testStore(t *testing.T, store Store) {
 	bolts := []*lightningBolt{
 		&lightningBolt{"high", "near"},
 		&lightningBolt{"medium", "near"},
 		&lightningBolt{"low", "far"},
 	}
 
-	err = store.Write("foo", bolts)
+	err := store.Write("foo", bolts)
 	require.Nil(t, err)
 
 	bolts = make([]*lightningBolt, 0)
@@ -48,6 +56,16 @@ func TestBoltStore(t *testing.T) {
 	require.Nil(t, err)
 	assert.True(t, ok)
 
+	err = store.Write("bar", "baz")
+	require.Nil(t, err)
+
+	keys, err := store.Keys()
+	require.Nil(t, err)
+	assert.Equal(t, []string{"bar", "foo"}, keys)
+
+	err = store.Delete("bar")
+	require.Nil(t, err)
+
 	err = store.Delete("foo")
 	require.Nil(t, err)
 
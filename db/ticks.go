@@ -0,0 +1,95 @@
+package db
+
+import (
+	"bytes"
+	"github.com/boltdb/bolt"
+	"github.com/jbgo/sftbot/data"
+)
+
+const TICKS_PREFIX = "ticks."
+
+// TickRepository stores public trades in a bucket per market.
+type TickRepository struct {
+	DB *DB
+}
+
+// Ticks are keyed by date and then trade id, so they are stored in the order
+// they happened and re-importing a trade overwrites it.
+type tickKey struct {
+	Date    int64
+	TradeId int64
+}
+
+func TicksBucket(currencyPair string) string {
+	return TICKS_PREFIX + currencyPair
+}
+
+func (repo *TickRepository) WriteTicks(currencyPair string, ticks []*data.Tick) error {
+	return repo.DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(TicksBucket(currencyPair)))
+		if err != nil {
+			return err
+		}
+
+		for _, t := range ticks {
+			err = b.Put(encodeKey(tickKey{t.Date, t.TradeId}), encodeValue(t))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// LastTick returns the newest stored tick, or nil if there is none.
+func (repo *TickRepository) LastTick(currencyPair string) (tick *data.Tick, err error) {
+	err = repo.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(TicksBucket(currencyPair)))
+		if b == nil {
+			return nil
+		}
+
+		k, v := b.Cursor().Last()
+		if v == nil {
+			return nil
+		}
+
+		tick = &data.Tick{}
+
+		return decodeRecord(TicksBucket(currencyPair), k, v, tick)
+	})
+
+	return tick, err
+}
+
+// GetTicks returns the ticks between startTime and endTime (inclusive) in the
+// order they happened.
+func (repo *TickRepository) GetTicks(currencyPair string, startTime, endTime int64) (ticks []*data.Tick, err error) {
+	err = repo.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(TicksBucket(currencyPair)))
+		if b == nil {
+			return nil
+		}
+
+		minKey := encodeKey(tickKey{startTime, 0})
+		maxKey := encodeKey(tickKey{endTime + 1, 0})
+
+		cursor := b.Cursor()
+
+		for k, v := cursor.Seek(minKey); k != nil && bytes.Compare(k, maxKey) < 0; k, v = cursor.Next() {
+			t := &data.Tick{}
+
+			err := decodeRecord(TicksBucket(currencyPair), k, v, t)
+			if err != nil {
+				return err
+			}
+
+			ticks = append(ticks, t)
+		}
+
+		return nil
+	})
+
+	return ticks, err
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"github.com/jbgo/sftbot/command"
-	"github.com/jbgo/sftbot/data"
 	"github.com/mitchellh/cli"
 	"log"
 	"os"
@@ -16,21 +15,32 @@ func main() {
 	c.Args = os.Args[1:]
 
 	c.Commands = map[string]cli.CommandFactory{
-		"chart-data list":   command.ChartDataList,
-		"chart-data import": command.ChartDataImport,
-		"plx balances":      command.Balances,
-		"plx my-trades":     command.MyTrades,
-		"plx orders":        command.OpenOrders,
-		"plx prospect":      command.Prospect,
-		"plx ticker":        command.Ticker,
-		"plx trade":         command.Trade,
-		"plx trade-history": command.TradeHistory,
-		"simulate":          command.Simulate,
-	}
-
-	err := data.InitSchema()
-	if err != nil {
-		log.Fatal(err)
+		"chart-data list":    command.ChartDataList,
+		"chart-data export":  command.ChartDataExport,
+		"chart-data import":  command.ChartDataImport,
+		"chart-data markets": command.ChartDataMarkets,
+		"chart-data migrate": command.ChartDataMigrate,
+		"config list":        command.ConfigList,
+		"config save":        command.ConfigSave,
+		"db check":           command.DBCheck,
+		"db merge":           command.DBMerge,
+		"optimize":           command.Optimize,
+		"plx balances":       command.Balances,
+		"plx import-trades":  command.ImportTrades,
+		"plx my-trades":      command.MyTrades,
+		"plx orders":         command.OpenOrders,
+		"plx prospect":       command.Prospect,
+		"plx ticker":         command.Ticker,
+		"plx trade":          command.Trade,
+		"plx trade-history":  command.TradeHistory,
+		"pnl":                command.PnL,
+		"report html":        command.ReportHTML,
+		"report tax":         command.ReportTax,
+		"simulate":           command.Simulate,
+		"simulate portfolio": command.SimulatePortfolio,
+		"ticks candles":      command.TicksCandles,
+		"ticks import":       command.TicksImport,
+		"walk-forward":       command.WalkForward,
 	}
 
 	exitStatus, err := c.Run()
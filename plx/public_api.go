@@ -59,20 +59,27 @@ type TradeHistoryParams struct {
 	EndTime   int64
 }
 
+// The most trades returnTradeHistory returns for a single public request.
+const PUBLIC_TRADE_HISTORY_LIMIT = 50000
+
 type Trade struct {
-	Date   time.Time
-	Type   string
-	Rate   float64
-	Amount float64
-	Total  float64
+	GlobalTradeId int64
+	TradeId       int64
+	Date          time.Time
+	Type          string
+	Rate          float64
+	Amount        float64
+	Total         float64
 }
 
 type PlxPublicTrade struct {
-	Date   string
-	Type   string
-	Rate   string
-	Amount string
-	Total  string
+	GlobalTradeId int64 `json:"globalTradeID"`
+	TradeId       int64 `json:"tradeID"`
+	Date          string
+	Type          string
+	Rate          string
+	Amount        string
+	Total         string
 }
 
 func (p *TradeHistoryParams) ToQueryString() string {
@@ -105,6 +112,8 @@ func (client *Client) GetTradeHistory(params *TradeHistoryParams) (trades []Trad
 	for _, d := range respData {
 		trade := Trade{}
 
+		trade.GlobalTradeId = d.GlobalTradeId
+		trade.TradeId = d.TradeId
 		trade.Date, _ = time.Parse("2006-01-02 15:04:05", d.Date)
 		trade.Type = d.Type
 		trade.Rate, _ = strconv.ParseFloat(d.Rate, 64)
@@ -161,3 +170,33 @@ func (client *Client) GetTicker() (ticker []TickerEntry, err error) {
 
 	return ticker, err
 }
+
+type Currency struct {
+	Id       int64
+	Name     string
+	TxFee    float64 `json:",string"`
+	Disabled int
+	Delisted int
+	Frozen   int
+}
+
+func (client *Client) GetCurrencies() (currencies map[string]Currency, err error) {
+	url := fmt.Sprintf("%s?command=returnCurrencies", client.PublicApiUrl())
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return currencies, err
+	}
+
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode != 200 {
+		fmt.Printf("HTTP ERROR %s\n%s\n", resp.Status, string(body))
+		return currencies, fmt.Errorf("request failed: %s", resp.Status)
+	}
+
+	currencies = make(map[string]Currency)
+	err = json.Unmarshal(body, &currencies)
+	return currencies, err
+}
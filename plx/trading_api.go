@@ -7,6 +7,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"github.com/jbgo/sftbot/data"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -16,9 +17,9 @@ import (
 
 type CompleteBalance struct {
 	Currency  string
-	Available float64 `json:"available"`
-	OnOrders  float64 `json:"onOrders"`
-	BtcValue  float64 `json:"btcValue"`
+	Available data.Decimal `json:"available"`
+	OnOrders  data.Decimal `json:"onOrders"`
+	BtcValue  data.Decimal `json:"btcValue"`
 }
 
 func (client *Client) GetBalance(currency string) (balance *CompleteBalance, err error) {
@@ -65,12 +66,12 @@ func (client *Client) CompleteBalances() ([]CompleteBalance, error) {
 
 	err = json.Unmarshal(body, &respData)
 
-	for key, data := range respData {
+	for key, values := range respData {
 		balance := CompleteBalance{}
 		balance.Currency = key
-		balance.Available, _ = strconv.ParseFloat(data["available"], 64)
-		balance.OnOrders, _ = strconv.ParseFloat(data["onOrders"], 64)
-		balance.BtcValue, _ = strconv.ParseFloat(data["btcValue"], 64)
+		balance.Available, _ = data.ParseDecimal(values["available"])
+		balance.OnOrders, _ = data.ParseDecimal(values["onOrders"])
+		balance.BtcValue, _ = data.ParseDecimal(values["btcValue"])
 		balances = append(balances, balance)
 	}
 
@@ -80,9 +81,9 @@ func (client *Client) CompleteBalances() ([]CompleteBalance, error) {
 type OpenOrder struct {
 	Number int64 `json:"orderNumber,string"`
 	Type   string
-	Rate   float64 `json:",string"`
-	Amount float64 `json:",string"`
-	Total  float64 `json:",string"`
+	Rate   data.Decimal
+	Amount data.Decimal
+	Total  data.Decimal
 }
 
 func (client *Client) AllOpenOrders() (marketOrders map[string][]OpenOrder, err error) {
@@ -138,25 +139,34 @@ func (client *Client) GetOpenOrders(currencyPair string) (openOrders []*OpenOrde
 
 type PlxPrivateTrade struct {
 	GlobalTradeId int64
-	TradeId       string
+	TradeId       json.Number
 	OrderNumber   string
 	Date          string
-	Rate          float64 `json:",string"`
-	Amount        float64 `json:",string"`
-	Total         float64 `json:",string"`
-	Fee           float64 `json:",string"`
+	Rate          data.Decimal
+	Amount        data.Decimal
+	Total         data.Decimal
+	Fee           float64 `json:",string"` // a rate, not an amount
 	Type          string
 	Category      string
 }
 
 func (client *Client) MyTradeHistory(marketName string, startTime, endTime int64) (trades map[string][]*PlxPrivateTrade, err error) {
+	return client.MyTradeHistoryLimit(marketName, startTime, endTime, 0)
+}
 
+// MyTradeHistoryLimit returns at most limit trades, newest first. A limit of
+// zero uses the API default.
+func (client *Client) MyTradeHistoryLimit(marketName string, startTime, endTime, limit int64) (trades map[string][]*PlxPrivateTrade, err error) {
 	values := &url.Values{}
 	values.Set("command", "returnTradeHistory")
 	values.Set("currencyPair", marketName)
 	values.Set("start", strconv.FormatInt(startTime, 10))
 	values.Set("end", strconv.FormatInt(endTime, 10))
 
+	if limit > 0 {
+		values.Set("limit", strconv.FormatInt(limit, 10))
+	}
+
 	resp, err := client.TradingApiRequest(values)
 
 	if err != nil {
@@ -174,6 +184,11 @@ func (client *Client) MyTradeHistory(marketName string, startTime, endTime int64
 	trades = make(map[string][]*PlxPrivateTrade)
 
 	if marketName == "all" {
+		// an empty result is returned as [] rather than {}
+		if string(bytes.TrimSpace(body)) == "[]" {
+			return trades, nil
+		}
+
 		err = json.Unmarshal(body, &trades)
 	} else {
 		marketTrades := make([]*PlxPrivateTrade, 0, 100)
@@ -184,29 +199,62 @@ func (client *Client) MyTradeHistory(marketName string, startTime, endTime int64
 	return trades, err
 }
 
+func (client *Client) OrderTrades(orderNumber string) (trades []*PlxPrivateTrade, err error) {
+	values := &url.Values{}
+	values.Set("command", "returnOrderTrades")
+	values.Set("orderNumber", orderNumber)
+
+	resp, err := client.TradingApiRequest(values)
+
+	if err != nil {
+		return nil, err
+	}
+
+	trades = make([]*PlxPrivateTrade, 0)
+	err = decodeJsonResponse(resp, &trades, 200)
+
+	return trades, err
+}
+
+// Flags of the buy and sell commands. At most one applies to an order.
+const POST_ONLY = "postOnly"
+const FILL_OR_KILL = "fillOrKill"
+const IMMEDIATE_OR_CANCEL = "immediateOrCancel"
+
 type PlxOrder struct {
 	OrderNumber     int64 `json:",string"`
 	Type            string
 	CurrencyPair    string
-	Rate            float64 `json:",string"`
-	Amount          float64 `json:",string"`
+	Rate            data.Decimal
+	Amount          data.Decimal
 	ResultingTrades []*PlxPrivateTrade
+	// Set for immediate-or-cancel orders.
+	AmountUnfilled data.Decimal `json:"amountUnfilled"`
+	// Why the order was not placed, e.g. a post-only order that would have
+	// filled immediately.
+	Error string `json:"error"`
 }
 
-func (client *Client) Buy(currencyPair string, rate, amount float64) (plxOrder *PlxOrder, err error) {
-	return client.PlaceMarketOrder("buy", currencyPair, rate, amount)
+func (client *Client) Buy(currencyPair string, rate, amount data.Decimal, flag string) (plxOrder *PlxOrder, err error) {
+	return client.PlaceLimitOrder("buy", currencyPair, rate, amount, flag)
 }
 
-func (client *Client) Sell(currencyPair string, rate, amount float64) (plxOrder *PlxOrder, err error) {
-	return client.PlaceMarketOrder("sell", currencyPair, rate, amount)
+func (client *Client) Sell(currencyPair string, rate, amount data.Decimal, flag string) (plxOrder *PlxOrder, err error) {
+	return client.PlaceLimitOrder("sell", currencyPair, rate, amount, flag)
 }
 
-func (client *Client) PlaceMarketOrder(apiCommand, currencyPair string, rate, amount float64) (plxOrder *PlxOrder, err error) {
+// PlaceLimitOrder sends the rate and amount exactly, with at most 8 decimals.
+// flag is empty, or one of POST_ONLY, FILL_OR_KILL and IMMEDIATE_OR_CANCEL.
+func (client *Client) PlaceLimitOrder(apiCommand, currencyPair string, rate, amount data.Decimal, flag string) (plxOrder *PlxOrder, err error) {
 	values := &url.Values{}
 	values.Set("command", apiCommand)
 	values.Set("currencyPair", currencyPair)
-	values.Set("rate", strconv.FormatFloat(rate, 'f', -1, 64))
-	values.Set("amount", strconv.FormatFloat(amount, 'f', -1, 64))
+	values.Set("rate", rate.String())
+	values.Set("amount", amount.String())
+
+	if flag != "" {
+		values.Set(flag, "1")
+	}
 
 	resp, err := client.TradingApiRequest(values)
 
@@ -217,6 +265,9 @@ func (client *Client) PlaceMarketOrder(apiCommand, currencyPair string, rate, am
 	plxOrder = &PlxOrder{}
 
 	err = decodeJsonResponse(resp, plxOrder, 200, 201)
+	if err == nil && plxOrder.Error != "" {
+		err = fmt.Errorf("order rejected: %s", plxOrder.Error)
+	}
 
 	return plxOrder, err
 }
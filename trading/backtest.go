@@ -0,0 +1,396 @@
+package trading
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jbgo/sftbot/data"
+	"github.com/jbgo/sftbot/db"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"time"
+)
+
+const SECONDS_PER_YEAR = 365 * 24 * 60 * 60
+
+/**
+ * Backtest
+ *
+ * Runs the real Trader against a SimulatedExchange that replays candles, one
+ * candle per step, and measures how its NAV (base currency plus the alt
+ * position at the current price) evolved.
+ */
+
+type Backtest struct {
+	Market string
+	Config *TraderConfig
+	// Candles sorted by date. Candles before StartTime only warm up the
+	// trader's window, so they should cover at least Config.TimeWindow.
+	Candles         []*SummaryData
+	Resolution      int64
+	StartTime       int64
+	EndTime         int64
+	StartingBalance float64
+	Fees            FeeSchedule
+	// Defaults to ImmediateFill.
+	FillModel FillModel
+	// Rejected orders and other trader errors are logged here and the
+	// backtest carries on. Defaults to discarding them.
+	Logger *log.Logger
+	// When set, every order the trader places is journaled here.
+	Journal *Journal
+}
+
+type EquityPoint struct {
+	Date  int64
+	Price float64
+	Base  float64
+	Alt   float64
+	NAV   float64
+}
+
+type BacktestResult struct {
+	Market           string
+	StartTime        int64
+	EndTime          int64
+	Steps            int
+	StartingNAV      float64
+	EndingNAV        float64
+	TotalReturn      float64
+	BuyAndHoldReturn float64
+	MaxDrawdown      float64
+	// Longest time in seconds the NAV spent below a previous peak.
+	MaxDrawdownDuration int64
+	Sharpe              float64
+	Sortino             float64
+	// Orders that filled at least partly. An order filled in several parts
+	// counts once; Fills has every part.
+	Trades  int
+	Wins    int
+	Losses  int
+	WinRate float64
+	// Cost weighted time in seconds between buying and selling.
+	AvgHoldingTime int64
+	FeesPaid       float64
+	// Fraction of steps spent holding the alt currency.
+	Exposure float64
+	Errors   int
+
+	Equity []*EquityPoint `json:"-"`
+	Fills  []*Trade       `json:"-"`
+}
+
+func (bt *Backtest) Run() (*BacktestResult, error) {
+	if bt.Resolution <= 0 {
+		return nil, fmt.Errorf("invalid resolution: %d", bt.Resolution)
+	}
+
+	err := bt.Config.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	config := *bt.Config
+	config.Simulate = false
+
+	exchange := NewSimulatedExchange(map[string]float64{})
+	exchange.Fees = bt.Fees
+	if bt.FillModel != nil {
+		exchange.FillModel = bt.FillModel
+	}
+	market := exchange.AddMarket(bt.Market, bt.Candles, config.TimeWindow)
+	exchange.Balances[market.GetBaseCurrency()] = &Balance{Available: data.NewDecimal(bt.StartingBalance)}
+
+	trader, err := NewTrader(bt.Market, exchange, db.NewMemoryStore(), &config)
+	if err != nil {
+		return nil, err
+	}
+
+	trader.Clock = func() time.Time { return time.Unix(exchange.Now, 0) }
+	trader.Logger = log.New(ioutil.Discard, "", 0)
+	trader.Journal = bt.Journal
+
+	logger := bt.Logger
+	if logger == nil {
+		logger = trader.Logger
+	}
+
+	equity := make([]*EquityPoint, 0, len(bt.Candles))
+	errors := 0
+
+	for _, candle := range bt.Candles {
+		if candle.Date < bt.StartTime || candle.Date > bt.EndTime {
+			continue
+		}
+
+		exchange.Advance(candle.Date)
+
+		err = trader.Trade()
+		if err != nil {
+			errors += 1
+			logger.Printf("market=%s evt=error date=%d msg=\"%s\"\n", bt.Market, candle.Date, err.Error())
+		}
+
+		// funds held on orders are still ours until the orders fill
+		baseBalance := exchange.balance(market.GetBaseCurrency())
+		altBalance := exchange.balance(market.GetCurrency())
+		base := (baseBalance.Available + baseBalance.OnOrders).Float64()
+		alt := (altBalance.Available + altBalance.OnOrders).Float64()
+
+		equity = append(equity, &EquityPoint{
+			Date:  candle.Date,
+			Price: candle.WeightedAverage,
+			Base:  base,
+			Alt:   alt,
+			NAV:   base + alt*candle.WeightedAverage,
+		})
+	}
+
+	if len(equity) == 0 {
+		return nil, fmt.Errorf("no chart data for %s between %d and %d", bt.Market, bt.StartTime, bt.EndTime)
+	}
+
+	result := NewBacktestResult(bt.Market, bt.Resolution, bt.StartingBalance, equity, exchange.Trades)
+	result.Errors = errors
+
+	return result, nil
+}
+
+// NewBacktestResult computes the performance metrics of an equity curve with
+// one point per resolution seconds, starting from startingNAV, and the fills
+// that produced it.
+func NewBacktestResult(marketName string, resolution int64, startingNAV float64, equity []*EquityPoint, fills []*Trade) *BacktestResult {
+	first, last := equity[0], equity[len(equity)-1]
+
+	result := &BacktestResult{
+		Market:      marketName,
+		StartTime:   first.Date,
+		EndTime:     last.Date,
+		Steps:       len(equity),
+		StartingNAV: startingNAV,
+		EndingNAV:   last.NAV,
+		Trades:      countOrders(fills),
+		Equity:      equity,
+		Fills:       fills,
+	}
+
+	if result.StartingNAV > 0 {
+		result.TotalReturn = result.EndingNAV/result.StartingNAV - 1
+	}
+
+	if first.Price > 0 {
+		result.BuyAndHoldReturn = last.Price/first.Price - 1
+	}
+
+	result.MaxDrawdown, result.MaxDrawdownDuration = maxDrawdown(result.StartingNAV, equity)
+
+	returns := navReturns(result.StartingNAV, equity)
+	periodsPerYear := float64(SECONDS_PER_YEAR) / float64(resolution)
+	result.Sharpe, result.Sortino = riskAdjustedReturns(returns, periodsPerYear)
+
+	pnl := &PnL{Market: marketName}
+	for name, marketFills := range fillsByMarket(fills) {
+		pnl.Add(CalculatePnL(name, marketFills, 0.0))
+	}
+
+	result.Wins = pnl.Wins
+	result.Losses = pnl.Losses
+	result.WinRate = pnl.WinRate()
+	result.FeesPaid = pnl.Fees
+	result.AvgHoldingTime = averageHoldingTime(fills)
+
+	exposed := 0
+	for _, point := range equity {
+		if point.Alt > 0 {
+			exposed += 1
+		}
+	}
+	result.Exposure = float64(exposed) / float64(len(equity))
+
+	return result
+}
+
+// maxDrawdown returns the largest fall from a peak as a fraction of the peak,
+// and the longest time in seconds spent below a peak, whether or not that
+// was the largest fall.
+func maxDrawdown(startingNAV float64, equity []*EquityPoint) (drawdown float64, duration int64) {
+	peak := startingNAV
+	peakDate := equity[0].Date
+
+	for _, point := range equity {
+		if point.NAV >= peak {
+			peak = point.NAV
+			peakDate = point.Date
+			continue
+		}
+
+		if peak > 0 {
+			drawdown = math.Max(drawdown, 1-point.NAV/peak)
+		}
+
+		if point.Date-peakDate > duration {
+			duration = point.Date - peakDate
+		}
+	}
+
+	return drawdown, duration
+}
+
+func navReturns(startingNAV float64, equity []*EquityPoint) []float64 {
+	returns := make([]float64, 0, len(equity))
+	previous := startingNAV
+
+	for _, point := range equity {
+		if previous > 0 {
+			returns = append(returns, point.NAV/previous-1)
+		}
+		previous = point.NAV
+	}
+
+	return returns
+}
+
+// riskAdjustedReturns returns the annualised Sharpe and Sortino ratios of
+// per-period returns, with a risk free rate of zero. A ratio is zero when
+// there is no variation to divide by.
+func riskAdjustedReturns(returns []float64, periodsPerYear float64) (sharpe, sortino float64) {
+	if len(returns) < 2 {
+		return 0.0, 0.0
+	}
+
+	n := float64(len(returns))
+	mean := 0.0
+	for _, r := range returns {
+		mean += r / n
+	}
+
+	variance, downside := 0.0, 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean) / (n - 1)
+
+		if r < 0 {
+			downside += r * r / n
+		}
+	}
+
+	annualise := math.Sqrt(periodsPerYear)
+
+	if variance > 0 {
+		sharpe = mean / math.Sqrt(variance) * annualise
+	}
+
+	if downside > 0 {
+		sortino = mean / math.Sqrt(downside) * annualise
+	}
+
+	return sharpe, sortino
+}
+
+func fillsByMarket(fills []*Trade) map[string][]*Trade {
+	byMarket := make(map[string][]*Trade)
+
+	for _, fill := range fills {
+		byMarket[fill.Market] = append(byMarket[fill.Market], fill)
+	}
+
+	return byMarket
+}
+
+// countOrders counts the distinct orders of fills. A fill without an order id
+// is counted as an order of its own.
+func countOrders(fills []*Trade) int {
+	orders := make(map[string]bool)
+	count := 0
+
+	for _, fill := range fills {
+		if fill.OrderId == "" {
+			count += 1
+		} else if !orders[fill.OrderId] {
+			orders[fill.OrderId] = true
+			count += 1
+		}
+	}
+
+	return count
+}
+
+// averageHoldingTime weights the time each lot was held by its cost, so lots
+// of different markets can be compared.
+func averageHoldingTime(fills []*Trade) int64 {
+	held, cost := 0.0, 0.0
+
+	for name, marketFills := range fillsByMarket(fills) {
+		book := NewLotBook(name)
+
+		for _, fill := range marketFills {
+			if fill.Type == "buy" {
+				book.Buy(fill)
+			} else {
+				book.Sell(fill)
+			}
+		}
+
+		for _, d := range book.Disposals {
+			held += float64(d.Disposed-d.Acquired) * d.CostBasis.Float64()
+			cost += d.CostBasis.Float64()
+		}
+	}
+
+	if cost == 0 {
+		return 0
+	}
+
+	return int64(held / cost)
+}
+
+func (result *BacktestResult) WriteTable(w io.Writer) {
+	for _, metric := range result.Metrics() {
+		fmt.Fprintf(w, "%-24s %s\n", metric[0], metric[1])
+	}
+}
+
+// Metrics returns the name and formatted value of each metric, in the order
+// they are reported.
+func (result *BacktestResult) Metrics() [][2]string {
+	metrics := make([][2]string, 0)
+
+	row := func(name, format string, v ...interface{}) {
+		metrics = append(metrics, [2]string{name, fmt.Sprintf(format, v...)})
+	}
+
+	row("market", "%s", result.Market)
+	row("start", "%s", time.Unix(result.StartTime, 0).Format(time.RFC3339))
+	row("end", "%s", time.Unix(result.EndTime, 0).Format(time.RFC3339))
+	row("steps", "%d", result.Steps)
+	row("starting nav", "%0.9f", result.StartingNAV)
+	row("ending nav", "%0.9f", result.EndingNAV)
+	row("total return", "%0.2f%%", result.TotalReturn*100)
+	row("buy and hold return", "%0.2f%%", result.BuyAndHoldReturn*100)
+	row("max drawdown", "%0.2f%%", result.MaxDrawdown*100)
+	row("max drawdown duration", "%s", time.Duration(result.MaxDrawdownDuration)*time.Second)
+	row("sharpe ratio", "%0.3f", result.Sharpe)
+	row("sortino ratio", "%0.3f", result.Sortino)
+	row("trades", "%d", result.Trades)
+	row("win rate", "%0.2f%% (%d/%d)", result.WinRate*100, result.Wins, result.Wins+result.Losses)
+	row("avg holding time", "%s", time.Duration(result.AvgHoldingTime)*time.Second)
+	row("fees paid", "%0.9f", result.FeesPaid)
+	row("exposure", "%0.2f%%", result.Exposure*100)
+
+	if result.Errors > 0 {
+		row("errors", "%d", result.Errors)
+	}
+
+	return metrics
+}
+
+// WriteJSON writes the metrics, without the equity curve and fills.
+func (result *BacktestResult) WriteJSON(w io.Writer) error {
+	encoded, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(encoded, '\n'))
+	return err
+}
@@ -0,0 +1,139 @@
+package trading
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jbgo/sftbot/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+)
+
+// oscillatingCandles returns n five minute candles whose price swings 10%
+// either side of 0.001 once a day, which the default config trades.
+func oscillatingCandles(start int64, n int) []*SummaryData {
+	candles := make([]*SummaryData, 0, n)
+
+	for i := 0; i < n; i += 1 {
+		price := 0.001 * (1 + 0.1*math.Sin(2*math.Pi*float64(i)/288))
+
+		candles = append(candles, &SummaryData{
+			Date:            start + int64(i*300),
+			High:            price,
+			Low:             price,
+			Open:            price,
+			Close:           price,
+			Volume:          price * 1000,
+			QuoteVolume:     1000,
+			WeightedAverage: price,
+		})
+	}
+
+	return candles
+}
+
+func TestBacktest(t *testing.T) {
+	candles := oscillatingCandles(0, 288*6)
+
+	backtest := &Backtest{
+		Market:          "BTC_ABC",
+		Config:          DefaultTraderConfig(),
+		Candles:         candles,
+		Resolution:      300,
+		StartTime:       86400,
+		EndTime:         candles[len(candles)-1].Date,
+		StartingBalance: 0.1,
+		Fees:            FeeSchedule{Maker: 0.0015, Taker: 0.0025},
+	}
+
+	result, err := backtest.Run()
+	require.Nil(t, err)
+
+	assert.Equal(t, 288*5, result.Steps)
+	assert.Equal(t, int64(86400), result.StartTime)
+	assert.Equal(t, 0.1, result.StartingNAV)
+	assert.True(t, result.Trades > 0)
+	assert.True(t, result.Trades <= len(result.Fills))
+	assert.Equal(t, 0, result.Errors)
+
+	fees := 0.0
+	for _, fill := range result.Fills {
+		assert.True(t, fill.Date >= backtest.StartTime)
+		fees += fill.Fee.Float64()
+	}
+	assert.InDelta(t, fees, result.FeesPaid, 1e-12)
+
+	last := result.Equity[len(result.Equity)-1]
+	assert.InDelta(t, last.Base+last.Alt*last.Price, result.EndingNAV, 1e-12)
+	assert.InDelta(t, result.EndingNAV/0.1-1, result.TotalReturn, 1e-12)
+	assert.True(t, result.Exposure > 0 && result.Exposure <= 1)
+
+	t.Run("without chart data in range", func(t *testing.T) {
+		empty := *backtest
+		empty.StartTime = candles[len(candles)-1].Date + 1
+		empty.EndTime = empty.StartTime + 86400
+
+		_, err := empty.Run()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("JSON omits the equity curve", func(t *testing.T) {
+		var buffer bytes.Buffer
+		require.Nil(t, result.WriteJSON(&buffer))
+
+		decoded := make(map[string]interface{})
+		require.Nil(t, json.Unmarshal(buffer.Bytes(), &decoded))
+
+		assert.Equal(t, "BTC_ABC", decoded["Market"])
+		assert.Equal(t, float64(result.Trades), decoded["Trades"])
+		assert.NotContains(t, decoded, "Equity")
+	})
+}
+
+func TestNewBacktestResult(t *testing.T) {
+	equity := []*EquityPoint{
+		&EquityPoint{Date: 300, Price: 1.0, Base: 1.0, NAV: 1.0},
+		&EquityPoint{Date: 600, Price: 1.0, Base: 0.0, Alt: 1.2, NAV: 1.2},
+		&EquityPoint{Date: 900, Price: 0.75, Base: 0.0, Alt: 1.2, NAV: 0.9},
+		&EquityPoint{Date: 1200, Price: 0.9, Base: 0.0, Alt: 1.2, NAV: 1.08},
+		&EquityPoint{Date: 1500, Price: 1.5, Base: 1.5, NAV: 1.5},
+	}
+
+	fills := []*Trade{
+		// one buy order filled in two parts
+		&Trade{Id: "1", OrderId: "bt1", Date: 600, Type: "buy", Price: data.NewDecimal(0.8), Amount: data.NewDecimal(0.5), Total: data.NewDecimal(0.4), Fee: data.NewDecimal(0.004)},
+		&Trade{Id: "2", OrderId: "bt1", Date: 600, Type: "buy", Price: data.NewDecimal(0.8), Amount: data.NewDecimal(0.7), Total: data.NewDecimal(0.56), Fee: data.NewDecimal(0.006)},
+		&Trade{Id: "3", OrderId: "bt2", Date: 1500, Type: "sell", Price: data.NewDecimal(1.3), Amount: data.NewDecimal(1.2), Total: data.NewDecimal(1.56), Fee: data.NewDecimal(0.02)},
+	}
+
+	result := NewBacktestResult("BTC_ABC", 300, 1.0, equity, fills)
+
+	assert.InDelta(t, 0.5, result.TotalReturn, 1e-12)
+	assert.InDelta(t, 0.5, result.BuyAndHoldReturn, 1e-12)
+	assert.InDelta(t, 0.25, result.MaxDrawdown, 1e-12)
+	assert.Equal(t, int64(600), result.MaxDrawdownDuration)
+	assert.Equal(t, 2, result.Trades)
+	assert.Equal(t, 1, result.Wins)
+	assert.Equal(t, 1.0, result.WinRate)
+	assert.Equal(t, int64(900), result.AvgHoldingTime)
+	assert.InDelta(t, 0.03, result.FeesPaid, 1e-12)
+	assert.InDelta(t, 0.6, result.Exposure, 1e-12)
+	assert.True(t, result.Sharpe > 0)
+	assert.True(t, result.Sortino > result.Sharpe)
+
+	var buffer bytes.Buffer
+	result.WriteTable(&buffer)
+	assert.Contains(t, buffer.String(), "max drawdown duration    10m0s")
+}
+
+func TestRiskAdjustedReturns(t *testing.T) {
+	sharpe, sortino := riskAdjustedReturns([]float64{0.01, 0.01, 0.01}, 365)
+	assert.Equal(t, 0.0, sharpe)
+	assert.Equal(t, 0.0, sortino)
+
+	// mean 0.01, sample std dev sqrt(0.0008), downside deviation sqrt(0.0001 / 2)
+	sharpe, sortino = riskAdjustedReturns([]float64{0.03, -0.01}, 4)
+	assert.InDelta(t, 0.02/math.Sqrt(0.0008), sharpe, 1e-12)
+	assert.InDelta(t, 0.02/math.Sqrt(0.00005), sortino, 1e-12)
+}
@@ -0,0 +1,76 @@
+package trading
+
+import (
+	"fmt"
+	"github.com/jbgo/sftbot/bittrex"
+)
+
+type BittrexExchange struct {
+	Client *bittrex.Client
+}
+
+func NewBittrexExchange(client *bittrex.Client) Exchange {
+	return &BittrexExchange{Client: client}
+}
+
+func (exchange *BittrexExchange) GetMarket(marketName string) (Market, error) {
+	symbol, err := ParseSymbol(marketName)
+	if err != nil {
+		return nil, err
+	}
+
+	market := &BittrexMarket{Symbol: symbol, Exchange: exchange}
+
+	if !market.Exists() {
+		return nil, fmt.Errorf("unknown market: %s", marketName)
+	}
+
+	return market, nil
+}
+
+// GetBalance counts everything that is not available as on orders, which
+// includes pending deposits.
+func (exchange *BittrexExchange) GetBalance(currency string) (*Balance, error) {
+	balance, err := exchange.Client.GetBalance(currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Balance{
+		Available: balance.Available,
+		OnOrders:  balance.Balance - balance.Available,
+	}, nil
+}
+
+func (exchange *BittrexExchange) GetTicker() (map[string]*TickerEntry, error) {
+	summaries, err := exchange.Client.GetMarketSummaries()
+	if err != nil {
+		return nil, err
+	}
+
+	ticker := make(map[string]*TickerEntry, len(summaries))
+
+	for _, s := range summaries {
+		symbol, err := BITTREX_SYMBOLS.ParseExchangeSymbol(s.MarketName)
+		if err != nil {
+			continue
+		}
+
+		entry := &TickerEntry{
+			Market:      symbol.String(),
+			Last:        s.Last,
+			LowestAsk:   s.Ask,
+			HighestBid:  s.Bid,
+			BaseVolume:  s.BaseVolume,
+			QuoteVolume: s.Volume,
+		}
+
+		if s.PrevDay > 0 {
+			entry.PercentChange = s.Last/s.PrevDay - 1
+		}
+
+		ticker[entry.Market] = entry
+	}
+
+	return ticker, nil
+}
@@ -0,0 +1,155 @@
+package trading
+
+import (
+	"fmt"
+	"github.com/jbgo/sftbot/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// bittrexStub answers each API path with a canned result, and records the
+// query of every request.
+func bittrexStub(results map[string]string, queries map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, ok := results[r.URL.Path]
+		if !ok {
+			fmt.Fprintln(w, `{"success":false,"message":"INVALID_PATH","result":null}`)
+			return
+		}
+
+		if queries != nil {
+			queries[r.URL.Path] = r.URL.RawQuery
+		}
+
+		fmt.Fprintf(w, `{"success":true,"message":"","result":%s}`, result)
+	}))
+}
+
+const bittrexSummaries = `[
+	{"MarketName":"BTC-ABC","High":0.11,"Low":0.09,"Volume":5000,"Last":0.1,"BaseVolume":500,"Bid":0.099,"Ask":0.101,"PrevDay":0.08},
+	{"MarketName":"USDT-BTC","Last":7000,"BaseVolume":1000000,"Bid":6999,"Ask":7001,"PrevDay":7000}
+]`
+
+func TestBittrexExchange(t *testing.T) {
+	queries := make(map[string]string)
+
+	testServer := bittrexStub(map[string]string{
+		"/api/v1.1/public/getmarketsummaries": bittrexSummaries,
+		"/api/v1.1/account/getbalance":        `{"Currency":"ABC","Balance":3.5,"Available":2.5,"Pending":0}`,
+		"/api/v1.1/market/buylimit":           `{"uuid":"e606d53c-8d70-11e3-94b5-425861b86ab6"}`,
+		"/api/v1.1/market/getopenorders": `[
+			{"OrderUuid":"09aa5bb6","Exchange":"BTC-ABC","OrderType":"LIMIT_SELL","Quantity":5,"QuantityRemaining":4,"Limit":0.12}
+		]`,
+		"/api/v1.1/account/getorder": `{"OrderUuid":"0cb4c4e4","Exchange":"BTC-ABC","Type":"LIMIT_BUY","Quantity":2,"QuantityRemaining":0.5,
+			"Limit":0.1,"CommissionPaid":0.00037,"Price":0.1485,"PricePerUnit":0.099,"Opened":"2017-05-04T10:00:00.5","Closed":"2017-05-04T10:05:00"}`,
+		"/Api/v2.0/pub/market/GetTicks": `[
+			{"O":0.1,"H":0.11,"L":0.09,"C":0.105,"V":100,"BV":10.2,"T":"2017-05-04T10:00:00"},
+			{"O":0.105,"H":0.11,"L":0.1,"C":0.1,"V":0,"BV":0,"T":"2017-05-04T10:05:00"},
+			{"O":0.1,"H":0.1,"L":0.1,"C":0.1,"V":1,"BV":0.1,"T":"2017-05-04T10:10:00"}
+		]`,
+	}, queries)
+
+	defer testServer.Close()
+
+	exchange, err := NewExchange(&ExchangeConfig{Name: EXCHANGE_BITTREX, BaseUrl: testServer.URL, CredentialsPath: credentialsPath()})
+	require.Nil(t, err)
+	require.IsType(t, &BittrexExchange{}, exchange)
+
+	t.Run("ticker uses canonical names", func(t *testing.T) {
+		ticker, err := exchange.GetTicker()
+		require.Nil(t, err)
+		require.Equal(t, 2, len(ticker))
+
+		abc := ticker["BTC_ABC"]
+		require.NotNil(t, abc)
+		assert.Equal(t, "BTC_ABC", abc.Market)
+		assert.Equal(t, 0.101, abc.LowestAsk)
+		assert.Equal(t, 0.099, abc.HighestBid)
+		assert.Equal(t, 500.0, abc.BaseVolume)
+		assert.InDelta(t, 0.25, abc.PercentChange, 1e-12)
+		assert.NotNil(t, ticker["USDT_BTC"])
+	})
+
+	t.Run("balance", func(t *testing.T) {
+		balance, err := exchange.GetBalance("ABC")
+		require.Nil(t, err)
+		assert.Equal(t, data.NewDecimal(2.5), balance.Available)
+		assert.Equal(t, data.NewDecimal(1.0), balance.OnOrders)
+		assert.Contains(t, queries["/api/v1.1/account/getbalance"], "currency=ABC")
+		assert.Contains(t, queries["/api/v1.1/account/getbalance"], "apikey=")
+	})
+
+	_, err = exchange.GetMarket("BTC_DNE")
+	assert.NotNil(t, err)
+
+	market, err := exchange.GetMarket("BTC_ABC")
+	require.Nil(t, err)
+	assert.Equal(t, "BTC_ABC", market.GetName())
+	assert.Equal(t, "BTC", market.GetBaseCurrency())
+	assert.Equal(t, "ABC", market.GetCurrency())
+
+	t.Run("orders use the exchange's market names", func(t *testing.T) {
+		order := &Order{Type: "buy", Price: data.NewDecimal(0.1), Amount: data.NewDecimal(2)}
+		require.Nil(t, market.Buy(order))
+		assert.Equal(t, "e606d53c-8d70-11e3-94b5-425861b86ab6", order.Id)
+		assert.Contains(t, queries["/api/v1.1/market/buylimit"], "market=BTC-ABC")
+		assert.Contains(t, queries["/api/v1.1/market/buylimit"], "quantity=2")
+		assert.Contains(t, queries["/api/v1.1/market/buylimit"], "rate=0.1")
+
+		pending, err := market.GetPendingOrders()
+		require.Nil(t, err)
+		require.Equal(t, 1, len(pending))
+		assert.Equal(t, "sell", pending[0].Type)
+		assert.Equal(t, data.NewDecimal(4.0), pending[0].Amount)
+		assert.Equal(t, data.NewDecimal(0.48), pending[0].Total)
+	})
+
+	t.Run("the filled part of an order is one trade", func(t *testing.T) {
+		trades, err := market.GetOrderTrades(&Order{Id: "0cb4c4e4"})
+		require.Nil(t, err)
+		require.Equal(t, 1, len(trades))
+
+		trade := trades[0]
+		assert.Equal(t, "BTC_ABC", trade.Market)
+		assert.Equal(t, "buy", trade.Type)
+		assert.Equal(t, data.NewDecimal(1.5), trade.Amount)
+		assert.Equal(t, data.NewDecimal(0.099), trade.Price)
+		assert.Equal(t, data.NewDecimal(0.1485), trade.Total)
+		assert.Equal(t, data.NewDecimal(0.00037), trade.Fee)
+		assert.Equal(t, int64(1493892300), trade.Date)
+		assert.Equal(t, EXCHANGE_BITTREX, trade.Exchange)
+	})
+
+	t.Run("summary data", func(t *testing.T) {
+		candles, err := market.GetSummaryData(1493892000, 1493892300)
+		require.Nil(t, err)
+		require.Equal(t, 2, len(candles))
+		assert.Equal(t, int64(1493892000), candles[0].Date)
+		assert.InDelta(t, 0.102, candles[0].WeightedAverage, 1e-12)
+		assert.Equal(t, 10.2, candles[0].Volume)
+		assert.Equal(t, 0.1, candles[1].WeightedAverage)
+		assert.Contains(t, queries["/Api/v2.0/pub/market/GetTicks"], "marketName=BTC-ABC")
+	})
+
+	t.Run("current price of a market missing from the ticker", func(t *testing.T) {
+		missing := &BittrexMarket{Symbol: NewSymbol("BTC", "DNE"), Exchange: exchange.(*BittrexExchange)}
+		_, err := missing.GetCurrentPrice()
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "unknown market: BTC_DNE")
+	})
+}
+
+func TestExchangeRegistry(t *testing.T) {
+	assert.Equal(t, []string{EXCHANGE_BITTREX, EXCHANGE_POLONIEX}, ExchangeNames())
+
+	exchange, err := NewExchange(&ExchangeConfig{Name: EXCHANGE_POLONIEX})
+	require.Nil(t, err)
+	assert.IsType(t, &PlxExchange{}, exchange)
+
+	_, err = NewExchange(&ExchangeConfig{Name: "nasdaq"})
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unknown exchange")
+}
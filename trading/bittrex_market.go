@@ -0,0 +1,202 @@
+package trading
+
+import (
+	"fmt"
+	"github.com/jbgo/sftbot/bittrex"
+	"strings"
+	"time"
+)
+
+type BittrexMarket struct {
+	Symbol   Symbol
+	Exchange *BittrexExchange
+}
+
+// exchangeName is the name of the market in the Bittrex API.
+func (market *BittrexMarket) exchangeName() string {
+	return BITTREX_SYMBOLS.ExchangeSymbol(market.Symbol)
+}
+
+func (market *BittrexMarket) Buy(order *Order) error {
+	if order.Execution != "" {
+		return fmt.Errorf("%s orders are not supported", order.Execution)
+	}
+
+	result, err := market.Exchange.Client.BuyLimit(market.exchangeName(), order.Price, order.Amount)
+	if err != nil {
+		return err
+	}
+
+	order.Id = result.Uuid
+
+	return nil
+}
+
+func (market *BittrexMarket) Sell(order *Order) error {
+	if order.Execution != "" {
+		return fmt.Errorf("%s orders are not supported", order.Execution)
+	}
+
+	result, err := market.Exchange.Client.SellLimit(market.exchangeName(), order.Price, order.Amount)
+	if err != nil {
+		return err
+	}
+
+	order.Id = result.Uuid
+
+	return nil
+}
+
+func (market *BittrexMarket) Exists() bool {
+	ticker, err := market.Exchange.GetTicker()
+	if err != nil {
+		return false
+	}
+
+	_, ok := ticker[market.GetName()]
+
+	return ok
+}
+
+func (market *BittrexMarket) GetBaseCurrency() string {
+	return market.Symbol.Base
+}
+
+func (market *BittrexMarket) GetCurrency() string {
+	return market.Symbol.Currency
+}
+
+func (market *BittrexMarket) GetCurrentPrice() (float64, error) {
+	ticker, err := market.Exchange.GetTicker()
+	if err != nil {
+		return 0.0, err
+	}
+
+	entry, ok := ticker[market.GetName()]
+	if !ok {
+		return 0.0, fmt.Errorf("unknown market: %s", market.GetName())
+	}
+
+	return entry.Last, nil
+}
+
+func (market *BittrexMarket) GetInfo() (*MarketInfo, error) {
+	return NewBittrexMarketInfo(), nil
+}
+
+func (market *BittrexMarket) GetName() string {
+	return market.Symbol.String()
+}
+
+// GetOrderTrades reports what has been filled of the order as one trade,
+// because Bittrex does not report individual fills.
+func (market *BittrexMarket) GetOrderTrades(order *Order) ([]*Trade, error) {
+	bittrexOrder, err := market.Exchange.Client.GetOrder(order.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	trades := make([]*Trade, 0, 1)
+
+	filled := bittrexOrder.Quantity - bittrexOrder.QuantityRemaining
+	if filled <= 0 {
+		return trades, nil
+	}
+
+	date := bittrexOrder.Closed
+	if len(date) == 0 {
+		date = bittrexOrder.Opened
+	}
+
+	return append(trades, &Trade{
+		Id:       bittrexOrder.OrderUuid,
+		OrderId:  bittrexOrder.OrderUuid,
+		Market:   market.GetName(),
+		Date:     parseBittrexDate(date),
+		Type:     bittrexOrderType(bittrexOrder),
+		Price:    bittrexOrder.PricePerUnit,
+		Amount:   filled,
+		Total:    bittrexOrder.Price,
+		Fee:      bittrexOrder.CommissionPaid,
+		Exchange: EXCHANGE_BITTREX,
+		Metadata: bittrexOrder,
+	}), nil
+}
+
+func (market *BittrexMarket) GetPendingOrders() ([]*Order, error) {
+	bittrexOrders, err := market.Exchange.Client.GetOpenOrders(market.exchangeName())
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make([]*Order, 0, len(bittrexOrders))
+	for _, o := range bittrexOrders {
+		orders = append(orders, &Order{
+			Id:     o.OrderUuid,
+			Type:   bittrexOrderType(o),
+			Price:  o.Limit,
+			Amount: o.QuantityRemaining,
+			Total:  o.Limit.Mul(o.QuantityRemaining),
+			Filled: false,
+		})
+	}
+
+	return orders, nil
+}
+
+// GetSummaryData can only return the candles of the last few days, which is
+// all the API keeps.
+func (market *BittrexMarket) GetSummaryData(startTime, endTime int64) ([]*SummaryData, error) {
+	ticks, err := market.Exchange.Client.GetTicks(market.exchangeName(), bittrex.FIVE_MINUTES)
+	if err != nil {
+		return nil, err
+	}
+
+	summaryData := make([]*SummaryData, 0, len(ticks))
+
+	for _, t := range ticks {
+		date := parseBittrexDate(t.T)
+		if date < startTime || date > endTime {
+			continue
+		}
+
+		s := &SummaryData{
+			Date:            date,
+			High:            t.H,
+			Low:             t.L,
+			Open:            t.O,
+			Close:           t.C,
+			Volume:          t.BV,
+			QuoteVolume:     t.V,
+			WeightedAverage: t.C,
+		}
+
+		if t.V > 0 {
+			s.WeightedAverage = t.BV / t.V
+		}
+
+		summaryData = append(summaryData, s)
+	}
+
+	return summaryData, nil
+}
+
+func parseBittrexDate(date string) int64 {
+	parsed, _ := time.Parse(bittrex.DATE_FORMAT, date)
+	return parsed.Unix()
+}
+
+// bittrexOrderType maps LIMIT_BUY and LIMIT_SELL to buy and sell. Open orders
+// report it as OrderType and closed orders as Type.
+func bittrexOrderType(order *bittrex.Order) string {
+	orderType := order.OrderType
+	if len(orderType) == 0 {
+		orderType = order.Type
+	}
+
+	if strings.HasSuffix(orderType, "SELL") {
+		return "sell"
+	}
+
+	return "buy"
+}
@@ -0,0 +1,85 @@
+package trading
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jbgo/sftbot/bittrex"
+	"github.com/jbgo/sftbot/plx"
+	"io/ioutil"
+	"sort"
+)
+
+const EXCHANGE_POLONIEX = "poloniex"
+const EXCHANGE_BITTREX = "bittrex"
+
+// ExchangeConfig selects an exchange adapter by Name. BaseUrl and
+// CredentialsPath default to the live API of the exchange, and can point at
+// a stub or a sandbox instead.
+type ExchangeConfig struct {
+	Name            string
+	BaseUrl         string
+	CredentialsPath string
+}
+
+type ExchangeFactory func(config *ExchangeConfig) (Exchange, error)
+
+var exchangeFactories = map[string]ExchangeFactory{
+	EXCHANGE_POLONIEX: func(config *ExchangeConfig) (Exchange, error) {
+		client := plx.NewLiveClient()
+		config.apply(&client.BaseUrl, &client.CredentialsPath)
+		return NewPlxExchange(client), nil
+	},
+	EXCHANGE_BITTREX: func(config *ExchangeConfig) (Exchange, error) {
+		client := bittrex.NewLiveClient()
+		config.apply(&client.BaseUrl, &client.CredentialsPath)
+		return NewBittrexExchange(client), nil
+	},
+}
+
+// RegisterExchange makes an adapter available to NewExchange under name.
+func RegisterExchange(name string, factory ExchangeFactory) {
+	exchangeFactories[name] = factory
+}
+
+func NewExchange(config *ExchangeConfig) (Exchange, error) {
+	factory, ok := exchangeFactories[config.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown exchange: %s (choices: %v)", config.Name, ExchangeNames())
+	}
+
+	return factory(config)
+}
+
+func ExchangeNames() []string {
+	names := make([]string, 0, len(exchangeFactories))
+	for name := range exchangeFactories {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// LoadExchangeConfigFile reads an exchange config from a JSON file.
+func LoadExchangeConfigFile(path string) (*ExchangeConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &ExchangeConfig{}
+	err = json.Unmarshal(data, config)
+
+	return config, err
+}
+
+func (config *ExchangeConfig) apply(baseUrl, credentialsPath *string) {
+	if len(config.BaseUrl) > 0 {
+		*baseUrl = config.BaseUrl
+	}
+
+	if len(config.CredentialsPath) > 0 {
+		*credentialsPath = config.CredentialsPath
+	}
+}
@@ -21,3 +21,12 @@ func (exchange *FakeExchange) GetBalance(currency string) (*Balance, error) {
 	balance, _ := exchange.Balances[currency]
 	return balance, nil
 }
+
+func (exchange *FakeExchange) GetTicker() (map[string]*TickerEntry, error) {
+	price, _ := exchange.Market.GetCurrentPrice()
+	name := exchange.Market.GetName()
+
+	return map[string]*TickerEntry{
+		name: &TickerEntry{Market: name, Last: price, LowestAsk: price, HighestBid: price},
+	}, nil
+}
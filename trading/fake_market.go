@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"math/rand"
 	"strconv"
-	"strings"
 )
 
 /**
@@ -19,16 +18,26 @@ type FakeMarket struct {
 	CurrentPrice     float64
 	SummaryData      []*SummaryData
 	PendingOrders    []*Order
+	OrderTrades      map[string][]*Trade
 	TriggerBuyError  bool
 	TriggerSellError bool
+	// Orders are cancelled as soon as they are placed, like immediate
+	// orders with nothing to fill against.
+	TriggerCancel bool
+	// Returned by GetInfo, so orders are not rounded unless it is set.
+	Info *MarketInfo
 }
 
 func (market *FakeMarket) GetName() string {
 	return market.Name
 }
 
+func (market *FakeMarket) GetBaseCurrency() string {
+	return marketSymbol(market.Name).Base
+}
+
 func (market *FakeMarket) GetCurrency() string {
-	return strings.Split(market.Name, "_")[1]
+	return marketSymbol(market.Name).Currency
 }
 
 func (market *FakeMarket) Exists() bool {
@@ -39,6 +48,10 @@ func (market *FakeMarket) GetCurrentPrice() (float64, error) {
 	return market.CurrentPrice, nil
 }
 
+func (market *FakeMarket) GetInfo() (*MarketInfo, error) {
+	return market.Info, nil
+}
+
 func (market *FakeMarket) GetSummaryData(startTime, endTime int64) ([]*SummaryData, error) {
 	return market.SummaryData, nil
 }
@@ -47,11 +60,19 @@ func (market *FakeMarket) GetPendingOrders() ([]*Order, error) {
 	return market.PendingOrders, nil
 }
 
+func (market *FakeMarket) GetOrderTrades(order *Order) ([]*Trade, error) {
+	return market.OrderTrades[order.Id], nil
+}
+
 func (market *FakeMarket) Buy(order *Order) error {
 	if market.TriggerBuyError {
 		return fmt.Errorf("fake buy error")
 	}
 
+	if market.TriggerCancel {
+		return &OrderCancelledError{Order: order, Reason: "did not fill"}
+	}
+
 	order.Id = strconv.FormatInt(rand.Int63(), 10)
 	return nil
 }
@@ -61,6 +82,10 @@ func (market *FakeMarket) Sell(order *Order) error {
 		return fmt.Errorf("fake sell error")
 	}
 
+	if market.TriggerCancel {
+		return &OrderCancelledError{Order: order, Reason: "did not fill"}
+	}
+
 	order.Id = strconv.FormatInt(rand.Int63(), 10)
 	return nil
 }
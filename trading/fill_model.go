@@ -0,0 +1,140 @@
+package trading
+
+import (
+	"github.com/jbgo/sftbot/data"
+	"math"
+)
+
+// Fill models for backtests, see FillModel.
+const FILL_IMMEDIATE = "immediate"
+const FILL_CANDLE = "candle"
+
+// Poloniex's fees for the lowest volume tier.
+const SIMULATED_MAKER_FEE = 0.0015
+const SIMULATED_TAKER_FEE = 0.0025
+
+// FeeSchedule is the fee charged on a fill as a fraction of its total. Maker
+// fills rested on the order book, taker fills crossed it.
+type FeeSchedule struct {
+	Maker float64
+	Taker float64
+}
+
+// SimulatedOrder is an order on the book of a SimulatedExchange.
+type SimulatedOrder struct {
+	*Order
+	Market *SimulatedMarket
+	// Candles that opened since the order was placed, so zero when it is
+	// placed.
+	Candles   int
+	Remaining data.Decimal
+	// Balance held on orders for what is left of the order, in the base
+	// currency for a buy and in the alt currency for a sell.
+	Reserved data.Decimal
+	// Set by the fill model when the exchange would cancel the rest of the
+	// order, see Order.Execution.
+	Cancelled bool
+}
+
+type SimulatedFill struct {
+	Price  data.Decimal
+	Amount data.Decimal
+	Taker  bool
+}
+
+// FillModel decides when and at what price simulated orders fill. Fill is
+// called with the current candle when an order is placed, then with every
+// candle that opens while the order is still on the book. It returns nil
+// when nothing fills during the candle, and sets order.Cancelled when what is
+// left of the order should leave the book afterwards.
+type FillModel interface {
+	Fill(order *SimulatedOrder, candle *SummaryData) *SimulatedFill
+}
+
+// ImmediateFill fills every order in full at its own price as soon as it is
+// placed, as a taker, or as a maker for post-only orders. It is the most
+// optimistic model: a buy placed below the market price would not really fill
+// until the price came down to it.
+type ImmediateFill struct{}
+
+func (model *ImmediateFill) Fill(order *SimulatedOrder, candle *SummaryData) *SimulatedFill {
+	return &SimulatedFill{Price: order.Price, Amount: order.Remaining, Taker: order.Execution != ORDER_POST_ONLY}
+}
+
+// CandleFill treats orders as limit orders that wait for the price to reach
+// them. An order reaches the book Latency candles after the one it was placed
+// in. If it crosses the book on arrival, i.e. a buy priced at or above the
+// open of that candle, it fills as a taker at the open plus Slippage, never
+// beyond its limit price. Otherwise it rests on the book and fills as a maker
+// at its own price in the first candle whose range reaches it.
+//
+// A post-only order that would cross the book on arrival is cancelled.
+// Immediate orders only fill if they cross the book on arrival, and are then
+// cancelled; a fill-or-kill order only if it fills in full.
+type CandleFill struct {
+	Latency int
+	// Fraction of the price by which taker fills are worse than the open.
+	Slippage float64
+	// Largest fraction of a candle's volume one order can fill during that
+	// candle. Zero for no limit.
+	Participation float64
+}
+
+func (model *CandleFill) Fill(order *SimulatedOrder, candle *SummaryData) *SimulatedFill {
+	if order.Candles <= model.Latency {
+		return nil
+	}
+
+	fill := &SimulatedFill{Price: order.Price, Amount: order.Remaining}
+	arrived := order.Candles == model.Latency+1
+
+	price := order.Price.Float64()
+
+	crosses := price <= candle.Open
+	if order.Type == "buy" {
+		crosses = price >= candle.Open
+	}
+
+	if arrived && crosses && order.Execution == ORDER_POST_ONLY {
+		order.Cancelled = true
+		return nil
+	}
+
+	if arrived && order.Immediate() {
+		order.Cancelled = true
+
+		if !crosses {
+			return nil
+		}
+	}
+
+	if order.Type == "buy" {
+		if arrived && crosses {
+			fill.Price = data.NewDecimal(math.Min(candle.Open*(1+model.Slippage), price))
+			fill.Taker = true
+		} else if candle.Low > price {
+			return nil
+		}
+	} else {
+		if arrived && crosses {
+			fill.Price = data.NewDecimal(math.Max(candle.Open*(1-model.Slippage), price))
+			fill.Taker = true
+		} else if candle.High < price {
+			return nil
+		}
+	}
+
+	// QuoteVolume is the volume in the alt currency, like order amounts
+	if model.Participation > 0 {
+		available := data.NewDecimal(candle.QuoteVolume * model.Participation)
+		if available < fill.Amount {
+			fill.Amount = available
+		}
+	}
+
+	if fill.Amount <= 0 || (order.Execution == ORDER_FILL_OR_KILL && fill.Amount < order.Remaining) {
+		return nil
+	}
+
+	return fill
+}
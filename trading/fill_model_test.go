@@ -0,0 +1,127 @@
+package trading
+
+import (
+	"github.com/jbgo/sftbot/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestImmediateFill(t *testing.T) {
+	order := &SimulatedOrder{
+		Order:     &Order{Type: "buy", Price: data.NewDecimal(0.1), Amount: data.NewDecimal(2.0)},
+		Remaining: data.NewDecimal(2.0),
+	}
+
+	fill := (&ImmediateFill{}).Fill(order, &SummaryData{Low: 0.2, High: 0.3})
+
+	assert.Equal(t, &SimulatedFill{Price: data.NewDecimal(0.1), Amount: data.NewDecimal(2.0), Taker: true}, fill)
+
+	order.Execution = ORDER_POST_ONLY
+	fill = (&ImmediateFill{}).Fill(order, &SummaryData{Low: 0.2, High: 0.3})
+	assert.False(t, fill.Taker)
+}
+
+func TestCandleFill(t *testing.T) {
+	candle := &SummaryData{Open: 0.10, High: 0.12, Low: 0.09, QuoteVolume: 100}
+
+	order := func(orderType string, price float64, candles int) *SimulatedOrder {
+		return &SimulatedOrder{
+			Order:     &Order{Type: orderType, Price: data.NewDecimal(price), Amount: data.NewDecimal(10.0)},
+			Candles:   candles,
+			Remaining: data.NewDecimal(10.0),
+		}
+	}
+
+	t.Run("never fills in the candle it was placed in", func(t *testing.T) {
+		model := &CandleFill{}
+		assert.Nil(t, model.Fill(order("buy", 0.2, 0), candle))
+	})
+
+	t.Run("resting orders fill at their price when the range reaches it", func(t *testing.T) {
+		model := &CandleFill{}
+
+		fill := &SimulatedFill{Price: data.NewDecimal(0.095), Amount: data.NewDecimal(10.0)}
+		assert.Equal(t, fill, model.Fill(order("buy", 0.095, 1), candle))
+		assert.Nil(t, model.Fill(order("buy", 0.085, 1), candle))
+		fill = &SimulatedFill{Price: data.NewDecimal(0.115), Amount: data.NewDecimal(10.0)}
+		assert.Equal(t, fill, model.Fill(order("sell", 0.115, 3), candle))
+		assert.Nil(t, model.Fill(order("sell", 0.125, 3), candle))
+	})
+
+	t.Run("orders crossing the book on arrival are takers", func(t *testing.T) {
+		model := &CandleFill{Slippage: 0.01}
+
+		fill := model.Fill(order("buy", 0.2, 1), candle)
+		require.NotNil(t, fill)
+		assert.True(t, fill.Taker)
+		assert.Equal(t, data.NewDecimal(0.101), fill.Price)
+
+		// slippage never goes past the limit price
+		fill = model.Fill(order("sell", 0.0995, 1), candle)
+		require.NotNil(t, fill)
+		assert.True(t, fill.Taker)
+		assert.Equal(t, data.NewDecimal(0.0995), fill.Price)
+
+		// after arrival the same order rests on the book
+		fill = model.Fill(order("buy", 0.2, 2), candle)
+		require.NotNil(t, fill)
+		assert.False(t, fill.Taker)
+		assert.Equal(t, data.NewDecimal(0.2), fill.Price)
+	})
+
+	t.Run("latency", func(t *testing.T) {
+		model := &CandleFill{Latency: 2}
+
+		assert.Nil(t, model.Fill(order("buy", 0.2, 2), candle))
+		assert.True(t, model.Fill(order("buy", 0.2, 3), candle).Taker)
+	})
+
+	t.Run("participation", func(t *testing.T) {
+		model := &CandleFill{Participation: 0.05}
+
+		assert.Equal(t, data.NewDecimal(5.0), model.Fill(order("buy", 0.095, 1), candle).Amount)
+		assert.Nil(t, model.Fill(order("buy", 0.095, 1), &SummaryData{Open: 0.1, High: 0.1, Low: 0.09}))
+	})
+
+	t.Run("post-only orders that would cross the book are cancelled", func(t *testing.T) {
+		model := &CandleFill{}
+
+		crossing := order("buy", 0.2, 1)
+		crossing.Execution = ORDER_POST_ONLY
+		assert.Nil(t, model.Fill(crossing, candle))
+		assert.True(t, crossing.Cancelled)
+
+		resting := order("buy", 0.095, 1)
+		resting.Execution = ORDER_POST_ONLY
+		fill := model.Fill(resting, candle)
+		require.NotNil(t, fill)
+		assert.False(t, fill.Taker)
+		assert.False(t, resting.Cancelled)
+	})
+
+	t.Run("immediate orders only fill on arrival", func(t *testing.T) {
+		model := &CandleFill{Participation: 0.05}
+
+		ioc := order("buy", 0.2, 1)
+		ioc.Execution = ORDER_IMMEDIATE_OR_CANCEL
+		fill := model.Fill(ioc, candle)
+		require.NotNil(t, fill)
+		assert.Equal(t, data.NewDecimal(5.0), fill.Amount)
+		assert.True(t, ioc.Cancelled)
+
+		resting := order("buy", 0.095, 1)
+		resting.Execution = ORDER_IMMEDIATE_OR_CANCEL
+		assert.Nil(t, model.Fill(resting, candle))
+		assert.True(t, resting.Cancelled)
+
+		fok := order("buy", 0.2, 1)
+		fok.Execution = ORDER_FILL_OR_KILL
+		assert.Nil(t, model.Fill(fok, candle))
+		assert.True(t, fok.Cancelled)
+
+		fok = order("buy", 0.2, 1)
+		fok.Execution = ORDER_FILL_OR_KILL
+		assert.Equal(t, data.NewDecimal(10.0), (&CandleFill{}).Fill(fok, candle).Amount)
+	})
+}
@@ -0,0 +1,422 @@
+package trading
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// Charts are downsampled to at most this many points per series, so reports
+// over months of 5 minute candles stay small enough for a browser.
+const HTML_REPORT_MAX_POINTS = 2000
+
+const (
+	SVG_WIDTH         = 960.0
+	SVG_MARGIN_LEFT   = 90.0
+	SVG_MARGIN_RIGHT  = 10.0
+	SVG_MARGIN_TOP    = 10.0
+	SVG_MARGIN_BOTTOM = 24.0
+)
+
+/**
+ * HTMLReport
+ *
+ * Renders the result of a backtest, or of live trading replayed with
+ * LedgerEquity, as a single HTML file with inline SVG charts and styles, so it
+ * can be opened or shared without network access: the price with the
+ * percentile bands the trader reads and a marker for every fill, the equity
+ * curve against buy and hold, the drawdown, and the metrics table.
+ */
+
+type HTMLReport struct {
+	Title  string
+	Result *BacktestResult
+	// Candles of the market, sorted by date. Only the candles between the
+	// result's start and end times are charted; earlier candles warm up the
+	// percentile bands.
+	Candles []*SummaryData
+	// Length of the percentile bands' window in seconds, usually the trader's
+	// TimeWindow.
+	Window int64
+	// Percentiles of the window charted over the price, e.g. the trader's
+	// BuyThresholdMin and BuyThresholdMax. The area between the lowest and
+	// highest is shaded.
+	Percentiles []int64
+	GeneratedAt time.Time
+}
+
+type htmlReportPage struct {
+	Title     string
+	Generated string
+	Bands     string
+	Price     template.HTML
+	Equity    template.HTML
+	Drawdown  template.HTML
+	Metrics   [][2]string
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Helvetica Neue", Arial, sans-serif; color: #222; margin: 2em auto; max-width: 980px; }
+h1 { font-size: 1.5em; margin-bottom: 0; }
+h2 { font-size: 1.1em; margin: 1.5em 0 0.3em; }
+.generated, .legend { color: #666; font-size: 0.85em; margin: 0.3em 0; }
+svg.chart { width: 100%; height: auto; background: #fafafa; border: 1px solid #ddd; }
+svg.chart text { font-size: 11px; fill: #666; }
+svg.chart .grid { stroke: #e4e4e4; stroke-width: 1; }
+svg.chart .price { fill: none; stroke: #333; stroke-width: 1.2; }
+svg.chart .band { fill: none; stroke: #8ab4e8; stroke-width: 1; stroke-dasharray: 4 3; }
+svg.chart .band-area { fill: #8ab4e8; fill-opacity: 0.2; stroke: none; }
+svg.chart .buy { fill: #1a9850; }
+svg.chart .sell { fill: #d73027; }
+svg.chart .nav { fill: none; stroke: #1f6fc5; stroke-width: 1.5; }
+svg.chart .hold { fill: none; stroke: #999; stroke-width: 1; stroke-dasharray: 4 3; }
+svg.chart .drawdown { fill: #d73027; fill-opacity: 0.35; stroke: #d73027; stroke-width: 1; }
+table { border-collapse: collapse; }
+th, td { text-align: left; padding: 0.25em 1.5em 0.25em 0; border-bottom: 1px solid #eee; font-size: 0.9em; }
+th { font-weight: normal; color: #666; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="generated">Generated {{.Generated}}</p>
+
+<h2>Price</h2>
+{{.Price}}
+<p class="legend">Weighted average price. Dashed lines: {{.Bands}}. Green markers are buys, red markers are sells.</p>
+
+<h2>Equity</h2>
+{{.Equity}}
+<p class="legend">Net asset value (solid) against buying and holding the starting balance (dashed).</p>
+
+<h2>Drawdown</h2>
+{{.Drawdown}}
+<p class="legend">Net asset value below its previous peak.</p>
+
+<h2>Metrics</h2>
+<table>
+{{range .Metrics}}<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func (report *HTMLReport) WriteHTML(w io.Writer) error {
+	if len(report.Result.Equity) == 0 {
+		return fmt.Errorf("no equity curve to report")
+	}
+
+	bands := make([]string, 0, len(report.Percentiles))
+	for _, p := range report.Percentiles {
+		bands = append(bands, fmt.Sprintf("%dth", p))
+	}
+
+	bandsLegend := "none"
+	if len(bands) > 0 {
+		bandsLegend = fmt.Sprintf("%s percentiles of the last %s", strings.Join(bands, ", "), time.Duration(report.Window)*time.Second)
+	}
+
+	page := &htmlReportPage{
+		Title:     report.Title,
+		Generated: report.GeneratedAt.UTC().Format(time.RFC1123),
+		Bands:     bandsLegend,
+		Price:     report.priceChart(),
+		Equity:    report.equityChart(),
+		Drawdown:  report.drawdownChart(),
+		Metrics:   report.Result.Metrics(),
+	}
+
+	return htmlReportTemplate.Execute(w, page)
+}
+
+func (report *HTMLReport) priceChart() template.HTML {
+	startTime, endTime := report.Result.StartTime, report.Result.EndTime
+
+	dates := make([]float64, 0)
+	prices := make([]float64, 0)
+	bands := make([][]float64, len(report.Percentiles))
+
+	window := NewRollingPercentiles(report.Window)
+
+	for _, candle := range report.Candles {
+		if candle.Date > endTime {
+			break
+		}
+
+		window.Add(candle.Date, candle.WeightedAverage)
+
+		if candle.Date < startTime {
+			continue
+		}
+
+		dates = append(dates, float64(candle.Date))
+		prices = append(prices, candle.WeightedAverage)
+
+		for i, p := range report.Percentiles {
+			bands[i] = append(bands[i], window.Percentile(p))
+		}
+	}
+
+	if len(dates) == 0 {
+		return template.HTML("<p class=\"legend\">No chart data.</p>")
+	}
+
+	minY, maxY := seriesRange(prices)
+	for _, band := range bands {
+		low, high := seriesRange(band)
+		minY, maxY = math.Min(minY, low), math.Max(maxY, high)
+	}
+
+	chart := newSVGChart(320, dates[0], dates[len(dates)-1], minY, maxY)
+	chart.axes(func(v float64) string { return fmt.Sprintf("%.6g", v) })
+
+	sample := sampleIndices(len(dates))
+
+	if len(bands) > 1 {
+		chart.band(pick(dates, sample), pick(bands[0], sample), pick(bands[len(bands)-1], sample), "band-area")
+	}
+
+	for _, band := range bands {
+		chart.polyline(pick(dates, sample), pick(band, sample), "band")
+	}
+
+	chart.polyline(pick(dates, sample), pick(prices, sample), "price")
+
+	for _, fill := range report.Result.Fills {
+		if fill.Date < startTime || fill.Date > endTime {
+			continue
+		}
+
+		title := fmt.Sprintf("%s %s %s @ %s %s", fill.Market, fill.Type, fill.Amount.Format(), fill.Price.Format(),
+			time.Unix(fill.Date, 0).UTC().Format("2006-01-02 15:04"))
+		chart.marker(fill.Date, fill.Price.Float64(), fill.Type == "buy", title)
+	}
+
+	return chart.render()
+}
+
+func (report *HTMLReport) equityChart() template.HTML {
+	equity := report.Result.Equity
+	first := equity[0]
+
+	dates := make([]float64, 0, len(equity))
+	navs := make([]float64, 0, len(equity))
+	hold := make([]float64, 0, len(equity))
+
+	for _, point := range equity {
+		dates = append(dates, float64(point.Date))
+		navs = append(navs, point.NAV)
+
+		if first.Price > 0 {
+			hold = append(hold, report.Result.StartingNAV*point.Price/first.Price)
+		}
+	}
+
+	minY, maxY := seriesRange(navs)
+	if len(hold) > 0 {
+		low, high := seriesRange(hold)
+		minY, maxY = math.Min(minY, low), math.Max(maxY, high)
+	}
+
+	chart := newSVGChart(240, dates[0], dates[len(dates)-1], minY, maxY)
+	chart.axes(func(v float64) string { return fmt.Sprintf("%.6g", v) })
+
+	sample := sampleIndices(len(dates))
+	if len(hold) > 0 {
+		chart.polyline(pick(dates, sample), pick(hold, sample), "hold")
+	}
+	chart.polyline(pick(dates, sample), pick(navs, sample), "nav")
+
+	return chart.render()
+}
+
+func (report *HTMLReport) drawdownChart() template.HTML {
+	equity := report.Result.Equity
+
+	dates := make([]float64, 0, len(equity))
+	drawdowns := make([]float64, 0, len(equity))
+	peak := report.Result.StartingNAV
+
+	for _, point := range equity {
+		peak = math.Max(peak, point.NAV)
+
+		drawdown := 0.0
+		if peak > 0 {
+			drawdown = point.NAV/peak - 1
+		}
+
+		dates = append(dates, float64(point.Date))
+		drawdowns = append(drawdowns, drawdown)
+	}
+
+	minY, _ := seriesRange(drawdowns)
+
+	chart := newSVGChart(160, dates[0], dates[len(dates)-1], math.Min(minY, -0.01), 0)
+	chart.axes(func(v float64) string { return fmt.Sprintf("%0.1f%%", v*100) })
+
+	sample := sampleIndices(len(dates))
+	zeros := make([]float64, len(sample))
+	chart.band(pick(dates, sample), zeros, pick(drawdowns, sample), "drawdown")
+
+	return chart.render()
+}
+
+// sampleIndices returns the indices of at most HTML_REPORT_MAX_POINTS evenly
+// spaced points out of n, always including the last one.
+func sampleIndices(n int) []int {
+	step := (n + HTML_REPORT_MAX_POINTS - 1) / HTML_REPORT_MAX_POINTS
+	if step < 1 {
+		step = 1
+	}
+
+	indices := make([]int, 0, n/step+1)
+	for i := 0; i < n; i += step {
+		indices = append(indices, i)
+	}
+
+	if len(indices) > 0 && indices[len(indices)-1] != n-1 {
+		indices = append(indices, n-1)
+	}
+
+	return indices
+}
+
+func pick(values []float64, indices []int) []float64 {
+	picked := make([]float64, 0, len(indices))
+
+	for _, i := range indices {
+		picked = append(picked, values[i])
+	}
+
+	return picked
+}
+
+func seriesRange(values []float64) (min, max float64) {
+	min, max = math.Inf(1), math.Inf(-1)
+
+	for _, v := range values {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+
+	return min, max
+}
+
+// svgChart plots series of values into an SVG, with dates in seconds on the
+// x axis.
+type svgChart struct {
+	height float64
+	minX   float64
+	maxX   float64
+	minY   float64
+	maxY   float64
+	body   strings.Builder
+}
+
+func newSVGChart(height, minX, maxX, minY, maxY float64) *svgChart {
+	if maxX <= minX {
+		maxX = minX + 1
+	}
+
+	if math.IsInf(minY, 0) || math.IsInf(maxY, 0) {
+		minY, maxY = 0, 1
+	}
+
+	if maxY <= minY {
+		pad := math.Abs(maxY) * 0.01
+		if pad == 0 {
+			pad = 1
+		}
+		minY, maxY = minY-pad, maxY+pad
+	}
+
+	return &svgChart{height: height, minX: minX, maxX: maxX, minY: minY, maxY: maxY}
+}
+
+func (chart *svgChart) x(date float64) float64 {
+	plotWidth := SVG_WIDTH - SVG_MARGIN_LEFT - SVG_MARGIN_RIGHT
+	return SVG_MARGIN_LEFT + (date-chart.minX)/(chart.maxX-chart.minX)*plotWidth
+}
+
+func (chart *svgChart) y(value float64) float64 {
+	plotHeight := chart.height - SVG_MARGIN_TOP - SVG_MARGIN_BOTTOM
+	return SVG_MARGIN_TOP + (chart.maxY-value)/(chart.maxY-chart.minY)*plotHeight
+}
+
+// axes draws horizontal grid lines with their values and the first, middle
+// and last dates.
+func (chart *svgChart) axes(format func(float64) string) {
+	for i := 0; i <= 4; i += 1 {
+		value := chart.minY + (chart.maxY-chart.minY)*float64(i)/4
+		y := chart.y(value)
+
+		fmt.Fprintf(&chart.body, `<line class="grid" x1="%0.1f" y1="%0.1f" x2="%0.1f" y2="%0.1f"/>`+"\n",
+			SVG_MARGIN_LEFT, y, SVG_WIDTH-SVG_MARGIN_RIGHT, y)
+		fmt.Fprintf(&chart.body, `<text x="%0.1f" y="%0.1f" text-anchor="end">%s</text>`+"\n",
+			SVG_MARGIN_LEFT-6, y+4, template.HTMLEscapeString(format(value)))
+	}
+
+	for i, anchor := range []string{"start", "middle", "end"} {
+		date := chart.minX + (chart.maxX-chart.minX)*float64(i)/2
+		label := time.Unix(int64(date), 0).UTC().Format("2006-01-02 15:04")
+
+		fmt.Fprintf(&chart.body, `<text x="%0.1f" y="%0.1f" text-anchor="%s">%s</text>`+"\n",
+			chart.x(date), chart.height-6, anchor, label)
+	}
+}
+
+func (chart *svgChart) polyline(dates, values []float64, class string) {
+	fmt.Fprintf(&chart.body, `<polyline class="%s" points="%s"/>`+"\n", class, chart.points(dates, values))
+}
+
+// band fills the area between two series of the same dates.
+func (chart *svgChart) band(dates, lower, upper []float64, class string) {
+	reversedDates := make([]float64, len(dates))
+	reversedLower := make([]float64, len(lower))
+	for i := range dates {
+		reversedDates[len(dates)-1-i] = dates[i]
+		reversedLower[len(lower)-1-i] = lower[i]
+	}
+
+	fmt.Fprintf(&chart.body, `<polygon class="%s" points="%s %s"/>`+"\n", class,
+		chart.points(dates, upper), chart.points(reversedDates, reversedLower))
+}
+
+// marker draws a triangle pointing at the price of a fill, from below for
+// buys and from above for sells, with the details as a tooltip.
+func (chart *svgChart) marker(date int64, price float64, buy bool, title string) {
+	x, y := chart.x(float64(date)), chart.y(price)
+
+	path := fmt.Sprintf("M%0.1f %0.1f l-5 9 h10 z", x, y)
+	class := "buy"
+	if !buy {
+		path = fmt.Sprintf("M%0.1f %0.1f l-5 -9 h10 z", x, y)
+		class = "sell"
+	}
+
+	fmt.Fprintf(&chart.body, `<path class="%s" d="%s"><title>%s</title></path>`+"\n",
+		class, path, template.HTMLEscapeString(title))
+}
+
+func (chart *svgChart) points(dates, values []float64) string {
+	points := make([]string, 0, len(dates))
+
+	for i := range dates {
+		points = append(points, fmt.Sprintf("%0.1f,%0.1f", chart.x(dates[i]), chart.y(values[i])))
+	}
+
+	return strings.Join(points, " ")
+}
+
+func (chart *svgChart) render() template.HTML {
+	return template.HTML(fmt.Sprintf(
+		`<svg class="chart" xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %0.0f %0.0f">`+"\n%s</svg>",
+		SVG_WIDTH, chart.height, chart.body.String()))
+}
@@ -0,0 +1,64 @@
+package trading
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTMLReport(t *testing.T) {
+	candles := oscillatingCandles(0, 288*3)
+	config := DefaultTraderConfig()
+
+	bt := &Backtest{
+		Market:          "BTC_ABC",
+		Config:          config,
+		Candles:         candles,
+		Resolution:      300,
+		StartTime:       86400,
+		EndTime:         candles[len(candles)-1].Date,
+		StartingBalance: 0.1,
+		Fees:            FeeSchedule{Maker: 0.0015, Taker: 0.0025},
+	}
+
+	result, err := bt.Run()
+	require.Nil(t, err)
+	require.True(t, len(result.Fills) > 0)
+
+	report := &HTMLReport{
+		Title:       "BTC_ABC <backtest>",
+		Result:      result,
+		Candles:     candles,
+		Window:      config.TimeWindow,
+		Percentiles: []int64{config.BuyThresholdMin, config.BuyThresholdMax},
+		GeneratedAt: time.Unix(0, 0),
+	}
+
+	var buffer bytes.Buffer
+	require.Nil(t, report.WriteHTML(&buffer))
+	html := buffer.String()
+
+	assert.Contains(t, html, "<title>BTC_ABC &lt;backtest&gt;</title>")
+	assert.Equal(t, 3, strings.Count(html, "<svg "))
+	assert.Equal(t, 2, strings.Count(html, `class="band"`))
+	assert.Equal(t, len(result.Fills), strings.Count(html, `class="buy"`)+strings.Count(html, `class="sell"`))
+	assert.Contains(t, html, "<th>max drawdown</th>")
+	assert.Contains(t, html, "10th, 50th percentiles of the last 24h0m0s")
+
+	// no external resources
+	assert.NotContains(t, html, "<script")
+	assert.NotContains(t, html, "<link")
+	assert.NotContains(t, html, "src=")
+}
+
+func TestSampleIndices(t *testing.T) {
+	assert.Equal(t, []int{0, 1, 2}, sampleIndices(3))
+
+	indices := sampleIndices(HTML_REPORT_MAX_POINTS*3 + 1)
+	assert.Equal(t, 0, indices[0])
+	assert.Equal(t, HTML_REPORT_MAX_POINTS*3, indices[len(indices)-1])
+	assert.True(t, len(indices) <= HTML_REPORT_MAX_POINTS+1)
+}
@@ -0,0 +1,432 @@
+// Package indicators provides streaming technical indicators. Each indicator
+// is updated with one candle (or value) at a time and keeps only the state it
+// needs, so the cost of an update does not depend on how much history has
+// been seen.
+package indicators
+
+import (
+	"math"
+)
+
+// Candle has the same fields as trading.SummaryData, so summary data can be
+// converted with indicators.Candle(*summaryData).
+type Candle struct {
+	Date            int64
+	High            float64
+	Low             float64
+	Open            float64
+	Close           float64
+	Volume          float64
+	QuoteVolume     float64
+	WeightedAverage float64
+}
+
+type Indicator interface {
+	Update(candle *Candle)
+	// Ready reports whether enough candles have been seen for Value to be
+	// meaningful.
+	Ready() bool
+	Value() float64
+}
+
+// window is a fixed size ring buffer of the most recent values.
+type window struct {
+	values []float64
+	next   int
+	full   bool
+}
+
+func newWindow(size int) *window {
+	return &window{values: make([]float64, size)}
+}
+
+// push adds value and returns the value it replaced, if any.
+func (w *window) push(value float64) (old float64, evicted bool) {
+	old, evicted = w.values[w.next], w.full
+	w.values[w.next] = value
+	w.next = (w.next + 1) % len(w.values)
+
+	if w.next == 0 {
+		w.full = true
+	}
+
+	return old, evicted
+}
+
+// wrapped reports whether the last push filled the final slot, which is when
+// running sums are recomputed so rounding errors cannot build up over a long
+// series. That costs one pass over the window every len(values) pushes.
+func (w *window) wrapped() bool {
+	return w.next == 0
+}
+
+func (w *window) sums() (sum, sumSq float64) {
+	for _, v := range w.values[:w.len()] {
+		sum += v
+		sumSq += v * v
+	}
+	return sum, sumSq
+}
+
+func (w *window) len() int {
+	if w.full {
+		return len(w.values)
+	}
+
+	return w.next
+}
+
+// SMA is the simple moving average of the last Period closes.
+type SMA struct {
+	Period int
+	window *window
+	sum    float64
+}
+
+func NewSMA(period int) *SMA {
+	return &SMA{Period: period, window: newWindow(period)}
+}
+
+func (sma *SMA) Update(candle *Candle) {
+	sma.Add(candle.Close)
+}
+
+func (sma *SMA) Add(value float64) {
+	old, evicted := sma.window.push(value)
+	sma.sum += value
+
+	if evicted {
+		sma.sum -= old
+	}
+
+	if sma.window.wrapped() {
+		sma.sum, _ = sma.window.sums()
+	}
+}
+
+func (sma *SMA) Ready() bool {
+	return sma.window.full
+}
+
+func (sma *SMA) Value() float64 {
+	if sma.window.len() == 0 {
+		return 0.0
+	}
+
+	return sma.sum / float64(sma.window.len())
+}
+
+// EMA is the exponential moving average of the closes, seeded with the simple
+// average of the first Period values.
+type EMA struct {
+	Period int
+	alpha  float64
+	seed   *SMA
+	value  float64
+}
+
+func NewEMA(period int) *EMA {
+	return &EMA{Period: period, alpha: 2.0 / float64(period+1), seed: NewSMA(period)}
+}
+
+func (ema *EMA) Update(candle *Candle) {
+	ema.Add(candle.Close)
+}
+
+func (ema *EMA) Add(value float64) {
+	if !ema.seed.Ready() {
+		ema.seed.Add(value)
+		ema.value = ema.seed.Value()
+		return
+	}
+
+	ema.value += ema.alpha * (value - ema.value)
+}
+
+func (ema *EMA) Ready() bool {
+	return ema.seed.Ready()
+}
+
+func (ema *EMA) Value() float64 {
+	return ema.value
+}
+
+// StdDev is the population standard deviation of the last Period closes.
+type StdDev struct {
+	Period int
+	window *window
+	sum    float64
+	sumSq  float64
+}
+
+func NewStdDev(period int) *StdDev {
+	return &StdDev{Period: period, window: newWindow(period)}
+}
+
+func (sd *StdDev) Update(candle *Candle) {
+	sd.Add(candle.Close)
+}
+
+func (sd *StdDev) Add(value float64) {
+	old, evicted := sd.window.push(value)
+	sd.sum += value
+	sd.sumSq += value * value
+
+	if evicted {
+		sd.sum -= old
+		sd.sumSq -= old * old
+	}
+
+	if sd.window.wrapped() {
+		sd.sum, sd.sumSq = sd.window.sums()
+	}
+}
+
+func (sd *StdDev) Ready() bool {
+	return sd.window.full
+}
+
+func (sd *StdDev) Value() float64 {
+	n := float64(sd.window.len())
+	if n == 0 {
+		return 0.0
+	}
+
+	mean := sd.sum / n
+	variance := sd.sumSq/n - mean*mean
+
+	// rounding can leave a tiny negative variance for a flat window
+	if variance < 0 {
+		return 0.0
+	}
+
+	return math.Sqrt(variance)
+}
+
+// RSI is Wilder's relative strength index of the closes, between 0 and 100.
+type RSI struct {
+	Period   int
+	previous float64
+	started  bool
+	count    int
+	avgGain  float64
+	avgLoss  float64
+}
+
+func NewRSI(period int) *RSI {
+	return &RSI{Period: period}
+}
+
+func (rsi *RSI) Update(candle *Candle) {
+	rsi.Add(candle.Close)
+}
+
+func (rsi *RSI) Add(value float64) {
+	if !rsi.started {
+		rsi.previous = value
+		rsi.started = true
+		return
+	}
+
+	change := value - rsi.previous
+	rsi.previous = value
+
+	gain, loss := math.Max(change, 0), math.Max(-change, 0)
+	rsi.count += 1
+
+	if rsi.count <= rsi.Period {
+		// the first average is a simple average of Period changes
+		rsi.avgGain += gain / float64(rsi.Period)
+		rsi.avgLoss += loss / float64(rsi.Period)
+		return
+	}
+
+	n := float64(rsi.Period)
+	rsi.avgGain = (rsi.avgGain*(n-1) + gain) / n
+	rsi.avgLoss = (rsi.avgLoss*(n-1) + loss) / n
+}
+
+func (rsi *RSI) Ready() bool {
+	return rsi.count >= rsi.Period
+}
+
+func (rsi *RSI) Value() float64 {
+	if rsi.avgLoss == 0 {
+		if rsi.avgGain == 0 {
+			return 50.0
+		}
+		return 100.0
+	}
+
+	return 100.0 - 100.0/(1.0+rsi.avgGain/rsi.avgLoss)
+}
+
+// MACD is the difference between a fast and a slow EMA of the closes. Signal
+// is an EMA of that difference and Histogram the distance between the two.
+type MACD struct {
+	fast   *EMA
+	slow   *EMA
+	signal *EMA
+}
+
+func NewMACD(fastPeriod, slowPeriod, signalPeriod int) *MACD {
+	return &MACD{fast: NewEMA(fastPeriod), slow: NewEMA(slowPeriod), signal: NewEMA(signalPeriod)}
+}
+
+func (macd *MACD) Update(candle *Candle) {
+	macd.Add(candle.Close)
+}
+
+func (macd *MACD) Add(value float64) {
+	macd.fast.Add(value)
+	macd.slow.Add(value)
+
+	if macd.slow.Ready() {
+		macd.signal.Add(macd.Value())
+	}
+}
+
+func (macd *MACD) Ready() bool {
+	return macd.signal.Ready()
+}
+
+func (macd *MACD) Value() float64 {
+	return macd.fast.Value() - macd.slow.Value()
+}
+
+func (macd *MACD) Signal() float64 {
+	return macd.signal.Value()
+}
+
+func (macd *MACD) Histogram() float64 {
+	return macd.Value() - macd.Signal()
+}
+
+// Bollinger bands are K standard deviations above and below the simple moving
+// average of the closes, which is the Value.
+type Bollinger struct {
+	K      float64
+	sma    *SMA
+	stdDev *StdDev
+}
+
+func NewBollinger(period int, k float64) *Bollinger {
+	return &Bollinger{K: k, sma: NewSMA(period), stdDev: NewStdDev(period)}
+}
+
+func (bb *Bollinger) Update(candle *Candle) {
+	bb.Add(candle.Close)
+}
+
+func (bb *Bollinger) Add(value float64) {
+	bb.sma.Add(value)
+	bb.stdDev.Add(value)
+}
+
+func (bb *Bollinger) Ready() bool {
+	return bb.sma.Ready()
+}
+
+func (bb *Bollinger) Value() float64 {
+	return bb.sma.Value()
+}
+
+func (bb *Bollinger) Upper() float64 {
+	return bb.sma.Value() + bb.K*bb.stdDev.Value()
+}
+
+func (bb *Bollinger) Lower() float64 {
+	return bb.sma.Value() - bb.K*bb.stdDev.Value()
+}
+
+// ATR is Wilder's average true range, seeded with the simple average of the
+// first Period true ranges.
+type ATR struct {
+	Period    int
+	count     int
+	value     float64
+	prevClose float64
+}
+
+func NewATR(period int) *ATR {
+	return &ATR{Period: period}
+}
+
+func (atr *ATR) Update(candle *Candle) {
+	trueRange := candle.High - candle.Low
+
+	if atr.count > 0 {
+		trueRange = math.Max(trueRange, math.Abs(candle.High-atr.prevClose))
+		trueRange = math.Max(trueRange, math.Abs(candle.Low-atr.prevClose))
+	}
+
+	atr.prevClose = candle.Close
+	atr.count += 1
+
+	n := float64(atr.Period)
+
+	if atr.count <= atr.Period {
+		atr.value += (trueRange - atr.value) / float64(atr.count)
+		return
+	}
+
+	atr.value = (atr.value*(n-1) + trueRange) / n
+}
+
+func (atr *ATR) Ready() bool {
+	return atr.count >= atr.Period
+}
+
+func (atr *ATR) Value() float64 {
+	return atr.value
+}
+
+// VWAP is the volume weighted average price over the last Period candles, or
+// over every candle seen when Period is zero. Chart data Volume is in the base
+// currency and QuoteVolume in the quote currency, so their ratio is the exact
+// average price paid.
+type VWAP struct {
+	Period      int
+	volume      *SMA
+	quoteVolume *SMA
+	sumVolume   float64
+	sumQuote    float64
+}
+
+func NewVWAP(period int) *VWAP {
+	vwap := &VWAP{Period: period}
+
+	if period > 0 {
+		vwap.volume = NewSMA(period)
+		vwap.quoteVolume = NewSMA(period)
+	}
+
+	return vwap
+}
+
+func (vwap *VWAP) Update(candle *Candle) {
+	if vwap.Period == 0 {
+		vwap.sumVolume += candle.Volume
+		vwap.sumQuote += candle.QuoteVolume
+		return
+	}
+
+	vwap.volume.Add(candle.Volume)
+	vwap.quoteVolume.Add(candle.QuoteVolume)
+	vwap.sumVolume = vwap.volume.sum
+	vwap.sumQuote = vwap.quoteVolume.sum
+}
+
+// Ready is true as soon as there has been any volume; a VWAP over fewer than
+// Period candles is still the average price paid in that time.
+func (vwap *VWAP) Ready() bool {
+	return vwap.sumQuote > 0
+}
+
+func (vwap *VWAP) Value() float64 {
+	if vwap.sumQuote <= 0 {
+		return 0.0
+	}
+
+	return vwap.sumVolume / vwap.sumQuote
+}
@@ -0,0 +1,150 @@
+package indicators
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func closes(values ...float64) []*Candle {
+	candles := make([]*Candle, 0, len(values))
+	for i, v := range values {
+		candles = append(candles, &Candle{Date: int64(i * 300), High: v, Low: v, Open: v, Close: v})
+	}
+	return candles
+}
+
+func feed(indicator Indicator, candles []*Candle) {
+	for _, c := range candles {
+		indicator.Update(c)
+	}
+}
+
+func TestSMA(t *testing.T) {
+	sma := NewSMA(3)
+	feed(sma, closes(1, 2))
+	assert.False(t, sma.Ready())
+	assert.Equal(t, 1.5, sma.Value())
+
+	feed(sma, closes(3, 4, 5))
+	assert.True(t, sma.Ready())
+	assert.Equal(t, 4.0, sma.Value())
+
+	t.Run("matches a full recompute over a long series", func(t *testing.T) {
+		sma := NewSMA(7)
+		values := make([]float64, 0)
+
+		for i := 0; i < 1000; i += 1 {
+			v := 0.0001 + math.Sin(float64(i))*0.00001
+			values = append(values, v)
+			sma.Add(v)
+		}
+
+		expected := 0.0
+		for _, v := range values[len(values)-7:] {
+			expected += v / 7
+		}
+
+		assert.InDelta(t, expected, sma.Value(), 1e-15)
+	})
+}
+
+func TestEMA(t *testing.T) {
+	ema := NewEMA(3)
+	feed(ema, closes(1, 2, 3))
+	assert.True(t, ema.Ready())
+	assert.Equal(t, 2.0, ema.Value())
+
+	// alpha = 2 / (3 + 1)
+	feed(ema, closes(6))
+	assert.Equal(t, 4.0, ema.Value())
+}
+
+func TestStdDev(t *testing.T) {
+	sd := NewStdDev(4)
+	feed(sd, closes(2, 4, 4, 4, 5, 5, 7, 9))
+	assert.InDelta(t, math.Sqrt(2.75), sd.Value(), 1e-12)
+
+	flat := NewStdDev(3)
+	feed(flat, closes(0.1, 0.1, 0.1))
+	assert.Equal(t, 0.0, flat.Value())
+}
+
+func TestRSI(t *testing.T) {
+	rsi := NewRSI(2)
+	feed(rsi, closes(10, 11))
+	assert.False(t, rsi.Ready())
+
+	// changes +1 -0.5: avg gain 0.5, avg loss 0.25
+	feed(rsi, closes(10.5))
+	assert.True(t, rsi.Ready())
+	assert.InDelta(t, 100.0-100.0/3.0, rsi.Value(), 1e-12)
+
+	// Wilder smoothing: gain (0.5+1)/2, loss (0.25+0)/2
+	feed(rsi, closes(11.5))
+	assert.InDelta(t, 100.0-100.0/(1.0+0.75/0.125), rsi.Value(), 1e-12)
+
+	rising := NewRSI(3)
+	feed(rising, closes(1, 2, 3, 4))
+	assert.Equal(t, 100.0, rising.Value())
+}
+
+func TestMACD(t *testing.T) {
+	macd := NewMACD(2, 3, 2)
+	feed(macd, closes(1, 2, 3))
+	assert.False(t, macd.Ready())
+
+	feed(macd, closes(4))
+	assert.True(t, macd.Ready())
+
+	// a steady trend keeps the fast EMA a constant distance above the slow one
+	feed(macd, closes(5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20))
+	assert.InDelta(t, 0.5, macd.Value(), 1e-3)
+	assert.InDelta(t, 0.5, macd.Signal(), 1e-3)
+	assert.InDelta(t, 0.0, macd.Histogram(), 1e-3)
+}
+
+func TestBollinger(t *testing.T) {
+	bb := NewBollinger(4, 2)
+	feed(bb, closes(2, 4, 4, 6))
+	assert.True(t, bb.Ready())
+	assert.Equal(t, 4.0, bb.Value())
+	assert.InDelta(t, 4.0+2*math.Sqrt(2), bb.Upper(), 1e-12)
+	assert.InDelta(t, 4.0-2*math.Sqrt(2), bb.Lower(), 1e-12)
+}
+
+func TestATR(t *testing.T) {
+	atr := NewATR(2)
+	atr.Update(&Candle{High: 10, Low: 8, Close: 9})
+	assert.False(t, atr.Ready())
+
+	// gap up: true range is high - previous close
+	atr.Update(&Candle{High: 13, Low: 12, Close: 12})
+	assert.True(t, atr.Ready())
+	assert.Equal(t, 3.0, atr.Value())
+
+	atr.Update(&Candle{High: 12, Low: 11, Close: 11})
+	assert.Equal(t, 2.0, atr.Value())
+}
+
+func TestVWAP(t *testing.T) {
+	candles := []*Candle{
+		&Candle{Volume: 2.0, QuoteVolume: 1.0},
+		&Candle{Volume: 0.0, QuoteVolume: 0.0},
+		&Candle{Volume: 12.0, QuoteVolume: 3.0},
+	}
+
+	cumulative := NewVWAP(0)
+	feed(cumulative, candles)
+	assert.True(t, cumulative.Ready())
+	assert.Equal(t, 3.5, cumulative.Value())
+
+	rolling := NewVWAP(2)
+	feed(rolling, candles)
+	assert.True(t, rolling.Ready())
+	assert.Equal(t, 4.0, rolling.Value())
+
+	empty := NewVWAP(0)
+	empty.Update(&Candle{})
+	assert.False(t, empty.Ready())
+}
@@ -0,0 +1,217 @@
+package trading
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/jbgo/sftbot/data"
+	"io"
+	"sort"
+	"strconv"
+)
+
+/**
+ * Journal
+ *
+ * Collects why each order was placed: the market data and trader thresholds
+ * at the moment of the decision. Together with the fills and equity curve of
+ * a BacktestResult, it can be written out as CSV or JSON lines for charting
+ * and inspection in other tools.
+ */
+
+type Journal struct {
+	Orders []*JournalOrder
+}
+
+type JournalOrder struct {
+	Date    int64
+	Market  string
+	OrderId string
+	Type    string
+	Price   data.Decimal
+	Amount  data.Decimal
+	Total   data.Decimal
+
+	CurrentPrice float64
+	BuyThreshold int64
+	// The price at the BuyThreshold percentile, which the current price must
+	// be below to buy.
+	BuyThresholdPrice float64
+	SellThreshold     float64
+	// The price of the filled bid a sell is measured against, 0 for buys
+	// when nothing has been bought yet.
+	LastBidPrice     float64
+	VolatilityIndex  float64
+	VolatilityFactor float64
+	Indicators       map[string]float64
+}
+
+func (journal *Journal) Record(order *JournalOrder) {
+	journal.Orders = append(journal.Orders, order)
+}
+
+// WriteOrders writes one row per journaled order. In CSV, each indicator is a
+// column named indicator_<name>.
+func (journal *Journal) WriteOrders(w io.Writer, format string) error {
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, order := range journal.Orders {
+		for name := range order.Indicators {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	header := []string{
+		"date", "market", "order_id", "type", "price", "amount", "total",
+		"current_price", "buy_threshold", "buy_threshold_price", "sell_threshold", "last_bid_price",
+		"volatility_index", "volatility_factor",
+	}
+	for _, name := range names {
+		header = append(header, "indicator_"+name)
+	}
+
+	rows := make([]interface{}, 0, len(journal.Orders))
+	for _, order := range journal.Orders {
+		rows = append(rows, order)
+	}
+
+	return writeRecords(w, format, header, rows, func(row interface{}) []string {
+		order := row.(*JournalOrder)
+
+		record := []string{
+			strconv.FormatInt(order.Date, 10),
+			order.Market,
+			order.OrderId,
+			order.Type,
+			order.Price.String(),
+			order.Amount.String(),
+			order.Total.String(),
+			formatJournalFloat(order.CurrentPrice),
+			strconv.FormatInt(order.BuyThreshold, 10),
+			formatJournalFloat(order.BuyThresholdPrice),
+			formatJournalFloat(order.SellThreshold),
+			formatJournalFloat(order.LastBidPrice),
+			formatJournalFloat(order.VolatilityIndex),
+			formatJournalFloat(order.VolatilityFactor),
+		}
+
+		for _, name := range names {
+			value, ok := order.Indicators[name]
+			if !ok {
+				record = append(record, "")
+				continue
+			}
+			record = append(record, formatJournalFloat(value))
+		}
+
+		return record
+	})
+}
+
+// WriteFills writes one row per fill, in the order they happened.
+func WriteFills(w io.Writer, format string, fills []*Trade) error {
+	header := []string{"date", "market", "trade_id", "order_id", "type", "price", "amount", "total", "fee"}
+
+	rows := make([]interface{}, 0, len(fills))
+	for _, fill := range fills {
+		rows = append(rows, fill)
+	}
+
+	return writeRecords(w, format, header, rows, func(row interface{}) []string {
+		fill := row.(*Trade)
+
+		return []string{
+			strconv.FormatInt(fill.Date, 10),
+			fill.Market,
+			fill.Id,
+			fill.OrderId,
+			fill.Type,
+			fill.Price.String(),
+			fill.Amount.String(),
+			fill.Total.String(),
+			fill.Fee.String(),
+		}
+	})
+}
+
+// WriteDailyNAV writes the last point of the equity curve in each UTC day.
+func WriteDailyNAV(w io.Writer, format string, equity []*EquityPoint) error {
+	header := []string{"date", "price", "base", "alt", "nav"}
+
+	rows := make([]interface{}, 0)
+	for _, point := range DailyEquity(equity) {
+		rows = append(rows, point)
+	}
+
+	return writeRecords(w, format, header, rows, func(row interface{}) []string {
+		point := row.(*EquityPoint)
+
+		return []string{
+			strconv.FormatInt(point.Date, 10),
+			formatJournalFloat(point.Price),
+			formatJournalFloat(point.Base),
+			formatJournalFloat(point.Alt),
+			formatJournalFloat(point.NAV),
+		}
+	})
+}
+
+// DailyEquity returns the last point of each UTC day.
+func DailyEquity(equity []*EquityPoint) []*EquityPoint {
+	daily := make([]*EquityPoint, 0)
+
+	for i, point := range equity {
+		if i+1 < len(equity) && equity[i+1].Date/(24*60*60) == point.Date/(24*60*60) {
+			continue
+		}
+
+		daily = append(daily, point)
+	}
+
+	return daily
+}
+
+// writeRecords writes rows as CSV, with the header and the columns returned
+// by csvRecord, or as JSON lines of the rows themselves.
+func writeRecords(w io.Writer, format string, header []string, rows []interface{}, csvRecord func(row interface{}) []string) error {
+	switch format {
+	case data.FORMAT_CSV:
+		out := csv.NewWriter(w)
+		out.Write(header)
+
+		for _, row := range rows {
+			out.Write(csvRecord(row))
+		}
+
+		out.Flush()
+		return out.Error()
+	case data.FORMAT_JSONL:
+		out := bufio.NewWriter(w)
+
+		for _, row := range rows {
+			encoded, err := json.Marshal(row)
+			if err != nil {
+				return err
+			}
+
+			_, err = out.Write(append(encoded, '\n'))
+			if err != nil {
+				return err
+			}
+		}
+
+		return out.Flush()
+	}
+
+	return fmt.Errorf("unknown format: %s", format)
+}
+
+func formatJournalFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
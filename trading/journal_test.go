@@ -0,0 +1,110 @@
+package trading
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestBacktestJournal(t *testing.T) {
+	candles := oscillatingCandles(0, 288*3)
+	journal := &Journal{}
+
+	bt := &Backtest{
+		Market:          "BTC_ABC",
+		Config:          DefaultTraderConfig(),
+		Candles:         candles,
+		Resolution:      300,
+		StartTime:       86400,
+		EndTime:         candles[len(candles)-1].Date,
+		StartingBalance: 0.1,
+		Fees:            FeeSchedule{Maker: 0.0015, Taker: 0.0025},
+		Journal:         journal,
+	}
+
+	result, err := bt.Run()
+	require.Nil(t, err)
+	require.True(t, len(journal.Orders) > 0)
+
+	orderIds := make(map[string]bool)
+	for _, order := range journal.Orders {
+		orderIds[order.OrderId] = true
+
+		if order.Type == "buy" {
+			assert.True(t, order.CurrentPrice < order.BuyThresholdPrice)
+		} else {
+			assert.True(t, order.CurrentPrice > order.LastBidPrice*order.SellThreshold)
+		}
+
+		assert.Contains(t, order.Indicators, "rsi")
+	}
+
+	for _, fill := range result.Fills {
+		assert.True(t, orderIds[fill.OrderId], fill.OrderId)
+	}
+
+	t.Run("WriteOrders", func(t *testing.T) {
+		var buffer bytes.Buffer
+		require.Nil(t, journal.WriteOrders(&buffer, "csv"))
+
+		rows, err := csv.NewReader(&buffer).ReadAll()
+		require.Nil(t, err)
+		require.Equal(t, len(journal.Orders)+1, len(rows))
+		assert.Equal(t, "date", rows[0][0])
+		assert.Contains(t, rows[0], "indicator_rsi")
+		assert.Equal(t, journal.Orders[0].OrderId, rows[1][2])
+
+		buffer.Reset()
+		require.Nil(t, journal.WriteOrders(&buffer, "jsonl"))
+
+		scanner := bufio.NewScanner(&buffer)
+		require.True(t, scanner.Scan())
+
+		var order JournalOrder
+		require.Nil(t, json.Unmarshal(scanner.Bytes(), &order))
+		assert.Equal(t, journal.Orders[0].OrderId, order.OrderId)
+		assert.Equal(t, journal.Orders[0].Indicators["rsi"], order.Indicators["rsi"])
+	})
+
+	t.Run("WriteFills", func(t *testing.T) {
+		var buffer bytes.Buffer
+		require.Nil(t, WriteFills(&buffer, "jsonl", result.Fills))
+		assert.Equal(t, len(result.Fills), strings.Count(buffer.String(), "\n"))
+	})
+
+	t.Run("WriteDailyNAV", func(t *testing.T) {
+		var buffer bytes.Buffer
+		require.Nil(t, WriteDailyNAV(&buffer, "csv", result.Equity))
+
+		rows, err := csv.NewReader(&buffer).ReadAll()
+		require.Nil(t, err)
+		require.Equal(t, 3, len(rows))
+		assert.Equal(t, []string{"date", "price", "base", "alt", "nav"}, rows[0])
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		var buffer bytes.Buffer
+		assert.NotNil(t, journal.WriteOrders(&buffer, "xml"))
+	})
+}
+
+func TestDailyEquity(t *testing.T) {
+	equity := []*EquityPoint{
+		&EquityPoint{Date: 86400 - 300, NAV: 1.0},
+		&EquityPoint{Date: 86400, NAV: 1.1},
+		&EquityPoint{Date: 86400 + 300, NAV: 1.2},
+		&EquityPoint{Date: 3 * 86400, NAV: 1.3},
+	}
+
+	daily := DailyEquity(equity)
+
+	require.Equal(t, 3, len(daily))
+	assert.Equal(t, 1.0, daily[0].NAV)
+	assert.Equal(t, 1.2, daily[1].NAV)
+	assert.Equal(t, 1.3, daily[2].NAV)
+}
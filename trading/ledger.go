@@ -0,0 +1,123 @@
+package trading
+
+import (
+	"github.com/jbgo/sftbot/data"
+	"github.com/jbgo/sftbot/db"
+	"sort"
+)
+
+// Ledger persists every fill as a Trade. Trades are grouped by market and
+// stored in chronological order under the market name.
+type Ledger struct {
+	DB db.Store
+}
+
+func NewLedger(dbStore db.Store) *Ledger {
+	return &Ledger{DB: dbStore}
+}
+
+// Record appends trades to the ledger. Trades that were already recorded (by
+// Id) are ignored, so it is safe to record the same fills more than once.
+func (ledger *Ledger) Record(trades ...*Trade) error {
+	byMarket := make(map[string][]*Trade)
+
+	for _, trade := range trades {
+		byMarket[trade.Market] = append(byMarket[trade.Market], trade)
+	}
+
+	for marketName, newTrades := range byMarket {
+		existing, err := ledger.Trades(marketName)
+		if err != nil {
+			return err
+		}
+
+		recorded := make(map[string]bool)
+		for _, trade := range existing {
+			recorded[trade.Id] = true
+		}
+
+		for _, trade := range newTrades {
+			if recorded[trade.Id] {
+				continue
+			}
+
+			recorded[trade.Id] = true
+			existing = append(existing, trade)
+		}
+
+		sort.Stable(ByTradeDate(existing))
+
+		err = ledger.DB.Write(marketName, existing)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ledger *Ledger) Trades(marketName string) ([]*Trade, error) {
+	trades := make([]*Trade, 0)
+
+	err, hasData := ledger.DB.HasData(marketName)
+	if err != nil || !hasData {
+		return trades, err
+	}
+
+	err = ledger.DB.Read(marketName, &trades)
+
+	return trades, err
+}
+
+func (ledger *Ledger) Markets() ([]string, error) {
+	return ledger.DB.Keys()
+}
+
+type ByTradeDate []*Trade
+
+func (a ByTradeDate) Len() int           { return len(a) }
+func (a ByTradeDate) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a ByTradeDate) Less(i, j int) bool { return a[i].Date < a[j].Date }
+
+// LedgerEquity replays the trades of one market over its candles, the way a
+// backtest records its equity curve: the NAV at each candle is the base
+// currency left from startingBalance after paying for buys and fees and
+// receiving sells, plus the alt bought so far at the candle's price. Trades
+// before the first candle count from the first point.
+func LedgerEquity(candles []*SummaryData, trades []*Trade, startingBalance float64) []*EquityPoint {
+	sorted := make([]*Trade, len(trades))
+	copy(sorted, trades)
+	sort.Stable(ByTradeDate(sorted))
+
+	equity := make([]*EquityPoint, 0, len(candles))
+	base, alt := data.NewDecimal(startingBalance), data.Decimal(0)
+	next := 0
+
+	for _, candle := range candles {
+		for next < len(sorted) && sorted[next].Date <= candle.Date {
+			trade := sorted[next]
+
+			if trade.Type == "buy" {
+				base -= trade.Total + trade.Fee
+				alt += trade.Amount
+			} else {
+				base += trade.Total - trade.Fee
+				alt -= trade.Amount
+			}
+
+			next += 1
+		}
+
+		point := &EquityPoint{
+			Date:  candle.Date,
+			Price: candle.WeightedAverage,
+			Base:  base.Float64(),
+			Alt:   alt.Float64(),
+		}
+		point.NAV = point.Base + point.Alt*candle.WeightedAverage
+
+		equity = append(equity, point)
+	}
+
+	return equity
+}
@@ -0,0 +1,66 @@
+package trading
+
+import (
+	"github.com/jbgo/sftbot/data"
+	"github.com/jbgo/sftbot/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestLedger(t *testing.T) {
+	dbStore, err := db.NewBoltStore("ledger_test", "test.db")
+	require.Nil(t, err)
+
+	ledger := NewLedger(dbStore)
+	dbStore.Delete("BTC_ABC")
+	dbStore.Delete("BTC_XYZ")
+
+	err = ledger.Record(
+		&Trade{Id: "2", Market: "BTC_ABC", Date: 200},
+		&Trade{Id: "1", Market: "BTC_ABC", Date: 100},
+		&Trade{Id: "3", Market: "BTC_XYZ", Date: 100},
+	)
+	require.Nil(t, err)
+
+	// duplicates are ignored
+	err = ledger.Record(&Trade{Id: "1", Market: "BTC_ABC", Date: 100})
+	require.Nil(t, err)
+
+	trades, err := ledger.Trades("BTC_ABC")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(trades))
+	assert.Equal(t, "1", trades[0].Id)
+	assert.Equal(t, "2", trades[1].Id)
+
+	trades, err = ledger.Trades("BTC_DNE")
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(trades))
+
+	markets, err := ledger.Markets()
+	require.Nil(t, err)
+	assert.Equal(t, []string{"BTC_ABC", "BTC_XYZ"}, markets)
+}
+
+func TestLedgerEquity(t *testing.T) {
+	candles := []*SummaryData{
+		&SummaryData{Date: 300, WeightedAverage: 0.1},
+		&SummaryData{Date: 600, WeightedAverage: 0.2},
+		&SummaryData{Date: 900, WeightedAverage: 0.3},
+	}
+
+	trades := []*Trade{
+		&Trade{Id: "2", Date: 800, Type: "sell", Price: data.NewDecimal(0.25), Amount: data.NewDecimal(1.0), Total: data.NewDecimal(0.25), Fee: data.NewDecimal(0.01)},
+		&Trade{Id: "1", Date: 450, Type: "buy", Price: data.NewDecimal(0.1), Amount: data.NewDecimal(2.0), Total: data.NewDecimal(0.2), Fee: data.NewDecimal(0.01)},
+	}
+
+	equity := LedgerEquity(candles, trades, 1.0)
+
+	require.Equal(t, 3, len(equity))
+	assert.Equal(t, &EquityPoint{Date: 300, Price: 0.1, Base: 1.0, Alt: 0.0, NAV: 1.0}, equity[0])
+	assert.InDelta(t, 0.79, equity[1].Base, 1e-12)
+	assert.InDelta(t, 0.79+2*0.2, equity[1].NAV, 1e-12)
+	assert.InDelta(t, 1.03, equity[2].Base, 1e-12)
+	assert.InDelta(t, 1.0, equity[2].Alt, 1e-12)
+	assert.InDelta(t, 1.33, equity[2].NAV, 1e-12)
+}
@@ -0,0 +1,88 @@
+package trading
+
+import (
+	"github.com/jbgo/sftbot/trading/indicators"
+)
+
+// Periods of the indicators reported with the market data, in candles.
+const INDICATOR_PERIOD = 14
+const BOLLINGER_PERIOD = 20
+const BOLLINGER_K = 2.0
+
+// 24 hours of 5 minute candles.
+const VWAP_PERIOD = 288
+
+// IndicatorSet streams candles through the standard indicators reported with
+// the market data.
+type IndicatorSet struct {
+	sma       *indicators.SMA
+	ema       *indicators.EMA
+	stdDev    *indicators.StdDev
+	rsi       *indicators.RSI
+	atr       *indicators.ATR
+	macd      *indicators.MACD
+	bollinger *indicators.Bollinger
+	vwap      *indicators.VWAP
+}
+
+func NewIndicatorSet() *IndicatorSet {
+	return &IndicatorSet{
+		sma:       indicators.NewSMA(INDICATOR_PERIOD),
+		ema:       indicators.NewEMA(INDICATOR_PERIOD),
+		stdDev:    indicators.NewStdDev(INDICATOR_PERIOD),
+		rsi:       indicators.NewRSI(INDICATOR_PERIOD),
+		atr:       indicators.NewATR(INDICATOR_PERIOD),
+		macd:      indicators.NewMACD(12, 26, 9),
+		bollinger: indicators.NewBollinger(BOLLINGER_PERIOD, BOLLINGER_K),
+		vwap:      indicators.NewVWAP(VWAP_PERIOD),
+	}
+}
+
+func (set *IndicatorSet) Update(d *SummaryData) {
+	candle := indicators.Candle(*d)
+
+	for _, indicator := range set.all() {
+		indicator.Update(&candle)
+	}
+}
+
+func (set *IndicatorSet) all() []indicators.Indicator {
+	return []indicators.Indicator{set.sma, set.ema, set.stdDev, set.rsi, set.atr, set.macd, set.bollinger, set.vwap}
+}
+
+// Values returns the latest value of each indicator that has seen enough
+// candles, keyed by name.
+func (set *IndicatorSet) Values() map[string]float64 {
+	values := make(map[string]float64)
+
+	put := func(name string, indicator indicators.Indicator, value float64) {
+		if indicator.Ready() {
+			values[name] = value
+		}
+	}
+
+	put("sma", set.sma, set.sma.Value())
+	put("ema", set.ema, set.ema.Value())
+	put("stddev", set.stdDev, set.stdDev.Value())
+	put("rsi", set.rsi, set.rsi.Value())
+	put("atr", set.atr, set.atr.Value())
+	put("macd", set.macd, set.macd.Value())
+	put("macd_signal", set.macd, set.macd.Signal())
+	put("bollinger_upper", set.bollinger, set.bollinger.Upper())
+	put("bollinger_lower", set.bollinger, set.bollinger.Lower())
+	put("vwap", set.vwap, set.vwap.Value())
+
+	return values
+}
+
+// calculateIndicators runs the standard indicators over summaryData. To follow
+// a market over time without recomputing them, keep an IndicatorSet.
+func calculateIndicators(summaryData []*SummaryData) map[string]float64 {
+	set := NewIndicatorSet()
+
+	for _, d := range summaryData {
+		set.Update(d)
+	}
+
+	return set.Values()
+}
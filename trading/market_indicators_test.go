@@ -0,0 +1,35 @@
+package trading
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCalculateIndicators(t *testing.T) {
+	summaryData := make([]*SummaryData, 0)
+
+	for i := 0; i < 40; i += 1 {
+		price := 0.001 + float64(i%5)*0.0001
+		summaryData = append(summaryData, &SummaryData{
+			Date:        int64(i * 300),
+			High:        price,
+			Low:         price,
+			Open:        price,
+			Close:       price,
+			Volume:      price,
+			QuoteVolume: 1.0,
+		})
+	}
+
+	values := calculateIndicators(summaryData[:10])
+	assert.NotContains(t, values, "rsi")
+	assert.Contains(t, values, "vwap")
+
+	values = calculateIndicators(summaryData)
+	for _, name := range []string{"sma", "ema", "stddev", "rsi", "atr", "macd", "macd_signal", "bollinger_upper", "bollinger_lower", "vwap"} {
+		assert.Contains(t, values, name)
+	}
+
+	assert.InDelta(t, 0.0012, values["vwap"], 1e-12)
+	assert.True(t, values["bollinger_upper"] > values["bollinger_lower"])
+}
@@ -0,0 +1,106 @@
+package trading
+
+import (
+	"fmt"
+	"github.com/jbgo/sftbot/data"
+)
+
+// Poloniex accepts prices and amounts with up to 8 decimals, and rejects
+// orders worth less than 0.0001 BTC.
+const PLX_PRECISION = 8
+const PLX_MIN_TOTAL = 0.0001
+
+// Bittrex rejects orders worth less than 50,000 satoshis, and charges the same
+// fee to makers and takers. BittrexMarket only places plain limit orders.
+const BITTREX_PRECISION = 8
+const BITTREX_MIN_TOTAL = 0.0005
+const BITTREX_FEE = 0.0025
+
+// MarketInfo describes the orders a market accepts: prices and amounts have at
+// most PricePrecision and AmountPrecision decimals, the total of an order
+// must be at least MinTotal in the base currency, and its Execution must be
+// a plain limit order or one of Executions.
+type MarketInfo struct {
+	PricePrecision  int
+	AmountPrecision int
+	MinTotal        data.Decimal
+	Fees            FeeSchedule
+	Executions      []string
+}
+
+func NewPlxMarketInfo() *MarketInfo {
+	return &MarketInfo{
+		PricePrecision:  PLX_PRECISION,
+		AmountPrecision: PLX_PRECISION,
+		MinTotal:        data.NewDecimal(PLX_MIN_TOTAL),
+		Fees:            FeeSchedule{Maker: SIMULATED_MAKER_FEE, Taker: SIMULATED_TAKER_FEE},
+		Executions:      []string{ORDER_POST_ONLY, ORDER_FILL_OR_KILL, ORDER_IMMEDIATE_OR_CANCEL},
+	}
+}
+
+func NewBittrexMarketInfo() *MarketInfo {
+	return &MarketInfo{
+		PricePrecision:  BITTREX_PRECISION,
+		AmountPrecision: BITTREX_PRECISION,
+		MinTotal:        data.NewDecimal(BITTREX_MIN_TOTAL),
+		Fees:            FeeSchedule{Maker: BITTREX_FEE, Taker: BITTREX_FEE},
+	}
+}
+
+// Round rounds the price of order in the trader's favour, down for a buy and
+// up for a sell, and its amount down so it never exceeds the balance it was
+// computed from. The total is recomputed from the rounded values.
+func (info *MarketInfo) Round(order *Order) {
+	if order.Type == "sell" {
+		order.Price = order.Price.Ceil(info.PricePrecision)
+	} else {
+		order.Price = order.Price.Floor(info.PricePrecision)
+	}
+
+	order.Amount = order.Amount.Floor(info.AmountPrecision)
+	order.Total = order.Price.Mul(order.Amount)
+}
+
+// Validate returns why the exchange would reject order, or nil.
+func (info *MarketInfo) Validate(order *Order) error {
+	if order.Price <= 0 {
+		return fmt.Errorf("price %s is not positive", order.Price.Format())
+	}
+
+	if order.Amount <= 0 {
+		return fmt.Errorf("amount %s is not positive", order.Amount.Format())
+	}
+
+	if order.Price != order.Price.Floor(info.PricePrecision) {
+		return fmt.Errorf("price %s has more than %d decimals", order.Price, info.PricePrecision)
+	}
+
+	if order.Amount != order.Amount.Floor(info.AmountPrecision) {
+		return fmt.Errorf("amount %s has more than %d decimals", order.Amount, info.AmountPrecision)
+	}
+
+	total := order.Price.MulFloor(order.Amount)
+	if total < info.MinTotal {
+		return fmt.Errorf("total %s is below the minimum of %s", total.Format(), info.MinTotal.Format())
+	}
+
+	if !info.Supports(order.Execution) {
+		return fmt.Errorf("%s orders are not supported", order.Execution)
+	}
+
+	return nil
+}
+
+func (info *MarketInfo) Supports(execution string) bool {
+	if execution == "" {
+		return true
+	}
+
+	for _, e := range info.Executions {
+		if e == execution {
+			return true
+		}
+	}
+
+	return false
+}
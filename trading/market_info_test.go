@@ -0,0 +1,57 @@
+package trading
+
+import (
+	"github.com/jbgo/sftbot/data"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMarketInfo(t *testing.T) {
+	info := NewPlxMarketInfo()
+
+	t.Run("Round", func(t *testing.T) {
+		buy := &Order{Type: "buy", Price: data.NewDecimal(0.12345678), Amount: data.NewDecimal(1.99999999)}
+		info.Round(buy)
+		assert.Equal(t, data.NewDecimal(0.12345678), buy.Price)
+		assert.Equal(t, data.NewDecimal(1.99999999), buy.Amount)
+		assert.Equal(t, buy.Price.Mul(buy.Amount), buy.Total)
+
+		bittrex := &MarketInfo{PricePrecision: 4, AmountPrecision: 2}
+
+		buy = &Order{Type: "buy", Price: data.NewDecimal(0.12345678), Amount: data.NewDecimal(1.999)}
+		bittrex.Round(buy)
+		assert.Equal(t, data.NewDecimal(0.1234), buy.Price)
+		assert.Equal(t, data.NewDecimal(1.99), buy.Amount)
+		assert.Equal(t, data.NewDecimal(0.245566), buy.Total)
+
+		sell := &Order{Type: "sell", Price: data.NewDecimal(0.12345001), Amount: data.NewDecimal(3)}
+		bittrex.Round(sell)
+		assert.Equal(t, data.NewDecimal(0.1235), sell.Price)
+		assert.Equal(t, data.NewDecimal(3), sell.Amount)
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		assert.Nil(t, info.Validate(&Order{Price: data.NewDecimal(0.01), Amount: data.NewDecimal(0.01)}))
+		assert.Nil(t, info.Validate(&Order{Price: data.SATOSHI, Amount: data.NewDecimal(10000)}))
+
+		for _, order := range []*Order{
+			&Order{Price: 0, Amount: data.NewDecimal(1)},
+			&Order{Price: data.NewDecimal(0.01), Amount: 0},
+			&Order{Price: data.NewDecimal(0.01), Amount: data.NewDecimal(0.00999999)},
+		} {
+			assert.NotNil(t, info.Validate(order), "%+v", order)
+		}
+
+		coarse := &MarketInfo{PricePrecision: 4, AmountPrecision: 2}
+		assert.NotNil(t, coarse.Validate(&Order{Price: data.NewDecimal(0.12345), Amount: data.NewDecimal(1)}))
+		assert.NotNil(t, coarse.Validate(&Order{Price: data.NewDecimal(0.1234), Amount: data.NewDecimal(1.001)}))
+
+		order := &Order{Price: data.NewDecimal(0.01), Amount: data.NewDecimal(1), Execution: ORDER_POST_ONLY}
+		assert.Nil(t, info.Validate(order))
+		assert.Nil(t, NewBittrexMarketInfo().Validate(&Order{Price: order.Price, Amount: order.Amount}))
+		assert.NotNil(t, NewBittrexMarketInfo().Validate(order))
+
+		err := info.Validate(&Order{Price: data.NewDecimal(0.01), Amount: data.NewDecimal(0.001)})
+		assert.Contains(t, err.Error(), "total 0.00001000 is below the minimum of 0.00010000")
+	})
+}
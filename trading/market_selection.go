@@ -0,0 +1,19 @@
+package trading
+
+// The ticker reports volumes over the last 24 hours.
+const TICKER_VOLUME_WINDOW = 24 * 60 * 60
+
+// plx trade only trades BTC markets with at least this much volume in the
+// ticker, in BTC.
+const MIN_MARKET_BASE_VOLUME = 500.0
+
+// ShouldTradeMarket is the rule plx trade uses to pick markets from the
+// ticker, and portfolio backtests use to pick them from the chart data.
+func ShouldTradeMarket(marketName string, baseVolume float64) bool {
+	symbol, err := ParseSymbol(marketName)
+	if err != nil {
+		return false
+	}
+
+	return symbol.Base == "BTC" && baseVolume >= MIN_MARKET_BASE_VOLUME
+}
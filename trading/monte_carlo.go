@@ -0,0 +1,365 @@
+package trading
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+const (
+	MONTE_CARLO_BOOTSTRAP    = "bootstrap"
+	MONTE_CARLO_SHUFFLE_DAYS = "shuffle-days"
+)
+
+/**
+ * MonteCarlo
+ *
+ * Runs a backtest on synthetic price paths built from its own candles, to see
+ * how much of its result is down to the order in which history happened. Each
+ * path keeps the first candle and chains the candle to candle returns of the
+ * history in a new order, either in blocks of consecutive candles drawn at
+ * random with replacement (bootstrap) or a whole day at a time with every day
+ * used once (shuffle-days). A candle's high, low, open, close and volume keep
+ * their ratio to its weighted average, and its dates do not change.
+ */
+
+type MonteCarlo struct {
+	Backtest *Backtest
+	Method   string
+	Paths    int
+	// Candles in each bootstrap block, so that volatility clusters survive
+	// the resampling.
+	BlockSize int
+	// A path is ruined when its NAV falls to this fraction of the starting
+	// balance or lower at any point, e.g. 0.5 to lose half.
+	RuinLevel float64
+	// Path i is generated from Seed+i, so reports are repeatable and do not
+	// depend on the number of workers.
+	Seed int64
+	// Defaults to the number of CPUs.
+	Workers int
+}
+
+type MonteCarloReport struct {
+	Method    string
+	Paths     int
+	RuinLevel float64
+	// The backtest on the candles as they happened.
+	Historical *BacktestResult
+	// One per path, nil for the paths whose backtest failed.
+	Results []*BacktestResult `json:"-"`
+	Errors  int
+
+	Returns           *Distribution
+	MaxDrawdowns      *Distribution
+	Sharpe            *Distribution
+	ProbabilityOfRuin float64
+	// The fraction of paths that ended with a higher NAV than the historical
+	// backtest.
+	BeatHistorical float64
+}
+
+type Distribution struct {
+	Mean   float64
+	StdDev float64
+	Min    float64
+	P5     float64
+	P25    float64
+	Median float64
+	P75    float64
+	P95    float64
+	Max    float64
+}
+
+func ValidMonteCarloMethod(method string) bool {
+	return method == MONTE_CARLO_BOOTSTRAP || method == MONTE_CARLO_SHUFFLE_DAYS
+}
+
+func (mc *MonteCarlo) Run() (*MonteCarloReport, error) {
+	if !ValidMonteCarloMethod(mc.Method) {
+		return nil, fmt.Errorf("unknown Monte Carlo method: %s", mc.Method)
+	}
+
+	if mc.Paths <= 0 {
+		return nil, fmt.Errorf("invalid number of paths: %d", mc.Paths)
+	}
+
+	if mc.Method == MONTE_CARLO_BOOTSTRAP && mc.BlockSize <= 0 {
+		return nil, fmt.Errorf("invalid block size: %d", mc.BlockSize)
+	}
+
+	if len(mc.Backtest.Candles) < 2 {
+		return nil, fmt.Errorf("not enough chart data for synthetic paths")
+	}
+
+	historical, err := mc.Backtest.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	workers := mc.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	results := make([]*BacktestResult, mc.Paths)
+	errs := make([]error, mc.Paths)
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w += 1 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				rng := rand.New(rand.NewSource(mc.Seed + int64(i)))
+
+				// only the historical run is journaled
+				backtest := *mc.Backtest
+				backtest.Candles = mc.SyntheticCandles(rng)
+				backtest.Journal = nil
+
+				results[i], errs[i] = backtest.Run()
+			}
+		}()
+	}
+
+	for i := 0; i < mc.Paths; i += 1 {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	report := &MonteCarloReport{
+		Method:     mc.Method,
+		Paths:      mc.Paths,
+		RuinLevel:  mc.RuinLevel,
+		Historical: historical,
+		Results:    results,
+	}
+
+	returns := make([]float64, 0, mc.Paths)
+	drawdowns := make([]float64, 0, mc.Paths)
+	sharpe := make([]float64, 0, mc.Paths)
+	ruined := 0
+	beat := 0
+
+	for i, result := range results {
+		if errs[i] != nil {
+			report.Errors += 1
+			continue
+		}
+
+		returns = append(returns, result.TotalReturn)
+		drawdowns = append(drawdowns, result.MaxDrawdown)
+		sharpe = append(sharpe, result.Sharpe)
+
+		if minimumNAV(result.Equity) <= result.StartingNAV*mc.RuinLevel {
+			ruined += 1
+		}
+
+		if result.EndingNAV > historical.EndingNAV {
+			beat += 1
+		}
+	}
+
+	if len(returns) == 0 {
+		return nil, fmt.Errorf("every path failed: %s", errs[0].Error())
+	}
+
+	report.Returns = NewDistribution(returns)
+	report.MaxDrawdowns = NewDistribution(drawdowns)
+	report.Sharpe = NewDistribution(sharpe)
+	report.ProbabilityOfRuin = float64(ruined) / float64(len(returns))
+	report.BeatHistorical = float64(beat) / float64(len(returns))
+
+	return report, nil
+}
+
+// SyntheticCandles generates one path from the backtest's candles.
+func (mc *MonteCarlo) SyntheticCandles(rng *rand.Rand) []*SummaryData {
+	candles := mc.Backtest.Candles
+
+	var order []int
+	if mc.Method == MONTE_CARLO_SHUFFLE_DAYS {
+		order = shuffledDays(candles, rng)
+	} else {
+		order = bootstrapBlocks(len(candles), mc.BlockSize, rng)
+	}
+
+	return chainReturns(candles, order)
+}
+
+// bootstrapBlocks returns n-1 candle indices, in blocks of consecutive
+// indices starting at random. Index i stands for the return from candle i-1
+// to candle i, so every index is at least 1.
+func bootstrapBlocks(n int, blockSize int, rng *rand.Rand) []int {
+	if blockSize > n-1 {
+		blockSize = n - 1
+	}
+
+	order := make([]int, 0, n-1+blockSize)
+
+	for len(order) < n-1 {
+		start := 1 + rng.Intn(n-blockSize)
+
+		for i := start; i < start+blockSize; i += 1 {
+			order = append(order, i)
+		}
+	}
+
+	return order[:n-1]
+}
+
+// shuffledDays returns the indices of every return but the first candle's,
+// grouped by the UTC day of the candle and with the days in random order.
+func shuffledDays(candles []*SummaryData, rng *rand.Rand) []int {
+	days := make([][]int, 0)
+
+	for i := 1; i < len(candles); i += 1 {
+		day := candles[i].Date / (24 * 60 * 60)
+
+		if len(days) == 0 || candles[days[len(days)-1][0]].Date/(24*60*60) != day {
+			days = append(days, []int{})
+		}
+
+		days[len(days)-1] = append(days[len(days)-1], i)
+	}
+
+	order := make([]int, 0, len(candles)-1)
+	for _, d := range rng.Perm(len(days)) {
+		order = append(order, days[d]...)
+	}
+
+	return order
+}
+
+// chainReturns builds candle i+1 of the path from the return into candle
+// order[i] of the history.
+func chainReturns(candles []*SummaryData, order []int) []*SummaryData {
+	path := make([]*SummaryData, 0, len(candles))
+
+	first := *candles[0]
+	path = append(path, &first)
+
+	for i, source := range order {
+		src := candles[source]
+		ratio := 1.0
+		if candles[source-1].WeightedAverage > 0 {
+			ratio = src.WeightedAverage / candles[source-1].WeightedAverage
+		}
+
+		price := path[i].WeightedAverage * ratio
+		scale := 0.0
+		if src.WeightedAverage > 0 {
+			scale = price / src.WeightedAverage
+		}
+
+		candle := *src
+		candle.Date = candles[i+1].Date
+		candle.WeightedAverage = price
+		candle.High = src.High * scale
+		candle.Low = src.Low * scale
+		candle.Open = src.Open * scale
+		candle.Close = src.Close * scale
+		candle.Volume = src.Volume * scale
+
+		path = append(path, &candle)
+	}
+
+	return path
+}
+
+func minimumNAV(equity []*EquityPoint) float64 {
+	min := math.Inf(1)
+
+	for _, point := range equity {
+		min = math.Min(min, point.NAV)
+	}
+
+	return min
+}
+
+// NewDistribution summarises the values, interpolating between the closest
+// ranks for the percentiles.
+func NewDistribution(values []float64) *Distribution {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	n := float64(len(sorted))
+	d := &Distribution{Min: sorted[0], Max: sorted[len(sorted)-1]}
+
+	for _, value := range sorted {
+		d.Mean += value / n
+	}
+
+	for _, value := range sorted {
+		d.StdDev += math.Pow(value-d.Mean, 2) / n
+	}
+	d.StdDev = math.Sqrt(d.StdDev)
+
+	d.P5 = percentileOf(sorted, 5)
+	d.P25 = percentileOf(sorted, 25)
+	d.Median = percentileOf(sorted, 50)
+	d.P75 = percentileOf(sorted, 75)
+	d.P95 = percentileOf(sorted, 95)
+
+	return d
+}
+
+func percentileOf(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+func (report *MonteCarloReport) WriteTable(w io.Writer) {
+	fmt.Fprintln(w, "historical path:")
+	report.Historical.WriteTable(w)
+
+	fmt.Fprintf(w, "\n%d %s paths (%d failed):\n", report.Paths, report.Method, report.Errors)
+	fmt.Fprintf(w, "%-14s %9s %9s %9s %9s %9s %9s %9s %9s %9s\n", "", "mean", "std dev", "min", "5%", "25%", "median", "75%", "95%", "max")
+	writeDistribution(w, "total return", report.Returns, true)
+	writeDistribution(w, "max drawdown", report.MaxDrawdowns, true)
+	writeDistribution(w, "sharpe ratio", report.Sharpe, false)
+
+	fmt.Fprintf(w, "\n%-24s %0.2f%% (NAV at or below %0.2f%% of the start)\n", "probability of ruin", report.ProbabilityOfRuin*100, report.RuinLevel*100)
+	fmt.Fprintf(w, "%-24s %0.2f%%\n", "beat historical", report.BeatHistorical*100)
+}
+
+func writeDistribution(w io.Writer, label string, d *Distribution, percent bool) {
+	values := []float64{d.Mean, d.StdDev, d.Min, d.P5, d.P25, d.Median, d.P75, d.P95, d.Max}
+
+	fmt.Fprintf(w, "%-14s", label)
+	for _, value := range values {
+		if percent {
+			fmt.Fprintf(w, " %8.2f%%", value*100)
+		} else {
+			fmt.Fprintf(w, " %9.3f", value)
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// WriteJSON writes the historical metrics and the distributions, without the
+// result of each path.
+func (report *MonteCarloReport) WriteJSON(w io.Writer) error {
+	encoded, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(encoded, '\n'))
+	return err
+}
@@ -0,0 +1,141 @@
+package trading
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestMonteCarlo(t *testing.T) {
+	candles := oscillatingCandles(0, 288*3)
+
+	mc := &MonteCarlo{
+		Backtest: &Backtest{
+			Market:          "BTC_ABC",
+			Config:          DefaultTraderConfig(),
+			Candles:         candles,
+			Resolution:      300,
+			StartTime:       86400,
+			EndTime:         candles[len(candles)-1].Date,
+			StartingBalance: 0.1,
+			Fees:            FeeSchedule{Maker: 0.0015, Taker: 0.0025},
+		},
+		Method:    MONTE_CARLO_BOOTSTRAP,
+		Paths:     6,
+		BlockSize: 12,
+		RuinLevel: 0.5,
+		Seed:      1,
+		Workers:   2,
+	}
+
+	report, err := mc.Run()
+	require.Nil(t, err)
+
+	require.Equal(t, 6, len(report.Results))
+	assert.Equal(t, 0, report.Errors)
+	assert.True(t, report.Returns.Min <= report.Returns.Median && report.Returns.Median <= report.Returns.Max)
+	assert.True(t, report.MaxDrawdowns.P5 <= report.MaxDrawdowns.P95)
+	assert.Equal(t, 0.0, report.ProbabilityOfRuin)
+
+	t.Run("repeatable", func(t *testing.T) {
+		mc.Workers = 1
+
+		again, err := mc.Run()
+		require.Nil(t, err)
+
+		for i, result := range again.Results {
+			assert.Equal(t, report.Results[i].EndingNAV, result.EndingNAV)
+		}
+	})
+
+	t.Run("ruin", func(t *testing.T) {
+		ruin := *mc
+		ruin.RuinLevel = 1.0
+
+		report, err := ruin.Run()
+		require.Nil(t, err)
+		assert.Equal(t, 1.0, report.ProbabilityOfRuin)
+	})
+
+	t.Run("WriteTable", func(t *testing.T) {
+		var buffer bytes.Buffer
+		report.WriteTable(&buffer)
+		assert.Contains(t, buffer.String(), "probability of ruin")
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		invalid := *mc
+		invalid.Method = "dice"
+		_, err := invalid.Run()
+		assert.NotNil(t, err)
+
+		invalid = *mc
+		invalid.BlockSize = 0
+		_, err = invalid.Run()
+		assert.NotNil(t, err)
+	})
+}
+
+func TestSyntheticCandles(t *testing.T) {
+	candles := oscillatingCandles(0, 288*3)
+	rng := rand.New(rand.NewSource(1))
+
+	t.Run("bootstrap", func(t *testing.T) {
+		mc := &MonteCarlo{Backtest: &Backtest{Candles: candles}, Method: MONTE_CARLO_BOOTSTRAP, BlockSize: 10}
+		path := mc.SyntheticCandles(rng)
+
+		require.Equal(t, len(candles), len(path))
+		assert.Equal(t, candles[0].WeightedAverage, path[0].WeightedAverage)
+
+		for i, candle := range path {
+			assert.Equal(t, candles[i].Date, candle.Date)
+			assert.InDelta(t, candle.WeightedAverage*1000, candle.Volume, 1e-9)
+		}
+	})
+
+	t.Run("shuffle-days", func(t *testing.T) {
+		mc := &MonteCarlo{Backtest: &Backtest{Candles: candles}, Method: MONTE_CARLO_SHUFFLE_DAYS}
+		path := mc.SyntheticCandles(rng)
+
+		require.Equal(t, len(candles), len(path))
+
+		// every day of returns is used once, so the path ends where the
+		// history does
+		assert.InDelta(t, candles[len(candles)-1].WeightedAverage, path[len(path)-1].WeightedAverage, 1e-12)
+	})
+}
+
+func TestBootstrapBlocks(t *testing.T) {
+	order := bootstrapBlocks(11, 4, rand.New(rand.NewSource(1)))
+	require.Equal(t, 10, len(order))
+
+	for i, index := range order {
+		assert.True(t, index >= 1 && index <= 10)
+
+		if i%4 > 0 {
+			assert.Equal(t, order[i-1]+1, index)
+		}
+	}
+
+	shuffled := shuffledDays(oscillatingCandles(0, 288*2), rand.New(rand.NewSource(1)))
+	sort.Ints(shuffled)
+	assert.Equal(t, 1, shuffled[0])
+	assert.Equal(t, 288*2-1, shuffled[len(shuffled)-1])
+	assert.Equal(t, 288*2-1, len(shuffled))
+}
+
+func TestNewDistribution(t *testing.T) {
+	d := NewDistribution([]float64{4, 1, 3, 2, 5})
+
+	assert.Equal(t, 3.0, d.Mean)
+	assert.Equal(t, 1.0, d.Min)
+	assert.Equal(t, 5.0, d.Max)
+	assert.Equal(t, 3.0, d.Median)
+	assert.Equal(t, 2.0, d.P25)
+	assert.InDelta(t, 1.2, d.P5, 1e-12)
+	assert.InDelta(t, 4.8, d.P95, 1e-12)
+	assert.InDelta(t, 1.4142135623730951, d.StdDev, 1e-12)
+}
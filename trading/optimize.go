@@ -0,0 +1,353 @@
+package trading
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Objectives to rank optimisation results by.
+const OBJECTIVE_RETURN = "return"
+const OBJECTIVE_SHARPE = "sharpe"
+const OBJECTIVE_RETURN_DRAWDOWN = "return-drawdown"
+
+// Drawdowns smaller than this are counted as this much when adjusting returns
+// for drawdown, so a config that barely traded does not rank first.
+const MIN_OBJECTIVE_DRAWDOWN = 0.01
+
+func ValidObjective(objective string) bool {
+	return objective == OBJECTIVE_RETURN || objective == OBJECTIVE_SHARPE || objective == OBJECTIVE_RETURN_DRAWDOWN
+}
+
+// ObjectiveScore is higher for better results.
+func ObjectiveScore(objective string, result *BacktestResult) float64 {
+	switch objective {
+	case OBJECTIVE_SHARPE:
+		return result.Sharpe
+	case OBJECTIVE_RETURN_DRAWDOWN:
+		return result.TotalReturn / math.Max(result.MaxDrawdown, MIN_OBJECTIVE_DRAWDOWN)
+	default:
+		return result.TotalReturn
+	}
+}
+
+// ParameterRange is the values of one numeric TraderConfig field to try, from
+// Min to Max inclusive in steps of Step.
+type ParameterRange struct {
+	Field string
+	Min   float64
+	Max   float64
+	Step  float64
+}
+
+// ParseParameterRange parses FIELD=MIN:MAX:STEP, or FIELD=VALUE for a single
+// value, e.g. ProfitFactor=1.02:1.1:0.02.
+func ParseParameterRange(spec string) (*ParameterRange, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 {
+		return nil, fmt.Errorf("invalid parameter range, expected FIELD=MIN:MAX:STEP: %s", spec)
+	}
+
+	bounds := strings.Split(parts[1], ":")
+	if len(bounds) != 1 && len(bounds) != 3 {
+		return nil, fmt.Errorf("invalid parameter range, expected FIELD=MIN:MAX:STEP: %s", spec)
+	}
+
+	values := make([]float64, 0, 3)
+	for _, bound := range bounds {
+		value, err := strconv.ParseFloat(bound, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameter range %s: %s", spec, err.Error())
+		}
+		values = append(values, value)
+	}
+
+	r := &ParameterRange{Field: parts[0], Min: values[0], Max: values[0]}
+
+	if len(values) == 3 {
+		r.Max, r.Step = values[1], values[2]
+	}
+
+	if r.Max < r.Min || (r.Max > r.Min && r.Step <= 0) {
+		return nil, fmt.Errorf("invalid parameter range %s: empty or no positive step", spec)
+	}
+
+	_, err := configField(&TraderConfig{}, r.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *ParameterRange) Values() []float64 {
+	if r.Max <= r.Min {
+		return []float64{r.Min}
+	}
+
+	// allow for rounding in (Max - Min) / Step so Max itself is included
+	n := int(math.Floor((r.Max-r.Min)/r.Step+1e-9)) + 1
+	values := make([]float64, 0, n)
+
+	for i := 0; i < n; i += 1 {
+		values = append(values, r.Min+float64(i)*r.Step)
+	}
+
+	return values
+}
+
+func (r *ParameterRange) String() string {
+	return fmt.Sprintf("%s=%g:%g:%g", r.Field, r.Min, r.Max, r.Step)
+}
+
+func configField(config *TraderConfig, field string) (reflect.Value, error) {
+	value := reflect.ValueOf(config).Elem().FieldByName(field)
+
+	if !value.IsValid() {
+		return value, fmt.Errorf("unknown TraderConfig field: %s", field)
+	}
+
+	if value.Kind() != reflect.Int64 && value.Kind() != reflect.Float64 {
+		return value, fmt.Errorf("TraderConfig field is not numeric: %s", field)
+	}
+
+	return value, nil
+}
+
+// SetConfigField sets a numeric TraderConfig field by name. Integer fields are
+// rounded to the nearest integer.
+func SetConfigField(config *TraderConfig, field string, value float64) error {
+	v, err := configField(config, field)
+	if err != nil {
+		return err
+	}
+
+	if v.Kind() == reflect.Int64 {
+		v.SetInt(int64(math.Round(value)))
+	} else {
+		v.SetFloat(value)
+	}
+
+	return nil
+}
+
+/**
+ * Optimizer
+ *
+ * Backtests every combination of the parameter ranges applied on top of the
+ * config of a template backtest, spread over Workers goroutines.
+ */
+
+type Optimizer struct {
+	Backtest  *Backtest
+	Ranges    []*ParameterRange
+	Objective string
+	// Defaults to the number of CPUs.
+	Workers int
+}
+
+type OptimizeResult struct {
+	// The value of each range, in the order of Optimizer.Ranges.
+	Params []float64
+	Config *TraderConfig
+	Result *BacktestResult
+	Score  float64
+	// Set instead of Result when the backtest failed, e.g. because the
+	// combination is not a valid config.
+	Err error
+}
+
+type OptimizeReport struct {
+	Ranges    []*ParameterRange
+	Objective string
+	// Sorted from the best score to the worst, followed by the failures.
+	Results []*OptimizeResult
+}
+
+// Grid returns every combination of the range values, the last range
+// varying fastest.
+func (o *Optimizer) Grid() [][]float64 {
+	grid := [][]float64{[]float64{}}
+
+	for _, r := range o.Ranges {
+		expanded := make([][]float64, 0, len(grid)*len(r.Values()))
+
+		for _, params := range grid {
+			for _, value := range r.Values() {
+				combination := make([]float64, len(params), len(params)+1)
+				copy(combination, params)
+				expanded = append(expanded, append(combination, value))
+			}
+		}
+
+		grid = expanded
+	}
+
+	return grid
+}
+
+func (o *Optimizer) Run() (*OptimizeReport, error) {
+	if !ValidObjective(o.Objective) {
+		return nil, fmt.Errorf("unknown objective: %s", o.Objective)
+	}
+
+	grid := o.Grid()
+	results := make([]*OptimizeResult, len(grid))
+
+	for i, params := range grid {
+		config := *o.Backtest.Config
+
+		for j, r := range o.Ranges {
+			err := SetConfigField(&config, r.Field, params[j])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		results[i] = &OptimizeResult{Params: params, Config: &config}
+	}
+
+	workers := o.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	jobs := make(chan *OptimizeResult)
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w += 1 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for job := range jobs {
+				backtest := *o.Backtest
+				backtest.Config = job.Config
+				backtest.Journal = nil
+
+				job.Result, job.Err = backtest.Run()
+				if job.Err == nil {
+					job.Score = ObjectiveScore(o.Objective, job.Result)
+				}
+			}
+		}()
+	}
+
+	for _, job := range results {
+		jobs <- job
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Stable(ByScore(results))
+
+	return &OptimizeReport{Ranges: o.Ranges, Objective: o.Objective, Results: results}, nil
+}
+
+type ByScore []*OptimizeResult
+
+func (a ByScore) Len() int      { return len(a) }
+func (a ByScore) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a ByScore) Less(i, j int) bool {
+	if a[i].Err != nil || a[j].Err != nil {
+		return a[i].Err == nil && a[j].Err != nil
+	}
+	return a[i].Score > a[j].Score
+}
+
+// WriteCSV writes one row per combination, best first.
+func (report *OptimizeReport) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+
+	header := make([]string, 0)
+	for _, r := range report.Ranges {
+		header = append(header, r.Field)
+	}
+	header = append(header,
+		"score", "total_return", "buy_and_hold_return", "max_drawdown", "max_drawdown_duration",
+		"sharpe", "sortino", "trades", "win_rate", "avg_holding_time", "fees_paid", "exposure", "error",
+	)
+	out.Write(header)
+
+	for _, result := range report.Results {
+		row := make([]string, 0, len(header))
+		for _, param := range result.Params {
+			row = append(row, strconv.FormatFloat(param, 'g', -1, 64))
+		}
+
+		if result.Err != nil {
+			for len(row) < len(header)-1 {
+				row = append(row, "")
+			}
+			out.Write(append(row, result.Err.Error()))
+			continue
+		}
+
+		r := result.Result
+		out.Write(append(row,
+			formatMetric(result.Score),
+			formatMetric(r.TotalReturn),
+			formatMetric(r.BuyAndHoldReturn),
+			formatMetric(r.MaxDrawdown),
+			strconv.FormatInt(r.MaxDrawdownDuration, 10),
+			formatMetric(r.Sharpe),
+			formatMetric(r.Sortino),
+			strconv.Itoa(r.Trades),
+			formatMetric(r.WinRate),
+			strconv.FormatInt(r.AvgHoldingTime, 10),
+			formatReportAmount(r.FeesPaid),
+			formatMetric(r.Exposure),
+			"",
+		))
+	}
+
+	out.Flush()
+
+	return out.Error()
+}
+
+// WriteTable prints the top results.
+func (report *OptimizeReport) WriteTable(w io.Writer, top int) {
+	fmt.Fprintf(w, "%-4s %-40s %10s %10s %10s %10s %8s\n", "rank", "params", "score", "return", "sharpe", "drawdown", "trades")
+
+	for i, result := range report.Results {
+		if i >= top {
+			break
+		}
+
+		params := make([]string, 0, len(result.Params))
+		for j, param := range result.Params {
+			params = append(params, fmt.Sprintf("%s=%g", report.Ranges[j].Field, param))
+		}
+
+		if result.Err != nil {
+			fmt.Fprintf(w, "%-4d %-40s %s\n", i+1, strings.Join(params, " "), result.Err.Error())
+			continue
+		}
+
+		fmt.Fprintf(w, "%-4d %-40s %10.4f %9.2f%% %10.3f %9.2f%% %8d\n",
+			i+1,
+			strings.Join(params, " "),
+			result.Score,
+			result.Result.TotalReturn*100,
+			result.Result.Sharpe,
+			result.Result.MaxDrawdown*100,
+			result.Result.Trades)
+	}
+}
+
+func formatMetric(value float64) string {
+	return strconv.FormatFloat(value, 'f', 6, 64)
+}
+
+func formatReportAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 8, 64)
+}
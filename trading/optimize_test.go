@@ -0,0 +1,121 @@
+package trading
+
+import (
+	"bytes"
+	"encoding/csv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParameterRange(t *testing.T) {
+	r, err := ParseParameterRange("ProfitFactor=1.02:1.1:0.02")
+	require.Nil(t, err)
+	assert.Equal(t, "ProfitFactor", r.Field)
+
+	values := r.Values()
+	require.Equal(t, 5, len(values))
+	assert.InDelta(t, 1.1, values[4], 1e-12)
+
+	r, err = ParseParameterRange("BuyThresholdStart=40")
+	require.Nil(t, err)
+	assert.Equal(t, []float64{40}, r.Values())
+
+	invalid := []string{
+		"ProfitFactor",
+		"ProfitFactor=1.1:1.02:0.02",
+		"ProfitFactor=1.02:1.1:0",
+		"ProfitFactor=1.02:1.1",
+		"ProfitFactor=high",
+		"NoSuchField=1",
+		"StateKey=1",
+	}
+
+	for _, spec := range invalid {
+		_, err := ParseParameterRange(spec)
+		assert.NotNil(t, err, spec)
+	}
+}
+
+func TestSetConfigField(t *testing.T) {
+	config := DefaultTraderConfig()
+
+	require.Nil(t, SetConfigField(config, "BuyThresholdStart", 39.6))
+	require.Nil(t, SetConfigField(config, "VolatilityFactor", 1.03))
+
+	assert.Equal(t, int64(40), config.BuyThresholdStart)
+	assert.Equal(t, 1.03, config.VolatilityFactor)
+}
+
+func TestOptimizer(t *testing.T) {
+	candles := oscillatingCandles(0, 288*4)
+
+	optimizer := &Optimizer{
+		Backtest: &Backtest{
+			Market:          "BTC_ABC",
+			Config:          DefaultTraderConfig(),
+			Candles:         candles,
+			Resolution:      300,
+			StartTime:       86400,
+			EndTime:         candles[len(candles)-1].Date,
+			StartingBalance: 0.1,
+			Fees:            FeeSchedule{Maker: 0.0015, Taker: 0.0025},
+		},
+		Ranges: []*ParameterRange{
+			&ParameterRange{Field: "ProfitFactor", Min: 1.02, Max: 1.06, Step: 0.04},
+			&ParameterRange{Field: "VolatilityIndexUpperPercentile", Min: 55, Max: 105, Step: 50},
+		},
+		Objective: OBJECTIVE_SHARPE,
+		Workers:   2,
+	}
+
+	assert.Equal(t, [][]float64{{1.02, 55}, {1.02, 105}, {1.06, 55}, {1.06, 105}}, optimizer.Grid())
+
+	report, err := optimizer.Run()
+	require.Nil(t, err)
+	require.Equal(t, 4, len(report.Results))
+
+	for i, result := range report.Results {
+		if i < 2 {
+			require.Nil(t, result.Err)
+			assert.Equal(t, 55.0, result.Params[1])
+			assert.Equal(t, result.Result.Sharpe, result.Score)
+		} else {
+			assert.NotNil(t, result.Err)
+		}
+	}
+
+	assert.True(t, report.Results[0].Score >= report.Results[1].Score)
+
+	// the template config is not modified
+	assert.Equal(t, 1.06, optimizer.Backtest.Config.ProfitFactor)
+
+	t.Run("WriteCSV", func(t *testing.T) {
+		var buffer bytes.Buffer
+		require.Nil(t, report.WriteCSV(&buffer))
+
+		rows, err := csv.NewReader(&buffer).ReadAll()
+		require.Nil(t, err)
+		require.Equal(t, 5, len(rows))
+		assert.Equal(t, []string{"ProfitFactor", "VolatilityIndexUpperPercentile", "score"}, rows[0][:3])
+		assert.Equal(t, "", rows[1][len(rows[1])-1])
+		assert.Contains(t, rows[4][len(rows[4])-1], "VolatilityIndexUpperPercentile")
+	})
+
+	t.Run("unknown objective", func(t *testing.T) {
+		optimizer.Objective = "luck"
+		_, err := optimizer.Run()
+		assert.NotNil(t, err)
+	})
+}
+
+func TestObjectiveScore(t *testing.T) {
+	result := &BacktestResult{TotalReturn: 0.2, Sharpe: 1.5, MaxDrawdown: 0.1}
+
+	assert.Equal(t, 0.2, ObjectiveScore(OBJECTIVE_RETURN, result))
+	assert.Equal(t, 1.5, ObjectiveScore(OBJECTIVE_SHARPE, result))
+	assert.InDelta(t, 2.0, ObjectiveScore(OBJECTIVE_RETURN_DRAWDOWN, result), 1e-12)
+
+	result.MaxDrawdown = 0.0
+	assert.InDelta(t, 20.0, ObjectiveScore(OBJECTIVE_RETURN_DRAWDOWN, result), 1e-12)
+}
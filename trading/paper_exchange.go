@@ -0,0 +1,495 @@
+package trading
+
+import (
+	"fmt"
+	"github.com/jbgo/sftbot/data"
+	"github.com/jbgo/sftbot/db"
+	"time"
+)
+
+// The key the paper exchange's balances, order book and fills are saved
+// under.
+const PAPER_STATE_KEY = "paper_exchange"
+
+/**
+ * PaperExchange
+ *
+ * An Exchange for forward testing: prices and chart data come from a
+ * PaperFeed, but orders never leave the process. Balances are virtual, and
+ * orders rest in a local order book until public trades fill them:
+ *
+ * - An order that crosses the ticker when it is placed (a buy at or above the
+ *   lowest ask, a sell at or below the highest bid) fills right away as a
+ *   taker at the ticker's price.
+ * - Otherwise, every public trade after the order was placed at or through
+ *   its price (at or below a buy, at or above a sell) fills it as a maker at
+ *   its own price, up to the amount of the trade. A public trade is only used
+ *   once across the orders of a market, oldest order first.
+ * - Like on Poloniex, a post-only order that would cross the ticker and an
+ *   immediate order that would not are rejected.
+ *
+ * The state is saved after every change, so a forward test can run for weeks
+ * across restarts.
+ */
+
+type PaperExchange struct {
+	Feed PaperFeed
+	Fees FeeSchedule
+	// Optional. The state is loaded from and saved to PAPER_STATE_KEY.
+	DB db.Store
+	// Defaults to time.Now.
+	Clock func() time.Time
+	State *PaperState
+}
+
+type PaperState struct {
+	Balances map[string]*Balance
+	// Open orders, oldest first.
+	Orders []*PaperOrder
+	// Every fill, oldest first.
+	Trades      []*Trade
+	NextOrderId int64
+	// The newest public trade of each market matched against the order book,
+	// by date and then global trade id.
+	LastTradeDate map[string]int64
+	LastTradeId   map[string]int64
+}
+
+type PaperOrder struct {
+	Order
+	Market string
+	// When the order was placed. Only later public trades fill it.
+	Date      int64
+	Remaining data.Decimal
+	// The part of the balance held for the rest of the order.
+	Reserved data.Decimal
+}
+
+type PaperMarket struct {
+	Name     string
+	Exchange *PaperExchange
+}
+
+// NewPaperExchange loads the saved state from dbStore, or starts with the
+// given balances when nothing has been saved yet. dbStore may be nil.
+func NewPaperExchange(feed PaperFeed, dbStore db.Store, balances map[string]float64) (*PaperExchange, error) {
+	exchange := &PaperExchange{
+		Feed: feed,
+		Fees: FeeSchedule{Maker: SIMULATED_MAKER_FEE, Taker: SIMULATED_TAKER_FEE},
+		DB:   dbStore,
+	}
+
+	if dbStore != nil {
+		err, hasData := dbStore.HasData(PAPER_STATE_KEY)
+		if err != nil {
+			return nil, err
+		}
+
+		if hasData {
+			exchange.State = &PaperState{}
+
+			err = dbStore.Read(PAPER_STATE_KEY, exchange.State)
+			if err != nil {
+				return nil, err
+			}
+
+			return exchange, nil
+		}
+	}
+
+	exchange.State = &PaperState{
+		Balances:      make(map[string]*Balance),
+		Orders:        make([]*PaperOrder, 0),
+		Trades:        make([]*Trade, 0),
+		LastTradeDate: make(map[string]int64),
+		LastTradeId:   make(map[string]int64),
+	}
+
+	for currency, amount := range balances {
+		exchange.State.Balances[currency] = &Balance{Available: data.NewDecimal(amount)}
+	}
+
+	return exchange, exchange.save()
+}
+
+func (exchange *PaperExchange) GetMarket(marketName string) (Market, error) {
+	ticker, err := exchange.Feed.GetTicker()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := ticker[marketName]; !ok {
+		return nil, fmt.Errorf("unknown market: %s", marketName)
+	}
+
+	return &PaperMarket{Name: marketName, Exchange: exchange}, nil
+}
+
+func (exchange *PaperExchange) GetTicker() (map[string]*TickerEntry, error) {
+	return exchange.Feed.GetTicker()
+}
+
+// GetBalance first matches the order book against the latest public trades
+// of every market with open orders.
+func (exchange *PaperExchange) GetBalance(currency string) (*Balance, error) {
+	for _, marketName := range exchange.openMarkets() {
+		err := exchange.Sync(marketName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	balance := *exchange.balance(currency)
+	return &balance, nil
+}
+
+// Sync fills the open orders of a market with the public trades since the
+// last sync.
+func (exchange *PaperExchange) Sync(marketName string) error {
+	orders := exchange.marketOrders(marketName)
+	if len(orders) == 0 {
+		return nil
+	}
+
+	startTime := exchange.State.LastTradeDate[marketName]
+	if startTime < orders[0].Date {
+		startTime = orders[0].Date
+	}
+
+	ticks, err := exchange.Feed.GetTrades(marketName, startTime, exchange.now().Unix())
+	if err != nil {
+		return err
+	}
+
+	for _, tick := range ticks {
+		if !exchange.isNewTrade(marketName, tick) {
+			continue
+		}
+
+		exchange.State.LastTradeDate[marketName] = tick.Date
+		exchange.State.LastTradeId[marketName] = tick.GlobalTradeId
+
+		available := data.NewDecimal(tick.Amount)
+
+		for _, order := range orders {
+			if available <= 0 {
+				break
+			}
+
+			if order.Remaining <= 0 || tick.Date <= order.Date || !crosses(order, tick.Rate) {
+				continue
+			}
+
+			amount := order.Remaining
+			if amount > available {
+				amount = available
+			}
+
+			exchange.fill(order, order.Price, amount, false, tick.Date)
+			available -= amount
+		}
+	}
+
+	exchange.removeFilledOrders()
+
+	return exchange.save()
+}
+
+func (exchange *PaperExchange) isNewTrade(marketName string, tick *data.Tick) bool {
+	lastDate := exchange.State.LastTradeDate[marketName]
+
+	return tick.Date > lastDate ||
+		(tick.Date == lastDate && tick.GlobalTradeId > exchange.State.LastTradeId[marketName])
+}
+
+// crosses tells whether a trade at price would fill the order.
+func crosses(order *PaperOrder, price float64) bool {
+	if order.Type == "buy" {
+		return price <= order.Price.Float64()
+	}
+
+	return price >= order.Price.Float64()
+}
+
+func (exchange *PaperExchange) placeOrder(market *PaperMarket, order *Order) error {
+	// reject what Poloniex would reject
+	info, _ := market.GetInfo()
+	err := info.Validate(order)
+	if err != nil {
+		return fmt.Errorf("invalid order: %s", err.Error())
+	}
+
+	ticker, err := exchange.Feed.GetTicker()
+	if err != nil {
+		return err
+	}
+
+	entry, ok := ticker[market.Name]
+	if !ok {
+		return fmt.Errorf("unknown market: %s", market.Name)
+	}
+
+	lowestAsk := data.NewDecimal(entry.LowestAsk)
+	highestBid := data.NewDecimal(entry.HighestBid)
+
+	crosses := highestBid > 0 && order.Price <= highestBid
+	if order.Type == "buy" {
+		crosses = lowestAsk > 0 && order.Price >= lowestAsk
+	}
+
+	if crosses && order.Execution == ORDER_POST_ONLY {
+		return &OrderCancelledError{Order: order, Reason: "would fill immediately"}
+	}
+
+	if !crosses && order.Immediate() {
+		return &OrderCancelledError{Order: order, Reason: "would not fill immediately"}
+	}
+
+	order.Total = order.Price.Mul(order.Amount)
+	paper := &PaperOrder{Order: *order, Market: market.Name, Date: exchange.now().Unix(), Remaining: order.Amount}
+
+	// hold enough for the order to fill at its price as a taker
+	if order.Type == "buy" {
+		paper.Reserved = order.Total + order.Total.MulFloat(exchange.Fees.Taker)
+	} else {
+		paper.Reserved = order.Amount
+	}
+
+	held := exchange.heldBalance(paper)
+	if held.Available < paper.Reserved {
+		return fmt.Errorf("insufficient balance: %s < %s", held.Available, paper.Reserved)
+	}
+
+	exchange.State.NextOrderId += 1
+	order.Id = fmt.Sprintf("paper%d", exchange.State.NextOrderId)
+	paper.Id = order.Id
+
+	held.Available -= paper.Reserved
+	held.OnOrders += paper.Reserved
+
+	if crosses && order.Type == "buy" {
+		exchange.fill(paper, lowestAsk, order.Amount, true, paper.Date)
+	} else if crosses {
+		exchange.fill(paper, highestBid, order.Amount, true, paper.Date)
+	}
+
+	if paper.Remaining > 0 {
+		exchange.State.Orders = append(exchange.State.Orders, paper)
+	}
+
+	return exchange.save()
+}
+
+func (exchange *PaperExchange) fill(order *PaperOrder, price, amount data.Decimal, taker bool, date int64) {
+	base := exchange.balance(marketSymbol(order.Market).Base)
+	alt := exchange.balance(marketSymbol(order.Market).Currency)
+
+	total := price.Mul(amount)
+	fee := total.MulFloat(exchange.Fees.Maker)
+	if taker {
+		fee = total.MulFloat(exchange.Fees.Taker)
+	}
+
+	if order.Type == "buy" {
+		base.OnOrders -= total + fee
+		order.Reserved -= total + fee
+		alt.Available += amount
+	} else {
+		alt.OnOrders -= amount
+		order.Reserved -= amount
+		base.Available += total - fee
+	}
+
+	order.Remaining -= amount
+
+	if order.Remaining <= 0 {
+		held := exchange.heldBalance(order)
+		held.OnOrders -= order.Reserved
+		held.Available += order.Reserved
+		order.Reserved = 0
+	}
+
+	fills := 0
+	for _, trade := range exchange.State.Trades {
+		if trade.OrderId == order.Id {
+			fills += 1
+		}
+	}
+
+	exchange.State.Trades = append(exchange.State.Trades, &Trade{
+		Id:       fmt.Sprintf("%s-%d", order.Id, fills+1),
+		OrderId:  order.Id,
+		Market:   order.Market,
+		Date:     date,
+		Type:     order.Type,
+		Price:    price,
+		Amount:   amount,
+		Total:    total,
+		Fee:      fee,
+		Exchange: "paper",
+	})
+}
+
+func (exchange *PaperExchange) removeFilledOrders() {
+	open := make([]*PaperOrder, 0, len(exchange.State.Orders))
+
+	for _, order := range exchange.State.Orders {
+		if order.Remaining > 0 {
+			open = append(open, order)
+		}
+	}
+
+	exchange.State.Orders = open
+}
+
+func (exchange *PaperExchange) marketOrders(marketName string) []*PaperOrder {
+	orders := make([]*PaperOrder, 0)
+
+	for _, order := range exchange.State.Orders {
+		if order.Market == marketName {
+			orders = append(orders, order)
+		}
+	}
+
+	return orders
+}
+
+func (exchange *PaperExchange) openMarkets() []string {
+	seen := make(map[string]bool)
+	markets := make([]string, 0)
+
+	for _, order := range exchange.State.Orders {
+		if !seen[order.Market] {
+			seen[order.Market] = true
+			markets = append(markets, order.Market)
+		}
+	}
+
+	return markets
+}
+
+func (exchange *PaperExchange) balance(currency string) *Balance {
+	balance, ok := exchange.State.Balances[currency]
+	if !ok {
+		balance = &Balance{}
+		exchange.State.Balances[currency] = balance
+	}
+
+	return balance
+}
+
+func (exchange *PaperExchange) heldBalance(order *PaperOrder) *Balance {
+	if order.Type == "buy" {
+		return exchange.balance(marketSymbol(order.Market).Base)
+	}
+
+	return exchange.balance(marketSymbol(order.Market).Currency)
+}
+
+func (exchange *PaperExchange) save() error {
+	if exchange.DB == nil {
+		return nil
+	}
+
+	return exchange.DB.Write(PAPER_STATE_KEY, exchange.State)
+}
+
+func (exchange *PaperExchange) now() time.Time {
+	if exchange.Clock == nil {
+		return time.Now()
+	}
+
+	return exchange.Clock()
+}
+
+func (market *PaperMarket) Buy(order *Order) error {
+	order.Type = "buy"
+	return market.Exchange.placeOrder(market, order)
+}
+
+func (market *PaperMarket) Sell(order *Order) error {
+	order.Type = "sell"
+	return market.Exchange.placeOrder(market, order)
+}
+
+func (market *PaperMarket) Exists() bool {
+	ticker, err := market.Exchange.Feed.GetTicker()
+	if err != nil {
+		return false
+	}
+
+	_, ok := ticker[market.Name]
+
+	return ok
+}
+
+func (market *PaperMarket) GetBaseCurrency() string {
+	return marketSymbol(market.Name).Base
+}
+
+func (market *PaperMarket) GetCurrency() string {
+	return marketSymbol(market.Name).Currency
+}
+
+func (market *PaperMarket) GetCurrentPrice() (float64, error) {
+	ticker, err := market.Exchange.Feed.GetTicker()
+	if err != nil {
+		return 0.0, err
+	}
+
+	entry, ok := ticker[market.Name]
+	if !ok {
+		return 0.0, fmt.Errorf("unknown market: %s", market.Name)
+	}
+
+	return entry.Last, nil
+}
+
+// GetInfo describes Poloniex's markets with the exchange's fees.
+func (market *PaperMarket) GetInfo() (*MarketInfo, error) {
+	info := NewPlxMarketInfo()
+	info.Fees = market.Exchange.Fees
+	return info, nil
+}
+
+func (market *PaperMarket) GetName() string {
+	return market.Name
+}
+
+func (market *PaperMarket) GetOrderTrades(order *Order) ([]*Trade, error) {
+	err := market.Exchange.Sync(market.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	trades := make([]*Trade, 0)
+	for _, trade := range market.Exchange.State.Trades {
+		if trade.OrderId == order.Id {
+			trades = append(trades, trade)
+		}
+	}
+
+	return trades, nil
+}
+
+// GetPendingOrders returns copies of the open orders, with Amount set to what
+// is left to fill.
+func (market *PaperMarket) GetPendingOrders() ([]*Order, error) {
+	err := market.Exchange.Sync(market.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make([]*Order, 0)
+	for _, paper := range market.Exchange.marketOrders(market.Name) {
+		order := paper.Order
+		order.Amount = paper.Remaining
+		orders = append(orders, &order)
+	}
+
+	return orders, nil
+}
+
+func (market *PaperMarket) GetSummaryData(startTime, endTime int64) ([]*SummaryData, error) {
+	return market.Exchange.Feed.GetSummaryData(market.Name, startTime, endTime)
+}
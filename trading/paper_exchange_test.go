@@ -0,0 +1,181 @@
+package trading
+
+import (
+	"github.com/jbgo/sftbot/data"
+	"github.com/jbgo/sftbot/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type stubPaperFeed struct {
+	ticker map[string]*TickerEntry
+	ticks  []*data.Tick
+}
+
+func (feed *stubPaperFeed) GetTicker() (map[string]*TickerEntry, error) {
+	return feed.ticker, nil
+}
+
+func (feed *stubPaperFeed) GetSummaryData(marketName string, startTime, endTime int64) ([]*SummaryData, error) {
+	return []*SummaryData{&SummaryData{Date: startTime, WeightedAverage: feed.ticker[marketName].Last}}, nil
+}
+
+func (feed *stubPaperFeed) GetTrades(marketName string, startTime, endTime int64) ([]*data.Tick, error) {
+	ticks := make([]*data.Tick, 0)
+
+	for _, t := range feed.ticks {
+		if t.Date >= startTime && t.Date <= endTime {
+			ticks = append(ticks, t)
+		}
+	}
+
+	return ticks, nil
+}
+
+func TestPaperExchange(t *testing.T) {
+	feed := &stubPaperFeed{
+		ticker: map[string]*TickerEntry{
+			"BTC_ABC": &TickerEntry{Market: "BTC_ABC", Last: 0.1, LowestAsk: 0.101, HighestBid: 0.099},
+		},
+	}
+
+	now := int64(1000)
+	dbStore := db.NewMemoryStore()
+
+	exchange, err := NewPaperExchange(feed, dbStore, map[string]float64{"BTC": 1.0})
+	require.Nil(t, err)
+	exchange.Fees = FeeSchedule{Maker: 0.001, Taker: 0.002}
+	exchange.Clock = func() time.Time { return time.Unix(now, 0) }
+
+	_, err = exchange.GetMarket("BTC_XYZ")
+	assert.NotNil(t, err)
+
+	market, err := exchange.GetMarket("BTC_ABC")
+	require.Nil(t, err)
+
+	bid := &Order{Price: data.NewDecimal(0.09), Amount: data.NewDecimal(3.0)}
+	require.Nil(t, market.Buy(bid))
+	assert.Equal(t, "paper1", bid.Id)
+
+	btc, _ := exchange.GetBalance("BTC")
+	assert.Equal(t, data.NewDecimal(1.0-0.27*1.002), btc.Available)
+	assert.Equal(t, data.NewDecimal(0.27*1.002), btc.OnOrders)
+
+	t.Run("public trades fill resting orders", func(t *testing.T) {
+		feed.ticks = []*data.Tick{
+			// before the order was placed
+			&data.Tick{GlobalTradeId: 1, Date: 900, Rate: 0.08, Amount: 10},
+			// above the bid
+			&data.Tick{GlobalTradeId: 2, Date: 1100, Rate: 0.095, Amount: 10},
+			&data.Tick{GlobalTradeId: 3, Date: 1200, Rate: 0.09, Amount: 1},
+		}
+		now = 1300
+
+		pending, err := market.GetPendingOrders()
+		require.Nil(t, err)
+		require.Equal(t, 1, len(pending))
+		assert.Equal(t, data.NewDecimal(2.0), pending[0].Amount)
+
+		trades, err := market.GetOrderTrades(bid)
+		require.Nil(t, err)
+		require.Equal(t, 1, len(trades))
+		assert.Equal(t, int64(1200), trades[0].Date)
+		assert.Equal(t, data.NewDecimal(0.09*0.001), trades[0].Fee)
+		assert.Equal(t, "paper", trades[0].Exchange)
+
+		// the same public trade does not fill the order twice
+		pending, _ = market.GetPendingOrders()
+		assert.Equal(t, data.NewDecimal(2.0), pending[0].Amount)
+
+		feed.ticks = append(feed.ticks, &data.Tick{GlobalTradeId: 4, Date: 1300, Rate: 0.085, Amount: 5})
+		pending, _ = market.GetPendingOrders()
+		assert.Equal(t, 0, len(pending))
+
+		btc, _ := exchange.GetBalance("BTC")
+		abc, _ := exchange.GetBalance("ABC")
+		assert.Equal(t, data.NewDecimal(1.0-0.27*1.001), btc.Available)
+		assert.Equal(t, data.NewDecimal(0.0), btc.OnOrders)
+		assert.Equal(t, data.NewDecimal(3.0), abc.Available)
+	})
+
+	t.Run("marketable orders fill at the ticker", func(t *testing.T) {
+		ask := &Order{Price: data.NewDecimal(0.098), Amount: data.NewDecimal(1.0)}
+		require.Nil(t, market.Sell(ask))
+
+		trades, err := market.GetOrderTrades(ask)
+		require.Nil(t, err)
+		require.Equal(t, 1, len(trades))
+		assert.Equal(t, data.NewDecimal(0.099), trades[0].Price)
+		assert.Equal(t, data.NewDecimal(0.099*0.002), trades[0].Fee)
+
+		err = market.Sell(&Order{Price: data.NewDecimal(0.2), Amount: data.NewDecimal(5.0)})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("post-only and immediate orders", func(t *testing.T) {
+		err := market.Sell(&Order{Price: data.NewDecimal(0.098), Amount: data.NewDecimal(1.0), Execution: ORDER_POST_ONLY})
+		assert.IsType(t, &OrderCancelledError{}, err)
+
+		err = market.Buy(&Order{Price: data.NewDecimal(0.1), Amount: data.NewDecimal(1.0), Execution: ORDER_FILL_OR_KILL})
+		assert.IsType(t, &OrderCancelledError{}, err)
+
+		pending, _ := market.GetPendingOrders()
+		assert.Equal(t, 0, len(pending))
+	})
+
+	t.Run("state survives a restart", func(t *testing.T) {
+		restarted, err := NewPaperExchange(feed, dbStore, map[string]float64{"BTC": 5.0})
+		require.Nil(t, err)
+
+		abc, _ := restarted.GetBalance("ABC")
+		assert.Equal(t, data.NewDecimal(2.0), abc.Available)
+		assert.Equal(t, 3, len(restarted.State.Trades))
+		assert.Equal(t, int64(2), restarted.State.NextOrderId)
+	})
+}
+
+func TestPaperExchangeTrader(t *testing.T) {
+	feed := &stubPaperFeed{
+		ticker: map[string]*TickerEntry{
+			"BTC_ABC": &TickerEntry{Market: "BTC_ABC", Last: 0.1, LowestAsk: 0.101, HighestBid: 0.099},
+		},
+	}
+
+	exchange, err := NewPaperExchange(feed, nil, map[string]float64{"BTC": 1.0})
+	require.Nil(t, err)
+
+	now := int64(1000)
+	exchange.Clock = func() time.Time { return time.Unix(now, 0) }
+
+	config := DefaultTraderConfig()
+	config.Simulate = false
+
+	trader, err := NewTrader("BTC_ABC", exchange, db.NewMemoryStore(), config)
+	require.Nil(t, err)
+	trader.Clock = exchange.Clock
+
+	// the trader buys below the market and only considers the bid filled
+	// once a public trade reaches it
+	order, err := trader.Buy(&MarketData{CurrentPrice: 0.1, Percentiles: make([]float64, 101), VolatilityIndex: 2.0})
+	require.Nil(t, err)
+	assert.Nil(t, order)
+
+	trader.BTC_Balance, _ = exchange.GetBalance("BTC")
+	percentiles := make([]float64, 101)
+	percentiles[trader.BuyThreshold] = 0.2
+
+	order, err = trader.Buy(&MarketData{CurrentPrice: 0.1, Percentiles: percentiles, VolatilityIndex: 2.0})
+	require.Nil(t, err)
+	require.NotNil(t, order)
+
+	require.Nil(t, trader.Reconcile())
+	assert.False(t, trader.Bids[0].Filled)
+
+	feed.ticks = []*data.Tick{&data.Tick{GlobalTradeId: 1, Date: 1100, Rate: 0.09, Amount: 100}}
+	now = 1200
+
+	require.Nil(t, trader.Reconcile())
+	assert.True(t, trader.Bids[0].Filled)
+}
@@ -0,0 +1,140 @@
+package trading
+
+import (
+	"fmt"
+	"github.com/jbgo/sftbot/data"
+	"github.com/jbgo/sftbot/db"
+	"github.com/jbgo/sftbot/plx"
+	"sort"
+	"time"
+)
+
+// PaperFeed is the public market data a PaperExchange trades against.
+type PaperFeed interface {
+	GetTicker() (map[string]*TickerEntry, error)
+	GetSummaryData(marketName string, startTime, endTime int64) ([]*SummaryData, error)
+	// GetTrades returns the public trades of a market between startTime and
+	// endTime (inclusive), in the order they happened.
+	GetTrades(marketName string, startTime, endTime int64) ([]*data.Tick, error)
+}
+
+// PlxPaperFeed reads live public data from Poloniex.
+type PlxPaperFeed struct {
+	Client *plx.Client
+}
+
+func (feed *PlxPaperFeed) GetTicker() (map[string]*TickerEntry, error) {
+	exchange := &PlxExchange{Client: feed.Client}
+	return exchange.GetTicker()
+}
+
+func (feed *PlxPaperFeed) GetSummaryData(marketName string, startTime, endTime int64) ([]*SummaryData, error) {
+	market := &PlxMarket{Name: marketName, Client: feed.Client}
+	return market.GetSummaryData(startTime, endTime)
+}
+
+func (feed *PlxPaperFeed) GetTrades(marketName string, startTime, endTime int64) ([]*data.Tick, error) {
+	params := &plx.TradeHistoryParams{Market: marketName, StartTime: startTime, EndTime: endTime}
+
+	plxTrades, err := feed.Client.GetTradeHistory(params)
+	if err != nil {
+		return nil, err
+	}
+
+	ticks := make([]*data.Tick, 0, len(plxTrades))
+	for _, t := range plxTrades {
+		ticks = append(ticks, &data.Tick{
+			GlobalTradeId: t.GlobalTradeId,
+			TradeId:       t.TradeId,
+			Date:          t.Date.Unix(),
+			Type:          t.Type,
+			Rate:          t.Rate,
+			Amount:        t.Amount,
+			Total:         t.Total,
+		})
+	}
+
+	// Poloniex returns the newest trades first
+	sort.Stable(byTickTime(ticks))
+
+	return ticks, nil
+}
+
+/**
+ * RecordedPaperFeed
+ *
+ * Reads public data recorded in the local database, e.g. by running ticks
+ * import and chart-data import on a schedule. The ticker of a market is built
+ * from its recorded ticks: the last price is that of the newest tick, and the
+ * volumes are those of the ticks in the 24 hours before it. There is no order
+ * book, so the lowest ask and highest bid are the last price.
+ */
+type RecordedPaperFeed struct {
+	DB      *db.DB
+	Markets []string
+}
+
+func (feed *RecordedPaperFeed) GetTicker() (map[string]*TickerEntry, error) {
+	ticker := make(map[string]*TickerEntry)
+
+	for _, name := range feed.Markets {
+		last, err := feed.DB.Ticks().LastTick(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if last == nil {
+			continue
+		}
+
+		ticks, err := feed.DB.Ticks().GetTicks(name, last.Date-TICKER_VOLUME_WINDOW, last.Date)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := &TickerEntry{Market: name, Last: last.Rate, LowestAsk: last.Rate, HighestBid: last.Rate}
+		for _, t := range ticks {
+			entry.BaseVolume += t.Total
+			entry.QuoteVolume += t.Amount
+		}
+
+		ticker[name] = entry
+	}
+
+	return ticker, nil
+}
+
+func (feed *RecordedPaperFeed) GetSummaryData(marketName string, startTime, endTime int64) ([]*SummaryData, error) {
+	periods, err := feed.DB.ChartData().GetPeriods(marketName, data.BASE_RESOLUTION, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	summaryData := make([]*SummaryData, 0, len(periods))
+	for _, d := range periods {
+		s := SummaryData(*d)
+		summaryData = append(summaryData, &s)
+	}
+
+	if len(summaryData) == 0 {
+		return nil, fmt.Errorf("no recorded chart data for %s between %s and %s", marketName,
+			time.Unix(startTime, 0).UTC().Format(time.RFC3339), time.Unix(endTime, 0).UTC().Format(time.RFC3339))
+	}
+
+	return summaryData, nil
+}
+
+func (feed *RecordedPaperFeed) GetTrades(marketName string, startTime, endTime int64) ([]*data.Tick, error) {
+	return feed.DB.Ticks().GetTicks(marketName, startTime, endTime)
+}
+
+type byTickTime []*data.Tick
+
+func (a byTickTime) Len() int      { return len(a) }
+func (a byTickTime) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byTickTime) Less(i, j int) bool {
+	if a[i].Date != a[j].Date {
+		return a[i].Date < a[j].Date
+	}
+	return a[i].GlobalTradeId < a[j].GlobalTradeId
+}
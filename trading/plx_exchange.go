@@ -28,3 +28,19 @@ func (exchange *PlxExchange) GetBalance(currency string) (*Balance, error) {
 		BtcValue:  plxBalance.BtcValue,
 	}, nil
 }
+
+func (exchange *PlxExchange) GetTicker() (map[string]*TickerEntry, error) {
+	plxTicker, err := exchange.Client.GetTickerMap()
+	if err != nil {
+		return nil, err
+	}
+
+	ticker := make(map[string]*TickerEntry, len(plxTicker))
+	for name, t := range plxTicker {
+		entry := TickerEntry(t)
+		entry.Market = name
+		ticker[name] = &entry
+	}
+
+	return ticker, nil
+}
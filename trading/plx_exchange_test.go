@@ -2,15 +2,15 @@ package trading
 
 import (
 	"fmt"
+	"github.com/jbgo/sftbot/data"
+	"github.com/jbgo/sftbot/plx"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"net/http/httptest"
-	"testing"
-
-	"github.com/jbgo/sftbot/plx"
 	"net/http"
+	"net/http/httptest"
 	"path/filepath"
 	"runtime"
+	"testing"
 )
 
 func credentialsPath() string {
@@ -64,8 +64,8 @@ func TestGetBalance(t *testing.T) {
 		balance, err := exchange.GetBalance("ABC")
 		require.Nil(t, err)
 		require.NotNil(t, balance)
-		assert.Equal(t, 0.42, balance.Available)
-		assert.Equal(t, 1.23, balance.OnOrders)
-		assert.Equal(t, 0.29, balance.BtcValue)
+		assert.Equal(t, data.NewDecimal(0.42), balance.Available)
+		assert.Equal(t, data.NewDecimal(1.23), balance.OnOrders)
+		assert.Equal(t, data.NewDecimal(0.29), balance.BtcValue)
 	})
 }
@@ -2,9 +2,10 @@ package trading
 
 import (
 	"fmt"
+	"github.com/jbgo/sftbot/data"
 	"github.com/jbgo/sftbot/plx"
 	"strconv"
-	"strings"
+	"time"
 )
 
 type PlxMarket struct {
@@ -25,27 +26,54 @@ func NewPlxMarket(marketName string, client *plx.Client) (*PlxMarket, error) {
 	return nil, fmt.Errorf("unknown market: %s", marketName)
 }
 
+// plxFlags maps order executions to the flags of Poloniex's buy and sell
+// commands.
+var plxFlags = map[string]string{
+	"":                        "",
+	ORDER_POST_ONLY:           plx.POST_ONLY,
+	ORDER_FILL_OR_KILL:        plx.FILL_OR_KILL,
+	ORDER_IMMEDIATE_OR_CANCEL: plx.IMMEDIATE_OR_CANCEL,
+}
+
 func (market *PlxMarket) Buy(order *Order) error {
-	plxOrder, err := market.Client.Buy(market.Name, order.Price, order.Amount)
+	flag, ok := plxFlags[order.Execution]
+	if !ok {
+		return fmt.Errorf("unknown order execution: %s", order.Execution)
+	}
+
+	plxOrder, err := market.Client.Buy(market.Name, order.Price, order.Amount, flag)
 
 	if err != nil {
 		return err
 	}
 
-	order.Id = strconv.FormatInt(plxOrder.OrderNumber, 10)
-
-	return nil
+	return placedPlxOrder(order, plxOrder)
 }
 
 func (market *PlxMarket) Sell(order *Order) error {
-	plxOrder, err := market.Client.Sell(market.Name, order.Price, order.Amount)
+	flag, ok := plxFlags[order.Execution]
+	if !ok {
+		return fmt.Errorf("unknown order execution: %s", order.Execution)
+	}
+
+	plxOrder, err := market.Client.Sell(market.Name, order.Price, order.Amount, flag)
 
 	if err != nil {
 		return err
 	}
 
+	return placedPlxOrder(order, plxOrder)
+}
+
+// placedPlxOrder sets the id of order. Poloniex settles immediate orders
+// before it responds, so one that did not fill at all is gone already.
+func placedPlxOrder(order *Order, plxOrder *plx.PlxOrder) error {
 	order.Id = strconv.FormatInt(plxOrder.OrderNumber, 10)
 
+	if order.Immediate() && len(plxOrder.ResultingTrades) == 0 {
+		return &OrderCancelledError{Order: order, Reason: "did not fill"}
+	}
+
 	return nil
 }
 
@@ -61,8 +89,12 @@ func (market *PlxMarket) Exists() bool {
 	return ok
 }
 
+func (market *PlxMarket) GetBaseCurrency() string {
+	return marketSymbol(market.Name).Base
+}
+
 func (market *PlxMarket) GetCurrency() string {
-	return strings.Split(market.Name, "_")[1]
+	return marketSymbol(market.Name).Currency
 }
 
 func (market *PlxMarket) GetCurrentPrice() (float64, error) {
@@ -75,10 +107,28 @@ func (market *PlxMarket) GetCurrentPrice() (float64, error) {
 	return ticker[market.Name].Last, nil
 }
 
+func (market *PlxMarket) GetInfo() (*MarketInfo, error) {
+	return NewPlxMarketInfo(), nil
+}
+
 func (market *PlxMarket) GetName() string {
 	return market.Name
 }
 
+func (market *PlxMarket) GetOrderTrades(order *Order) ([]*Trade, error) {
+	plxTrades, err := market.Client.OrderTrades(order.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	trades := make([]*Trade, 0, len(plxTrades))
+	for _, t := range plxTrades {
+		trades = append(trades, NewTradeFromPlx(market.Name, t))
+	}
+
+	return trades, nil
+}
+
 func (market *PlxMarket) GetPendingOrders() ([]*Order, error) {
 	plxOrders, err := market.Client.GetOpenOrders(market.Name)
 	if err != nil {
@@ -105,7 +155,7 @@ func (market *PlxMarket) GetSummaryData(startTime, endTime int64) (summaryData [
 		CurrencyPair: market.Name,
 		Start:        startTime,
 		End:          endTime,
-		Period:       300,
+		Period:       data.BASE_RESOLUTION,
 	}
 
 	chartData, err := market.Client.GetChartData(params)
@@ -122,3 +172,31 @@ func (market *PlxMarket) GetSummaryData(startTime, endTime int64) (summaryData [
 
 	return summaryData, nil
 }
+
+// Poloniex reports the fee as a rate, so convert it to BTC to match Trade.Fee.
+// The fee of a buy is taken from the currency bought, so Amount is what was
+// received and Total + Fee is still the BTC that was paid.
+func NewTradeFromPlx(marketName string, t *plx.PlxPrivateTrade) *Trade {
+	date, _ := time.Parse("2006-01-02 15:04:05", t.Date)
+
+	amount, total, fee := t.Amount, t.Total, t.Total.MulFloat(t.Fee)
+
+	if t.Type == "buy" {
+		amount -= t.Amount.MulFloat(t.Fee)
+		total -= fee
+	}
+
+	return &Trade{
+		Id:       strconv.FormatInt(t.GlobalTradeId, 10),
+		OrderId:  t.OrderNumber,
+		Market:   marketName,
+		Date:     date.Unix(),
+		Type:     t.Type,
+		Price:    t.Rate,
+		Amount:   amount,
+		Total:    total,
+		Fee:      fee,
+		Exchange: EXCHANGE_POLONIEX,
+		Metadata: t,
+	}
+}
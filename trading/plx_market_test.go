@@ -2,13 +2,14 @@ package trading
 
 import (
 	"fmt"
+	"github.com/jbgo/sftbot/data"
+	"github.com/jbgo/sftbot/plx"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
-
-	"github.com/jbgo/sftbot/plx"
-	"net/http"
 )
 
 type TestPlxApiRequest struct {
@@ -78,6 +79,7 @@ func TestMarket(t *testing.T) {
 
 	t.Run("GetCurrency", func(t *testing.T) {
 		assert.Equal(t, "ABC", market.GetCurrency())
+		assert.Equal(t, "BTC", market.GetBaseCurrency())
 	})
 
 	t.Run("GetCurrentPrice", func(t *testing.T) {
@@ -94,17 +96,17 @@ func TestMarket(t *testing.T) {
 		o1 := orders[0]
 		assert.Equal(t, "123", o1.Id)
 		assert.Equal(t, "buy", o1.Type)
-		assert.Equal(t, 4.56, o1.Price)
-		assert.Equal(t, 12.0, o1.Amount)
-		assert.Equal(t, 22.2, o1.Total)
+		assert.Equal(t, data.NewDecimal(4.56), o1.Price)
+		assert.Equal(t, data.NewDecimal(12.0), o1.Amount)
+		assert.Equal(t, data.NewDecimal(22.2), o1.Total)
 		assert.Equal(t, false, o1.Filled)
 
 		o2 := orders[1]
 		assert.Equal(t, "321", o2.Id)
 		assert.Equal(t, "sell", o2.Type)
-		assert.Equal(t, 6.54, o2.Price)
-		assert.Equal(t, 0.12, o2.Amount)
-		assert.Equal(t, 0.0222, o2.Total)
+		assert.Equal(t, data.NewDecimal(6.54), o2.Price)
+		assert.Equal(t, data.NewDecimal(0.12), o2.Amount)
+		assert.Equal(t, data.NewDecimal(0.0222), o2.Total)
 		assert.Equal(t, false, o2.Filled)
 	})
 
@@ -138,3 +140,100 @@ func TestMarket(t *testing.T) {
 		assert.Equal(t, "654321", order.Id)
 	})
 }
+
+func TestPlxMarketOrderFlags(t *testing.T) {
+	var form url.Values
+	response := `{"orderNumber":"123456","resultingTrades":[]}`
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/public" {
+			fmt.Fprintln(w, `{"BTC_ABC":{"last":"0.015"}}`)
+			return
+		}
+
+		r.ParseForm()
+		form = r.PostForm
+		fmt.Fprintln(w, response)
+	}))
+
+	defer testServer.Close()
+
+	client := plx.NewClient(testServer.URL, credentialsPath())
+	market, err := NewPlxMarket("BTC_ABC", client)
+	require.Nil(t, err)
+
+	t.Run("sends the exact rate and amount with the flag", func(t *testing.T) {
+		order := &Order{
+			Price:     data.NewDecimal(0.1) + data.NewDecimal(0.2),
+			Amount:    data.NewDecimal(2),
+			Execution: ORDER_POST_ONLY,
+		}
+		require.Nil(t, market.Buy(order))
+
+		assert.Equal(t, "0.3", form.Get("rate"))
+		assert.Equal(t, "2", form.Get("amount"))
+		assert.Equal(t, "1", form.Get("postOnly"))
+		assert.Equal(t, "", form.Get("immediateOrCancel"))
+	})
+
+	t.Run("immediate orders that did not fill are cancelled", func(t *testing.T) {
+		order := &Order{Price: data.NewDecimal(0.3), Amount: data.NewDecimal(2), Execution: ORDER_IMMEDIATE_OR_CANCEL}
+		err := market.Sell(order)
+		require.IsType(t, &OrderCancelledError{}, err)
+		assert.Equal(t, "1", form.Get("immediateOrCancel"))
+		assert.Equal(t, "immediate_or_cancel order did not fill", err.Error())
+		assert.Equal(t, "123456", order.Id)
+	})
+
+	t.Run("rejected orders are an error", func(t *testing.T) {
+		response = `{"error":"Unable to fill order completely."}`
+
+		err := market.Buy(&Order{Price: data.NewDecimal(0.3), Amount: data.NewDecimal(2), Execution: ORDER_FILL_OR_KILL})
+		require.NotNil(t, err)
+		assert.Equal(t, "1", form.Get("fillOrKill"))
+		assert.Contains(t, err.Error(), "Unable to fill order completely.")
+	})
+
+	t.Run("unknown executions are not sent", func(t *testing.T) {
+		form = nil
+
+		err := market.Buy(&Order{Price: data.NewDecimal(0.3), Amount: data.NewDecimal(2), Execution: "gtc"})
+		assert.NotNil(t, err)
+		assert.Nil(t, form)
+	})
+}
+
+func TestNewTradeFromPlx(t *testing.T) {
+	t.Run("buy fees are taken from the amount bought", func(t *testing.T) {
+		trade := NewTradeFromPlx("BTC_ABC", &plx.PlxPrivateTrade{
+			GlobalTradeId: 1,
+			Date:          "2017-06-01 12:00:00",
+			Rate:          data.NewDecimal(0.01),
+			Amount:        data.NewDecimal(100),
+			Total:         data.NewDecimal(1),
+			Fee:           0.0025,
+			Type:          "buy",
+		})
+
+		assert.Equal(t, "99.75", trade.Amount.String())
+		assert.Equal(t, "0.9975", trade.Total.String())
+		assert.Equal(t, "0.0025", trade.Fee.String())
+		assert.Equal(t, data.NewDecimal(1), trade.Total+trade.Fee)
+	})
+
+	t.Run("sell fees are taken from the proceeds", func(t *testing.T) {
+		trade := NewTradeFromPlx("BTC_ABC", &plx.PlxPrivateTrade{
+			GlobalTradeId: 2,
+			Date:          "2017-06-01 12:00:00",
+			Rate:          data.NewDecimal(0.01),
+			Amount:        data.NewDecimal(100),
+			Total:         data.NewDecimal(1),
+			Fee:           0.0025,
+			Type:          "sell",
+		})
+
+		assert.Equal(t, "100", trade.Amount.String())
+		assert.Equal(t, "1", trade.Total.String())
+		assert.Equal(t, "0.0025", trade.Fee.String())
+	})
+}
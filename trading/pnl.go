@@ -0,0 +1,232 @@
+package trading
+
+import (
+	"github.com/jbgo/sftbot/data"
+	"sort"
+)
+
+// Lot matching methods, i.e. which open lot a sell closes first.
+const LOT_METHOD_FIFO = "fifo"
+const LOT_METHOD_LIFO = "lifo"
+const LOT_METHOD_HIFO = "hifo"
+
+// An open position acquired by a single buy. Cost is the BTC paid for the
+// remaining Amount, including fees.
+type Lot struct {
+	Market  string
+	TradeId string
+	Date    int64
+	Price   data.Decimal
+	Amount  data.Decimal
+	Cost    data.Decimal
+}
+
+// The part of a sell that closed (part of) a single lot.
+type Disposal struct {
+	Market    string
+	TradeId   string
+	Acquired  int64
+	Disposed  int64
+	Amount    data.Decimal
+	Proceeds  data.Decimal
+	CostBasis data.Decimal
+}
+
+func (d *Disposal) Gain() data.Decimal {
+	return d.Proceeds - d.CostBasis
+}
+
+// LotBook matches sells against open lots using Method, which defaults to
+// first in, first out.
+type LotBook struct {
+	Market    string
+	Method    string
+	Lots      []*Lot
+	Disposals []*Disposal
+	// Amount sold that could not be matched to any recorded buy.
+	Unmatched data.Decimal
+}
+
+func NewLotBook(marketName string) *LotBook {
+	return &LotBook{Market: marketName, Method: LOT_METHOD_FIFO}
+}
+
+func ValidLotMethod(method string) bool {
+	return method == LOT_METHOD_FIFO || method == LOT_METHOD_LIFO || method == LOT_METHOD_HIFO
+}
+
+func (book *LotBook) Buy(trade *Trade) {
+	book.Lots = append(book.Lots, &Lot{
+		Market:  book.Market,
+		TradeId: trade.Id,
+		Date:    trade.Date,
+		Price:   trade.Price,
+		Amount:  trade.Amount,
+		Cost:    trade.Total + trade.Fee,
+	})
+}
+
+// Sell closes lots for the trade amount and returns the resulting disposals.
+func (book *LotBook) Sell(trade *Trade) []*Disposal {
+	disposals := make([]*Disposal, 0, 1)
+	remaining := trade.Amount
+	proceeds := trade.Total - trade.Fee
+
+	for remaining > 0 && len(book.Lots) > 0 {
+		i := book.nextLot()
+		lot := book.Lots[i]
+
+		// the last part of a lot, or of a sell, carries all of what is left
+		// of its cost or proceeds, so rounding never loses a satoshi
+		amount, cost := lot.Amount, lot.Cost
+		if remaining < lot.Amount {
+			amount = remaining
+			cost = lot.Cost.MulDiv(amount, lot.Amount)
+		}
+
+		part := proceeds
+		if amount < remaining {
+			part = proceeds.MulDiv(amount, remaining)
+		}
+
+		disposals = append(disposals, &Disposal{
+			Market:    book.Market,
+			TradeId:   trade.Id,
+			Acquired:  lot.Date,
+			Disposed:  trade.Date,
+			Amount:    amount,
+			Proceeds:  part,
+			CostBasis: cost,
+		})
+
+		lot.Amount -= amount
+		lot.Cost -= cost
+		remaining -= amount
+		proceeds -= part
+
+		if lot.Amount <= 0 {
+			book.Lots = append(book.Lots[:i], book.Lots[i+1:]...)
+		}
+	}
+
+	if remaining > 0 {
+		book.Unmatched += remaining
+	}
+
+	book.Disposals = append(book.Disposals, disposals...)
+
+	return disposals
+}
+
+// nextLot returns the index of the lot the next sell should close.
+func (book *LotBook) nextLot() int {
+	switch book.Method {
+	case LOT_METHOD_LIFO:
+		return len(book.Lots) - 1
+	case LOT_METHOD_HIFO:
+		highest := 0
+		for i, lot := range book.Lots {
+			if lot.Cost.Div(lot.Amount) > book.Lots[highest].Cost.Div(book.Lots[highest].Amount) {
+				highest = i
+			}
+		}
+		return highest
+	default:
+		return 0
+	}
+}
+
+func (book *LotBook) OpenAmount() (amount data.Decimal) {
+	for _, lot := range book.Lots {
+		amount += lot.Amount
+	}
+	return amount
+}
+
+func (book *LotBook) OpenCost() (cost data.Decimal) {
+	for _, lot := range book.Lots {
+		cost += lot.Cost
+	}
+	return cost
+}
+
+type PnL struct {
+	Market     string
+	Realised   float64
+	Unrealised float64
+	Fees       float64
+	Wins       int
+	Losses     int
+	OpenAmount float64
+	OpenCost   float64
+	Unmatched  float64
+}
+
+func (pnl *PnL) WinRate() float64 {
+	if pnl.Wins+pnl.Losses == 0 {
+		return 0.0
+	}
+
+	return float64(pnl.Wins) / float64(pnl.Wins+pnl.Losses)
+}
+
+// Add accumulates other into pnl, e.g. to compute a total across markets.
+func (pnl *PnL) Add(other *PnL) {
+	pnl.Realised += other.Realised
+	pnl.Unrealised += other.Unrealised
+	pnl.Fees += other.Fees
+	pnl.Wins += other.Wins
+	pnl.Losses += other.Losses
+	pnl.OpenCost += other.OpenCost
+	pnl.Unmatched += other.Unmatched
+}
+
+// CalculatePnL replays the trades of a single market and values the open lots
+// at currentPrice. A sell counts as a win when its total gain is positive.
+// Without a current price, e.g. for a delisted market, the open lots are not
+// valued and Unrealised stays zero.
+func CalculatePnL(marketName string, trades []*Trade, currentPrice float64) *PnL {
+	sorted := make([]*Trade, len(trades))
+	copy(sorted, trades)
+	sort.Stable(ByTradeDate(sorted))
+
+	book := NewLotBook(marketName)
+	pnl := &PnL{Market: marketName}
+
+	for _, trade := range sorted {
+		pnl.Fees += trade.Fee.Float64()
+
+		if trade.Type == "buy" {
+			book.Buy(trade)
+			continue
+		}
+
+		disposals := book.Sell(trade)
+		if len(disposals) == 0 {
+			continue
+		}
+
+		gain := data.Decimal(0)
+		for _, d := range disposals {
+			gain += d.Gain()
+		}
+
+		pnl.Realised += gain.Float64()
+
+		if gain > 0 {
+			pnl.Wins += 1
+		} else {
+			pnl.Losses += 1
+		}
+	}
+
+	pnl.OpenAmount = book.OpenAmount().Float64()
+	pnl.OpenCost = book.OpenCost().Float64()
+	if currentPrice > 0 {
+		pnl.Unrealised = pnl.OpenAmount*currentPrice - pnl.OpenCost
+	}
+
+	pnl.Unmatched = book.Unmatched.Float64()
+
+	return pnl
+}
@@ -0,0 +1,115 @@
+package trading
+
+import (
+	"github.com/jbgo/sftbot/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCalculatePnL(t *testing.T) {
+	trades := []*Trade{
+		&Trade{Id: "3", Date: 300, Type: "sell", Price: data.NewDecimal(0.03), Amount: data.NewDecimal(15.0), Total: data.NewDecimal(0.45), Fee: data.NewDecimal(0.01)},
+		&Trade{Id: "1", Date: 100, Type: "buy", Price: data.NewDecimal(0.01), Amount: data.NewDecimal(10.0), Total: data.NewDecimal(0.1), Fee: data.NewDecimal(0.001)},
+		&Trade{Id: "2", Date: 200, Type: "buy", Price: data.NewDecimal(0.02), Amount: data.NewDecimal(10.0), Total: data.NewDecimal(0.2), Fee: data.NewDecimal(0.002)},
+		&Trade{Id: "4", Date: 400, Type: "sell", Price: data.NewDecimal(0.005), Amount: data.NewDecimal(2.0), Total: data.NewDecimal(0.01), Fee: data.NewDecimal(0.0)},
+	}
+
+	pnl := CalculatePnL("BTC_ABC", trades, 0.04)
+
+	// sell #3 closes lot #1 and half of lot #2: 0.44 - (0.101 + 0.101)
+	// sell #4 closes 2 of lot #2 at a loss: 0.01 - 0.0404
+	assert.InDelta(t, 0.238-0.0304, pnl.Realised, 0.0000001)
+	assert.InDelta(t, 0.013, pnl.Fees, 0.0000001)
+	assert.InDelta(t, 3.0, pnl.OpenAmount, 0.0000001)
+	assert.InDelta(t, 0.0606, pnl.OpenCost, 0.0000001)
+	assert.InDelta(t, 0.12-0.0606, pnl.Unrealised, 0.0000001)
+	assert.Equal(t, 1, pnl.Wins)
+	assert.Equal(t, 1, pnl.Losses)
+	assert.Equal(t, 0.5, pnl.WinRate())
+	assert.Equal(t, 0.0, pnl.Unmatched)
+
+	t.Run("without a current price", func(t *testing.T) {
+		pnl := CalculatePnL("BTC_ABC", trades, 0.0)
+
+		assert.InDelta(t, 3.0, pnl.OpenAmount, 0.0000001)
+		assert.Equal(t, 0.0, pnl.Unrealised)
+	})
+}
+
+func TestPnLAdd(t *testing.T) {
+	total := &PnL{Market: "TOTAL"}
+
+	total.Add(&PnL{Realised: 0.1, Unrealised: 0.2, Fees: 0.01, Wins: 1, OpenCost: 0.5, Unmatched: 2.0})
+	total.Add(&PnL{Realised: -0.05, Fees: 0.02, Losses: 1, OpenCost: 0.25, Unmatched: 1.0})
+
+	assert.InDelta(t, 0.05, total.Realised, 0.0000001)
+	assert.InDelta(t, 0.2, total.Unrealised, 0.0000001)
+	assert.InDelta(t, 0.03, total.Fees, 0.0000001)
+	assert.Equal(t, 1, total.Wins)
+	assert.Equal(t, 1, total.Losses)
+	assert.InDelta(t, 0.75, total.OpenCost, 0.0000001)
+	assert.InDelta(t, 3.0, total.Unmatched, 0.0000001)
+}
+
+func TestLotBook(t *testing.T) {
+	book := NewLotBook("BTC_ABC")
+
+	t.Run("sell without lots", func(t *testing.T) {
+		disposals := book.Sell(&Trade{Id: "1", Type: "sell", Amount: data.NewDecimal(5.0), Total: data.NewDecimal(1.0)})
+		assert.Equal(t, 0, len(disposals))
+		assert.Equal(t, data.NewDecimal(5.0), book.Unmatched)
+	})
+
+	t.Run("sell across lots", func(t *testing.T) {
+		book.Buy(&Trade{Id: "2", Date: 10, Amount: data.NewDecimal(1.0), Total: data.NewDecimal(1.0)})
+		book.Buy(&Trade{Id: "3", Date: 20, Amount: data.NewDecimal(1.0), Total: data.NewDecimal(2.0)})
+
+		disposals := book.Sell(&Trade{Id: "4", Date: 30, Amount: data.NewDecimal(1.5), Total: data.NewDecimal(3.0)})
+		require.Equal(t, 2, len(disposals))
+
+		assert.Equal(t, int64(10), disposals[0].Acquired)
+		assert.Equal(t, data.NewDecimal(1.0), disposals[0].Amount)
+		assert.Equal(t, data.NewDecimal(2.0), disposals[0].Proceeds)
+		assert.Equal(t, data.NewDecimal(1.0), disposals[0].Gain())
+
+		assert.Equal(t, int64(20), disposals[1].Acquired)
+		assert.Equal(t, data.NewDecimal(0.5), disposals[1].Amount)
+		assert.Equal(t, data.NewDecimal(1.0), disposals[1].CostBasis)
+
+		assert.Equal(t, 1, len(book.Lots))
+		assert.Equal(t, data.NewDecimal(0.5), book.OpenAmount())
+		assert.Equal(t, data.NewDecimal(1.0), book.OpenCost())
+	})
+}
+
+func TestLotBookMethods(t *testing.T) {
+	buys := []*Trade{
+		&Trade{Id: "1", Date: 10, Amount: data.NewDecimal(1.0), Total: data.NewDecimal(1.0)},
+		&Trade{Id: "2", Date: 20, Amount: data.NewDecimal(1.0), Total: data.NewDecimal(3.0)},
+		&Trade{Id: "3", Date: 30, Amount: data.NewDecimal(1.0), Total: data.NewDecimal(2.0)},
+	}
+
+	expected := map[string]int64{
+		LOT_METHOD_FIFO: 10,
+		LOT_METHOD_LIFO: 30,
+		LOT_METHOD_HIFO: 20,
+	}
+
+	for method, acquired := range expected {
+		book := NewLotBook("BTC_ABC")
+		book.Method = method
+
+		for _, trade := range buys {
+			book.Buy(trade)
+		}
+
+		disposals := book.Sell(&Trade{Id: "4", Date: 40, Amount: data.NewDecimal(1.0), Total: data.NewDecimal(2.5)})
+		require.Equal(t, 1, len(disposals), method)
+		assert.Equal(t, acquired, disposals[0].Acquired, method)
+		assert.Equal(t, 2, len(book.Lots), method)
+	}
+
+	assert.True(t, ValidLotMethod("hifo"))
+	assert.False(t, ValidLotMethod("random"))
+}
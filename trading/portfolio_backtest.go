@@ -0,0 +1,297 @@
+package trading
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jbgo/sftbot/db"
+	"io"
+	"io/ioutil"
+	"log"
+	"sort"
+	"time"
+)
+
+// Portfolio backtests trade BTC markets from a single BTC wallet, like plx
+// trade.
+const PORTFOLIO_BASE_CURRENCY = "BTC"
+
+/**
+ * PortfolioBacktest
+ *
+ * Replays every market on one clock and trades them from one wallet the way
+ * plx trade does: at each step, the markets that pass ShouldTradeMarket with
+ * their volume over the previous 24 hours of candles are traded in order of
+ * that volume, largest first. A market that drops out of the selection keeps
+ * its position and open orders until it is selected again.
+ */
+
+type PortfolioBacktest struct {
+	Config *TraderConfig
+	// Candles of each market, sorted by date. They should start a
+	// Config.TimeWindow (and at least 24 hours) before StartTime, so both the
+	// trader's window and the volume filter are warmed up.
+	Markets         map[string][]*SummaryData
+	Resolution      int64
+	StartTime       int64
+	EndTime         int64
+	StartingBalance float64
+	Fees            FeeSchedule
+	// Defaults to ImmediateFill.
+	FillModel FillModel
+	// Trader errors are logged here and the backtest carries on. Defaults to
+	// discarding them.
+	Logger *log.Logger
+	// When set, every order the traders place is journaled here.
+	Journal *Journal
+}
+
+// PortfolioResult has the metrics of the whole portfolio, where the equity
+// curve's Alt is the value of every alt position in BTC and its Price is 1.
+// BuyAndHoldReturn is for an equal weight basket of the markets selected at
+// the first step.
+type PortfolioResult struct {
+	*BacktestResult
+	Markets []*PortfolioMarket
+}
+
+type PortfolioMarket struct {
+	Market string
+	// Steps in which the market was selected and traded.
+	Steps      int
+	Trades     int
+	Realised   float64
+	Unrealised float64
+	Fees       float64
+	WinRate    float64
+}
+
+func (bt *PortfolioBacktest) Run() (*PortfolioResult, error) {
+	if bt.Resolution <= 0 {
+		return nil, fmt.Errorf("invalid resolution: %d", bt.Resolution)
+	}
+
+	err := bt.Config.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	config := *bt.Config
+	config.Simulate = false
+
+	exchange := NewSimulatedExchange(map[string]float64{PORTFOLIO_BASE_CURRENCY: bt.StartingBalance})
+	exchange.Fees = bt.Fees
+	if bt.FillModel != nil {
+		exchange.FillModel = bt.FillModel
+	}
+
+	names := make([]string, 0, len(bt.Markets))
+	for name, candles := range bt.Markets {
+		exchange.AddMarket(name, candles, config.TimeWindow)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	logger := bt.Logger
+	if logger == nil {
+		logger = log.New(ioutil.Discard, "", 0)
+	}
+
+	dbStore := db.NewMemoryStore()
+	traders := make(map[string]*Trader)
+	steps := make(map[string]int)
+	equity := make([]*EquityPoint, 0)
+	errors := 0
+
+	var firstSelection []string
+	var firstPrices map[string]float64
+
+	for _, date := range bt.dates() {
+		exchange.Advance(date)
+
+		selected := bt.selectMarkets(exchange, names)
+
+		if firstSelection == nil {
+			firstSelection = selected
+			firstPrices = currentPrices(exchange)
+		}
+
+		for _, name := range selected {
+			trader, ok := traders[name]
+			if !ok {
+				trader, err = NewTrader(name, exchange, dbStore, &config)
+				if err != nil {
+					return nil, err
+				}
+
+				trader.Clock = func() time.Time { return time.Unix(exchange.Now, 0) }
+				trader.Logger = log.New(ioutil.Discard, "", 0)
+				trader.Journal = bt.Journal
+				traders[name] = trader
+			}
+
+			steps[name] += 1
+
+			err = trader.Trade()
+			if err != nil {
+				errors += 1
+				logger.Printf("market=%s evt=error date=%d msg=\"%s\"\n", name, date, err.Error())
+			}
+		}
+
+		equity = append(equity, portfolioEquity(exchange, date))
+	}
+
+	if len(equity) == 0 {
+		return nil, fmt.Errorf("no chart data between %d and %d", bt.StartTime, bt.EndTime)
+	}
+
+	result := &PortfolioResult{
+		BacktestResult: NewBacktestResult("portfolio", bt.Resolution, bt.StartingBalance, equity, exchange.Trades),
+		Markets:        make([]*PortfolioMarket, 0),
+	}
+
+	result.Errors = errors
+	result.BuyAndHoldReturn = basketReturn(firstSelection, firstPrices, currentPrices(exchange))
+
+	fills := fillsByMarket(exchange.Trades)
+
+	for _, name := range names {
+		if steps[name] == 0 && len(fills[name]) == 0 {
+			continue
+		}
+
+		price, _ := exchange.Markets[name].GetCurrentPrice()
+		pnl := CalculatePnL(name, fills[name], price)
+
+		result.Markets = append(result.Markets, &PortfolioMarket{
+			Market:     name,
+			Steps:      steps[name],
+			Trades:     countOrders(fills[name]),
+			Realised:   pnl.Realised,
+			Unrealised: pnl.Unrealised,
+			Fees:       pnl.Fees,
+			WinRate:    pnl.WinRate(),
+		})
+	}
+
+	return result, nil
+}
+
+// dates returns the date of every candle of any market between the start and
+// end times, in order.
+func (bt *PortfolioBacktest) dates() []int64 {
+	seen := make(map[int64]bool)
+	dates := make([]int64, 0)
+
+	for _, candles := range bt.Markets {
+		for _, candle := range candles {
+			if candle.Date < bt.StartTime || candle.Date > bt.EndTime || seen[candle.Date] {
+				continue
+			}
+
+			seen[candle.Date] = true
+			dates = append(dates, candle.Date)
+		}
+	}
+
+	sort.Sort(int64s(dates))
+
+	return dates
+}
+
+// selectMarkets returns the markets plx trade would trade, in the order it
+// would trade them.
+func (bt *PortfolioBacktest) selectMarkets(exchange *SimulatedExchange, names []string) []string {
+	selected := make([]string, 0)
+
+	for _, name := range names {
+		if ShouldTradeMarket(name, exchange.Markets[name].BaseVolume()) {
+			selected = append(selected, name)
+		}
+	}
+
+	sort.SliceStable(selected, func(i, j int) bool {
+		return exchange.Markets[selected[i]].BaseVolume() > exchange.Markets[selected[j]].BaseVolume()
+	})
+
+	return selected
+}
+
+func portfolioEquity(exchange *SimulatedExchange, date int64) *EquityPoint {
+	base := exchange.balance(PORTFOLIO_BASE_CURRENCY)
+	point := &EquityPoint{Date: date, Price: 1.0, Base: (base.Available + base.OnOrders).Float64()}
+
+	for _, market := range exchange.Markets {
+		alt := exchange.balance(market.GetCurrency())
+		current := market.Current()
+
+		if current != nil {
+			point.Alt += (alt.Available + alt.OnOrders).Float64() * current.WeightedAverage
+		}
+	}
+
+	point.NAV = point.Base + point.Alt
+
+	return point
+}
+
+func currentPrices(exchange *SimulatedExchange) map[string]float64 {
+	prices := make(map[string]float64)
+
+	for name, market := range exchange.Markets {
+		if current := market.Current(); current != nil {
+			prices[name] = current.WeightedAverage
+		}
+	}
+
+	return prices
+}
+
+func basketReturn(names []string, startPrices, endPrices map[string]float64) float64 {
+	total := 0.0
+
+	for _, name := range names {
+		total += endPrices[name]/startPrices[name] - 1
+	}
+
+	if len(names) == 0 {
+		return 0.0
+	}
+
+	return total / float64(len(names))
+}
+
+type int64s []int64
+
+func (a int64s) Len() int           { return len(a) }
+func (a int64s) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a int64s) Less(i, j int) bool { return a[i] < a[j] }
+
+func (result *PortfolioResult) WriteTable(w io.Writer) {
+	result.BacktestResult.WriteTable(w)
+
+	fmt.Fprintln(w)
+
+	for _, m := range result.Markets {
+		fmt.Fprintf(w, "%-12s steps=%-6d trades=%-5d realised=%0.9f    unrealised=%0.9f    fees=%0.9f    win_rate=%0.2f%%\n",
+			m.Market,
+			m.Steps,
+			m.Trades,
+			m.Realised,
+			m.Unrealised,
+			m.Fees,
+			m.WinRate*100)
+	}
+}
+
+// WriteJSON writes the metrics and the results of each market, without the
+// equity curve and fills.
+func (result *PortfolioResult) WriteJSON(w io.Writer) error {
+	encoded, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(encoded, '\n'))
+	return err
+}
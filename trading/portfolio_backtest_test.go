@@ -0,0 +1,69 @@
+package trading
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func scaleVolume(candles []*SummaryData, factor float64) []*SummaryData {
+	for _, candle := range candles {
+		candle.Volume *= factor
+	}
+
+	return candles
+}
+
+func TestPortfolioBacktest(t *testing.T) {
+	// about 2880 BTC a day, 288 BTC a day and 2880 USDT a day
+	markets := map[string][]*SummaryData{
+		"BTC_ABC":  scaleVolume(oscillatingCandles(0, 288*3), 10),
+		"BTC_DEF":  oscillatingCandles(0, 288*3),
+		"USDT_XYZ": scaleVolume(oscillatingCandles(0, 288*3), 10),
+	}
+
+	bt := &PortfolioBacktest{
+		Config:          DefaultTraderConfig(),
+		Markets:         markets,
+		Resolution:      300,
+		StartTime:       86400,
+		EndTime:         3*86400 - 300,
+		StartingBalance: 1.0,
+		Fees:            FeeSchedule{Maker: 0.0015, Taker: 0.0025},
+	}
+
+	result, err := bt.Run()
+	require.Nil(t, err)
+
+	assert.Equal(t, 576, result.Steps)
+	assert.Equal(t, 1.0, result.StartingNAV)
+	assert.True(t, result.Trades > 0)
+
+	require.Equal(t, 1, len(result.Markets))
+	assert.Equal(t, "BTC_ABC", result.Markets[0].Market)
+	assert.Equal(t, 576, result.Markets[0].Steps)
+	assert.Equal(t, result.Trades, result.Markets[0].Trades)
+
+	// the equity curve values the alt position at the market price
+	last := result.Equity[len(result.Equity)-1]
+	assert.Equal(t, 1.0, last.Price)
+	assert.InDelta(t, last.Base+last.Alt, last.NAV, 1e-12)
+
+	// only BTC_ABC was selected at the first step
+	abc := markets["BTC_ABC"]
+	assert.InDelta(t, abc[len(abc)-1].WeightedAverage/abc[288].WeightedAverage-1, result.BuyAndHoldReturn, 1e-12)
+
+	var buffer bytes.Buffer
+	result.WriteTable(&buffer)
+	assert.Contains(t, buffer.String(), "BTC_ABC")
+
+	t.Run("no chart data", func(t *testing.T) {
+		empty := *bt
+		empty.StartTime = 10 * 86400
+		empty.EndTime = 11 * 86400
+
+		_, err := empty.Run()
+		assert.NotNil(t, err)
+	})
+}
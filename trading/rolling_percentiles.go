@@ -0,0 +1,204 @@
+package trading
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Enough levels for windows of about a million values.
+const SKIPLIST_MAX_LEVEL = 20
+
+// RollingPercentiles keeps the values of a sliding time window in order, so
+// percentiles can be read after every new candle without sorting the window
+// again. Adding a value and evicting an old one are O(log n), amortized.
+type RollingPercentiles struct {
+	// Length of the window in seconds. Values dated before the newest date
+	// minus Window are evicted. Zero keeps every value.
+	Window int64
+	values *orderStatistics
+	queue  []datedValue
+	// index in queue of the oldest value still in the window
+	head int
+}
+
+type datedValue struct {
+	Date  int64
+	Value float64
+}
+
+func NewRollingPercentiles(window int64) *RollingPercentiles {
+	return &RollingPercentiles{Window: window, values: newOrderStatistics()}
+}
+
+// Add inserts a value, which must not be older than the values added before
+// it, and evicts the values that fell out of the window.
+func (rp *RollingPercentiles) Add(date int64, value float64) {
+	rp.values.insert(value)
+
+	if rp.Window <= 0 {
+		return
+	}
+
+	rp.queue = append(rp.queue, datedValue{date, value})
+
+	for rp.head < len(rp.queue) && rp.queue[rp.head].Date < date-rp.Window {
+		rp.values.remove(rp.queue[rp.head].Value)
+		rp.head += 1
+	}
+
+	// compacting only once half of the queue is evicted keeps the copying
+	// amortized to O(1) per value
+	if rp.head > len(rp.queue)/2 {
+		rp.queue = append(rp.queue[:0], rp.queue[rp.head:]...)
+		rp.head = 0
+	}
+}
+
+func (rp *RollingPercentiles) Len() int {
+	return rp.values.size
+}
+
+// Percentile returns the value below which p percent of the window falls,
+// using the same rank as calculatePercentiles: the (p*n/100)th smallest.
+func (rp *RollingPercentiles) Percentile(p int64) float64 {
+	if rp.values.size == 0 {
+		return 0.0
+	}
+
+	rank := int(p) * rp.values.size / 100
+	if rank >= rp.values.size {
+		rank = rp.values.size - 1
+	}
+
+	return rp.values.at(rank)
+}
+
+// Percentiles returns all percentiles from 0 to 100. The 0th percentile is
+// always zero and the 100th is the largest value.
+func (rp *RollingPercentiles) Percentiles() []float64 {
+	percentiles := make([]float64, 101)
+
+	for i := int64(1); i <= 100; i += 1 {
+		percentiles[i] = rp.Percentile(i)
+	}
+
+	return percentiles
+}
+
+// orderStatistics is an indexable skiplist: every link also stores how many
+// values it skips, so the value at any rank can be found in O(log n).
+type orderStatistics struct {
+	head *skipNode
+	size int
+	rng  *rand.Rand
+}
+
+type skipNode struct {
+	value float64
+	next  []*skipNode
+	width []int
+}
+
+func newOrderStatistics() *orderStatistics {
+	head := &skipNode{
+		next:  make([]*skipNode, SKIPLIST_MAX_LEVEL),
+		width: make([]int, SKIPLIST_MAX_LEVEL),
+	}
+
+	for level := range head.width {
+		head.width[level] = 1
+	}
+
+	// a fixed seed keeps backtests reproducible
+	return &orderStatistics{head: head, rng: rand.New(rand.NewSource(1))}
+}
+
+func (s *orderStatistics) randomLevel() int {
+	level := 1 - int(math.Log2(1.0-s.rng.Float64()))
+
+	if level > SKIPLIST_MAX_LEVEL {
+		return SKIPLIST_MAX_LEVEL
+	}
+
+	return level
+}
+
+func (s *orderStatistics) insert(value float64) {
+	var chain [SKIPLIST_MAX_LEVEL]*skipNode
+	var steps [SKIPLIST_MAX_LEVEL]int
+
+	node := s.head
+
+	for level := SKIPLIST_MAX_LEVEL - 1; level >= 0; level -= 1 {
+		for node.next[level] != nil && node.next[level].value <= value {
+			steps[level] += node.width[level]
+			node = node.next[level]
+		}
+		chain[level] = node
+	}
+
+	levels := s.randomLevel()
+	inserted := &skipNode{value: value, next: make([]*skipNode, levels), width: make([]int, levels)}
+	distance := 0
+
+	for level := 0; level < levels; level += 1 {
+		previous := chain[level]
+		inserted.next[level] = previous.next[level]
+		previous.next[level] = inserted
+		inserted.width[level] = previous.width[level] - distance
+		previous.width[level] = distance + 1
+		distance += steps[level]
+	}
+
+	for level := levels; level < SKIPLIST_MAX_LEVEL; level += 1 {
+		chain[level].width[level] += 1
+	}
+
+	s.size += 1
+}
+
+// remove deletes one occurrence of value, if there is one.
+func (s *orderStatistics) remove(value float64) {
+	var chain [SKIPLIST_MAX_LEVEL]*skipNode
+
+	node := s.head
+
+	for level := SKIPLIST_MAX_LEVEL - 1; level >= 0; level -= 1 {
+		for node.next[level] != nil && node.next[level].value < value {
+			node = node.next[level]
+		}
+		chain[level] = node
+	}
+
+	removed := chain[0].next[0]
+	if removed == nil || removed.value != value {
+		return
+	}
+
+	for level := 0; level < len(removed.next); level += 1 {
+		previous := chain[level]
+		previous.width[level] += removed.width[level] - 1
+		previous.next[level] = removed.next[level]
+	}
+
+	for level := len(removed.next); level < SKIPLIST_MAX_LEVEL; level += 1 {
+		chain[level].width[level] -= 1
+	}
+
+	s.size -= 1
+}
+
+// at returns the value of the given rank, starting from zero for the smallest.
+func (s *orderStatistics) at(rank int) float64 {
+	node := s.head
+	remaining := rank + 1
+
+	for level := SKIPLIST_MAX_LEVEL - 1; level >= 0; level -= 1 {
+		for node.next[level] != nil && node.width[level] <= remaining {
+			remaining -= node.width[level]
+			node = node.next[level]
+		}
+	}
+
+	return node.value
+}
@@ -0,0 +1,92 @@
+package trading
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestRollingPercentiles(t *testing.T) {
+	t.Run("matches sorting the window", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(42))
+		window := int64(24 * 300)
+		rp := NewRollingPercentiles(window)
+		history := make([]*SummaryData, 0)
+
+		for i := 0; i < 500; i += 1 {
+			date := int64(i * 300)
+			// coarse prices so the window holds many duplicates
+			price := float64(rng.Intn(20)) / 1000.0
+
+			rp.Add(date, price)
+			history = append(history, &SummaryData{Date: date, WeightedAverage: price})
+
+			inWindow := make([]float64, 0)
+			for _, d := range history {
+				if d.Date >= date-window {
+					inWindow = append(inWindow, d.WeightedAverage)
+				}
+			}
+			sort.Float64s(inWindow)
+
+			assert.Equal(t, len(inWindow), rp.Len())
+			assert.True(t, len(rp.queue) <= 2*rp.Len(), "evicted values are compacted")
+
+			for _, p := range []int64{1, 10, 45, 50, 55, 99} {
+				assert.Equal(t, inWindow[int(p)*len(inWindow)/100], rp.Percentile(p))
+			}
+
+			assert.Equal(t, inWindow[len(inWindow)-1], rp.Percentile(100))
+		}
+	})
+
+	t.Run("a gap evicts the whole window", func(t *testing.T) {
+		rp := NewRollingPercentiles(600)
+		rp.Add(0, 1.0)
+		rp.Add(300, 2.0)
+		rp.Add(600, 3.0)
+		rp.Add(3000, 4.0)
+
+		assert.Equal(t, 1, rp.Len())
+		assert.Equal(t, 4.0, rp.Percentile(50))
+		assert.Equal(t, 1, len(rp.queue))
+	})
+
+	t.Run("calculatePercentiles", func(t *testing.T) {
+		summaryData := []*SummaryData{
+			&SummaryData{WeightedAverage: 3.0},
+			&SummaryData{WeightedAverage: 1.0},
+			&SummaryData{WeightedAverage: 2.0},
+			&SummaryData{WeightedAverage: 4.0},
+		}
+
+		percentiles, err := calculatePercentiles(summaryData)
+		assert.Nil(t, err)
+		assert.Equal(t, 101, len(percentiles))
+		assert.Equal(t, 0.0, percentiles[0])
+		assert.Equal(t, 1.0, percentiles[1])
+		assert.Equal(t, 2.0, percentiles[25])
+		assert.Equal(t, 3.0, percentiles[50])
+		assert.Equal(t, 4.0, percentiles[99])
+		assert.Equal(t, 4.0, percentiles[100])
+	})
+
+	t.Run("empty window", func(t *testing.T) {
+		assert.Equal(t, 0.0, NewRollingPercentiles(300).Percentile(50))
+
+		_, err := calculatePercentiles([]*SummaryData{})
+		assert.NotNil(t, err)
+	})
+}
+
+func BenchmarkRollingPercentiles(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	rp := NewRollingPercentiles(24 * 60 * 60)
+
+	for i := 0; i < b.N; i += 1 {
+		rp.Add(int64(i*300), rng.Float64())
+		rp.Percentile(45)
+		rp.Percentile(55)
+	}
+}
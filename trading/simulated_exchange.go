@@ -1 +1,404 @@
 package trading
+
+import (
+	"fmt"
+	"github.com/jbgo/sftbot/data"
+	"sort"
+)
+
+/**
+ * SimulatedExchange
+ *
+ * Replays stored candles for backtests. The clock only moves when Advance is
+ * called, and every market sees the candles up to the current time. Orders
+ * are kept on a simulated order book and FillModel decides when they fill,
+ * as each new candle opens. Funds for an order are held on orders until it
+ * fills, and fees are charged in the base currency like on Poloniex.
+ */
+
+type SimulatedExchange struct {
+	Balances map[string]*Balance
+	Markets  map[string]*SimulatedMarket
+	Fees     FeeSchedule
+	// Defaults to ImmediateFill.
+	FillModel FillModel
+	// Every fill, in the order it happened.
+	Trades []*Trade
+	Now    int64
+
+	orderTrades map[string][]*Trade
+	nextOrderId int64
+}
+
+func NewSimulatedExchange(balances map[string]float64) *SimulatedExchange {
+	exchange := &SimulatedExchange{
+		Balances:    make(map[string]*Balance),
+		Markets:     make(map[string]*SimulatedMarket),
+		Fees:        FeeSchedule{Maker: SIMULATED_MAKER_FEE, Taker: SIMULATED_TAKER_FEE},
+		FillModel:   &ImmediateFill{},
+		orderTrades: make(map[string][]*Trade),
+	}
+
+	for currency, amount := range balances {
+		exchange.Balances[currency] = &Balance{Available: data.NewDecimal(amount)}
+	}
+
+	return exchange
+}
+
+// AddMarket makes candles, sorted by date, available as a market. window is
+// the length in seconds of the trailing window whose percentiles and
+// indicators the market keeps up to date, which should be the TimeWindow of
+// the traders using it.
+func (exchange *SimulatedExchange) AddMarket(marketName string, candles []*SummaryData, window int64) *SimulatedMarket {
+	market := &SimulatedMarket{
+		Name:        marketName,
+		Exchange:    exchange,
+		Candles:     candles,
+		Window:      window,
+		current:     -1,
+		percentiles: NewRollingPercentiles(window),
+		indicators:  NewIndicatorSet(),
+	}
+
+	exchange.Markets[marketName] = market
+
+	return market
+}
+
+func (exchange *SimulatedExchange) GetMarket(marketName string) (Market, error) {
+	market, ok := exchange.Markets[marketName]
+	if !ok {
+		return nil, fmt.Errorf("no chart data for market: %s", marketName)
+	}
+
+	return market, nil
+}
+
+func (exchange *SimulatedExchange) GetBalance(currency string) (*Balance, error) {
+	balance := exchange.balance(currency)
+	copy := *balance
+	return &copy, nil
+}
+
+// GetTicker reports the markets that have a current candle, priced at its
+// weighted average.
+func (exchange *SimulatedExchange) GetTicker() (map[string]*TickerEntry, error) {
+	ticker := make(map[string]*TickerEntry)
+
+	for name, market := range exchange.Markets {
+		current := market.Current()
+		if current == nil {
+			continue
+		}
+
+		price := current.WeightedAverage
+		ticker[name] = &TickerEntry{
+			Market:     name,
+			Last:       price,
+			LowestAsk:  price,
+			HighestBid: price,
+			BaseVolume: market.BaseVolume(),
+		}
+	}
+
+	return ticker, nil
+}
+
+func (exchange *SimulatedExchange) balance(currency string) *Balance {
+	balance, ok := exchange.Balances[currency]
+	if !ok {
+		balance = &Balance{}
+		exchange.Balances[currency] = balance
+	}
+
+	return balance
+}
+
+// Advance moves the clock to date and feeds every market the candles that
+// opened since the last call, filling orders as it goes.
+func (exchange *SimulatedExchange) Advance(date int64) {
+	exchange.Now = date
+
+	for _, market := range exchange.Markets {
+		market.advance(date)
+	}
+}
+
+func (exchange *SimulatedExchange) placeOrder(market *SimulatedMarket, order *Order) error {
+	current := market.Current()
+	if current == nil {
+		return fmt.Errorf("%s has no chart data before %d", market.Name, exchange.Now)
+	}
+
+	// reject what Poloniex would reject
+	info, _ := market.GetInfo()
+	err := info.Validate(order)
+	if err != nil {
+		return fmt.Errorf("invalid order: %s", err.Error())
+	}
+
+	order.Total = order.Price.Mul(order.Amount)
+	simulated := &SimulatedOrder{Order: order, Market: market, Remaining: order.Amount}
+
+	// hold enough for the order to fill at its price as a taker
+	if order.Type == "buy" {
+		simulated.Reserved = order.Total + order.Total.MulFloat(exchange.Fees.Taker)
+	} else {
+		simulated.Reserved = order.Amount
+	}
+
+	held := exchange.heldBalance(simulated)
+	if held.Available < simulated.Reserved {
+		return fmt.Errorf("insufficient balance: %s < %s", held.Available, simulated.Reserved)
+	}
+
+	exchange.nextOrderId += 1
+	order.Id = fmt.Sprintf("bt%d", exchange.nextOrderId)
+
+	held.Available -= simulated.Reserved
+	held.OnOrders += simulated.Reserved
+
+	fill := exchange.FillModel.Fill(simulated, current)
+	if fill != nil {
+		exchange.fill(simulated, fill, exchange.Now)
+	}
+
+	if simulated.Cancelled {
+		exchange.release(simulated)
+	} else if simulated.Remaining > 0 {
+		market.pending = append(market.pending, simulated)
+	}
+
+	return nil
+}
+
+// heldBalance is the balance an order holds funds from.
+func (exchange *SimulatedExchange) heldBalance(order *SimulatedOrder) *Balance {
+	if order.Type == "buy" {
+		return exchange.balance(order.Market.GetBaseCurrency())
+	}
+
+	return exchange.balance(order.Market.GetCurrency())
+}
+
+// release makes what order still holds available again, once it is filled or
+// cancelled.
+func (exchange *SimulatedExchange) release(order *SimulatedOrder) {
+	held := exchange.heldBalance(order)
+	held.OnOrders -= order.Reserved
+	held.Available += order.Reserved
+	order.Reserved = 0
+}
+
+func (exchange *SimulatedExchange) fill(order *SimulatedOrder, fill *SimulatedFill, date int64) {
+	base := exchange.balance(order.Market.GetBaseCurrency())
+	alt := exchange.balance(order.Market.GetCurrency())
+
+	amount := fill.Amount
+	if amount > order.Remaining {
+		amount = order.Remaining
+	}
+
+	total := fill.Price.Mul(amount)
+	fee := total.MulFloat(exchange.Fees.Maker)
+	if fill.Taker {
+		fee = total.MulFloat(exchange.Fees.Taker)
+	}
+
+	if order.Type == "buy" {
+		base.OnOrders -= total + fee
+		order.Reserved -= total + fee
+		alt.Available += amount
+	} else {
+		alt.OnOrders -= amount
+		order.Reserved -= amount
+		base.Available += total - fee
+	}
+
+	order.Remaining -= amount
+
+	if order.Remaining <= 0 {
+		exchange.release(order)
+	}
+
+	trades := exchange.orderTrades[order.Id]
+
+	trade := &Trade{
+		Id:       fmt.Sprintf("%s-%d", order.Id, len(trades)+1),
+		OrderId:  order.Id,
+		Market:   order.Market.Name,
+		Date:     date,
+		Type:     order.Type,
+		Price:    fill.Price,
+		Amount:   amount,
+		Total:    total,
+		Fee:      fee,
+		Exchange: "simulated",
+	}
+
+	exchange.orderTrades[order.Id] = append(trades, trade)
+	exchange.Trades = append(exchange.Trades, trade)
+}
+
+type SimulatedMarket struct {
+	Name     string
+	Exchange *SimulatedExchange
+	Candles  []*SummaryData
+	Window   int64
+
+	// index of the newest candle visible at the current time
+	current     int
+	pending     []*SimulatedOrder
+	percentiles *RollingPercentiles
+	indicators  *IndicatorSet
+
+	// index of the oldest candle in the ticker volume window
+	volumeStart int
+	baseVolume  float64
+}
+
+func (market *SimulatedMarket) advance(date int64) {
+	for market.current+1 < len(market.Candles) && market.Candles[market.current+1].Date <= date {
+		market.current += 1
+
+		candle := market.Candles[market.current]
+
+		for _, order := range market.pending {
+			order.Candles += 1
+		}
+		market.fillPending(candle)
+
+		market.percentiles.Add(candle.Date, candle.WeightedAverage)
+		market.indicators.Update(candle)
+		market.baseVolume += candle.Volume
+	}
+
+	for market.volumeStart <= market.current && market.Candles[market.volumeStart].Date <= date-TICKER_VOLUME_WINDOW {
+		market.baseVolume -= market.Candles[market.volumeStart].Volume
+		market.volumeStart += 1
+	}
+}
+
+// BaseVolume is the volume traded in the base currency over the last 24
+// hours, like the ticker's BaseVolume.
+func (market *SimulatedMarket) BaseVolume() float64 {
+	if market.volumeStart > market.current {
+		return 0.0
+	}
+
+	return market.baseVolume
+}
+
+// fillPending offers candle to the fill model for every order on the book and
+// removes the orders that are filled or cancelled.
+func (market *SimulatedMarket) fillPending(candle *SummaryData) {
+	exchange := market.Exchange
+	pending := make([]*SimulatedOrder, 0, len(market.pending))
+
+	for _, order := range market.pending {
+		fill := exchange.FillModel.Fill(order, candle)
+		if fill != nil {
+			exchange.fill(order, fill, candle.Date)
+		}
+
+		if order.Cancelled {
+			exchange.release(order)
+		} else if order.Remaining > 0 {
+			pending = append(pending, order)
+		}
+	}
+
+	market.pending = pending
+}
+
+// Current returns the newest candle visible at the current time, or nil.
+func (market *SimulatedMarket) Current() *SummaryData {
+	if market.current < 0 {
+		return nil
+	}
+
+	return market.Candles[market.current]
+}
+
+func (market *SimulatedMarket) GetName() string {
+	return market.Name
+}
+
+func (market *SimulatedMarket) GetBaseCurrency() string {
+	return marketSymbol(market.Name).Base
+}
+
+func (market *SimulatedMarket) GetCurrency() string {
+	return marketSymbol(market.Name).Currency
+}
+
+func (market *SimulatedMarket) Exists() bool {
+	return true
+}
+
+// GetCurrentPrice is the weighted average of the current candle.
+func (market *SimulatedMarket) GetCurrentPrice() (float64, error) {
+	current := market.Current()
+	if current == nil {
+		return 0.0, fmt.Errorf("%s has no chart data before %d", market.Name, market.Exchange.Now)
+	}
+
+	return current.WeightedAverage, nil
+}
+
+// GetInfo describes Poloniex's markets with the exchange's fees.
+func (market *SimulatedMarket) GetInfo() (*MarketInfo, error) {
+	info := NewPlxMarketInfo()
+	info.Fees = market.Exchange.Fees
+	return info, nil
+}
+
+func (market *SimulatedMarket) GetSummaryData(startTime, endTime int64) ([]*SummaryData, error) {
+	visible := market.Candles[:market.current+1]
+
+	first := sort.Search(len(visible), func(i int) bool { return visible[i].Date >= startTime })
+	last := sort.Search(len(visible), func(i int) bool { return visible[i].Date > endTime })
+
+	return visible[first:last], nil
+}
+
+func (market *SimulatedMarket) RollingData(window int64) ([]float64, map[string]float64, bool) {
+	if window != market.Window || market.percentiles.Len() == 0 {
+		return nil, nil, false
+	}
+
+	return market.percentiles.Percentiles(), market.indicators.Values(), true
+}
+
+// GetPendingOrders returns the orders on the book with their remaining
+// amounts, like the exchange's open orders.
+func (market *SimulatedMarket) GetPendingOrders() ([]*Order, error) {
+	orders := make([]*Order, 0, len(market.pending))
+
+	for _, order := range market.pending {
+		orders = append(orders, &Order{
+			Id:     order.Id,
+			Type:   order.Type,
+			Price:  order.Price,
+			Amount: order.Remaining,
+			Total:  order.Price.Mul(order.Remaining),
+		})
+	}
+
+	return orders, nil
+}
+
+func (market *SimulatedMarket) GetOrderTrades(order *Order) ([]*Trade, error) {
+	return market.Exchange.orderTrades[order.Id], nil
+}
+
+func (market *SimulatedMarket) Buy(order *Order) error {
+	order.Type = "buy"
+	return market.Exchange.placeOrder(market, order)
+}
+
+func (market *SimulatedMarket) Sell(order *Order) error {
+	order.Type = "sell"
+	return market.Exchange.placeOrder(market, order)
+}
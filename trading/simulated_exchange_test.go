@@ -0,0 +1,185 @@
+package trading
+
+import (
+	"github.com/jbgo/sftbot/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSimulatedExchange(t *testing.T) {
+	exchange := NewSimulatedExchange(map[string]float64{"BTC": 1.0})
+	exchange.Fees.Taker = 0.01
+
+	candles := []*SummaryData{
+		&SummaryData{Date: 300, WeightedAverage: 0.1},
+		&SummaryData{Date: 600, WeightedAverage: 0.2},
+		&SummaryData{Date: 900, WeightedAverage: 0.4},
+	}
+
+	market := exchange.AddMarket("BTC_ABC", candles, 600)
+
+	t.Run("before the first candle", func(t *testing.T) {
+		_, err := market.GetCurrentPrice()
+		assert.NotNil(t, err)
+
+		_, _, ok := market.RollingData(600)
+		assert.False(t, ok)
+
+		err = market.Buy(&Order{Price: data.NewDecimal(0.1), Amount: data.NewDecimal(1.0)})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Advance", func(t *testing.T) {
+		exchange.Advance(700)
+
+		price, err := market.GetCurrentPrice()
+		require.Nil(t, err)
+		assert.Equal(t, 0.2, price)
+
+		summaryData, err := market.GetSummaryData(0, 1000)
+		require.Nil(t, err)
+		assert.Equal(t, 2, len(summaryData))
+
+		percentiles, _, ok := market.RollingData(600)
+		assert.True(t, ok)
+		assert.Equal(t, 0.2, percentiles[100])
+
+		_, _, ok = market.RollingData(300)
+		assert.False(t, ok)
+	})
+
+	t.Run("Buy", func(t *testing.T) {
+		order := &Order{Price: data.NewDecimal(0.2), Amount: data.NewDecimal(2.0)}
+		require.Nil(t, market.Buy(order))
+
+		assert.Equal(t, "bt1", order.Id)
+		assert.Equal(t, "buy", order.Type)
+
+		btc, _ := exchange.GetBalance("BTC")
+		abc, _ := exchange.GetBalance("ABC")
+		assert.Equal(t, data.NewDecimal(1.0-0.4-0.004), btc.Available)
+		assert.Equal(t, data.NewDecimal(2.0), abc.Available)
+
+		trades, err := market.GetOrderTrades(order)
+		require.Nil(t, err)
+		require.Equal(t, 1, len(trades))
+		assert.Equal(t, int64(700), trades[0].Date)
+		assert.Equal(t, data.NewDecimal(0.004), trades[0].Fee)
+
+		pending, err := market.GetPendingOrders()
+		require.Nil(t, err)
+		assert.Equal(t, 0, len(pending))
+	})
+
+	t.Run("Sell", func(t *testing.T) {
+		exchange.Advance(900)
+
+		err := market.Sell(&Order{Price: data.NewDecimal(0.4), Amount: data.NewDecimal(3.0)})
+		assert.NotNil(t, err)
+
+		require.Nil(t, market.Sell(&Order{Price: data.NewDecimal(0.4), Amount: data.NewDecimal(2.0)}))
+
+		btc, _ := exchange.GetBalance("BTC")
+		abc, _ := exchange.GetBalance("ABC")
+		assert.Equal(t, data.NewDecimal(0.596+0.8-0.008), btc.Available)
+		assert.Equal(t, data.NewDecimal(0.0), abc.Available)
+		assert.Equal(t, 2, len(exchange.Trades))
+	})
+
+	t.Run("GetMarket", func(t *testing.T) {
+		_, err := exchange.GetMarket("BTC_XYZ")
+		assert.NotNil(t, err)
+
+		found, err := exchange.GetMarket("BTC_ABC")
+		require.Nil(t, err)
+		assert.Equal(t, "ABC", found.GetCurrency())
+	})
+}
+
+func TestSimulatedExchangeOrderBook(t *testing.T) {
+	exchange := NewSimulatedExchange(map[string]float64{"BTC": 1.0})
+	exchange.Fees = FeeSchedule{Maker: 0.001, Taker: 0.002}
+	exchange.FillModel = &CandleFill{Participation: 0.5}
+
+	candles := []*SummaryData{
+		&SummaryData{Date: 300, Open: 0.2, High: 0.2, Low: 0.2, WeightedAverage: 0.2, QuoteVolume: 10},
+		&SummaryData{Date: 600, Open: 0.2, High: 0.2, Low: 0.15, WeightedAverage: 0.18, QuoteVolume: 4},
+		&SummaryData{Date: 900, Open: 0.16, High: 0.17, Low: 0.15, WeightedAverage: 0.16, QuoteVolume: 10},
+	}
+
+	market := exchange.AddMarket("BTC_ABC", candles, 600)
+	exchange.Advance(300)
+
+	order := &Order{Price: data.NewDecimal(0.16), Amount: data.NewDecimal(3.0)}
+	require.Nil(t, market.Buy(order))
+
+	btc, _ := exchange.GetBalance("BTC")
+	assert.Equal(t, data.NewDecimal(1.0-0.48*1.002), btc.Available)
+	assert.Equal(t, data.NewDecimal(0.48*1.002), btc.OnOrders)
+
+	t.Run("fills up to the participation cap", func(t *testing.T) {
+		exchange.Advance(600)
+
+		pending, _ := market.GetPendingOrders()
+		require.Equal(t, 1, len(pending))
+		assert.Equal(t, data.NewDecimal(1.0), pending[0].Amount)
+
+		abc, _ := exchange.GetBalance("ABC")
+		assert.Equal(t, data.NewDecimal(2.0), abc.Available)
+
+		trades, _ := market.GetOrderTrades(order)
+		require.Equal(t, 1, len(trades))
+		assert.Equal(t, int64(600), trades[0].Date)
+		assert.Equal(t, data.NewDecimal(0.32*0.001), trades[0].Fee)
+	})
+
+	t.Run("releases what is left of the hold when filled", func(t *testing.T) {
+		exchange.Advance(900)
+
+		pending, _ := market.GetPendingOrders()
+		assert.Equal(t, 0, len(pending))
+
+		btc, _ := exchange.GetBalance("BTC")
+		assert.Equal(t, data.NewDecimal(1.0-0.48*1.001), btc.Available)
+		assert.Equal(t, data.NewDecimal(0.0), btc.OnOrders)
+
+		abc, _ := exchange.GetBalance("ABC")
+		assert.Equal(t, data.NewDecimal(3.0), abc.Available)
+	})
+
+	t.Run("insufficient balance", func(t *testing.T) {
+		err := market.Sell(&Order{Price: data.NewDecimal(0.2), Amount: data.NewDecimal(4.0)})
+		assert.NotNil(t, err)
+	})
+}
+
+func TestSimulatedExchangeCancelsImmediateOrders(t *testing.T) {
+	exchange := NewSimulatedExchange(map[string]float64{"BTC": 1.0})
+	exchange.Fees = FeeSchedule{Maker: 0.001, Taker: 0.002}
+	exchange.FillModel = &CandleFill{Participation: 0.5}
+
+	candles := []*SummaryData{
+		&SummaryData{Date: 300, Open: 0.2, High: 0.2, Low: 0.2, WeightedAverage: 0.2, QuoteVolume: 10},
+		&SummaryData{Date: 600, Open: 0.2, High: 0.2, Low: 0.15, WeightedAverage: 0.18, QuoteVolume: 4},
+	}
+
+	market := exchange.AddMarket("BTC_ABC", candles, 600)
+	exchange.Advance(300)
+
+	order := &Order{Price: data.NewDecimal(0.2), Amount: data.NewDecimal(3.0), Execution: ORDER_IMMEDIATE_OR_CANCEL}
+	require.Nil(t, market.Buy(order))
+
+	exchange.Advance(600)
+
+	pending, _ := market.GetPendingOrders()
+	assert.Equal(t, 0, len(pending))
+
+	trades, _ := market.GetOrderTrades(order)
+	require.Equal(t, 1, len(trades))
+	assert.Equal(t, data.NewDecimal(2.0), trades[0].Amount)
+
+	btc, _ := exchange.GetBalance("BTC")
+	assert.Equal(t, data.NewDecimal(1.0-0.4*1.002), btc.Available)
+	assert.Equal(t, data.Decimal(0), btc.OnOrders)
+}
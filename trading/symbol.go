@@ -0,0 +1,66 @@
+package trading
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Symbol identifies a market by the currency it is priced in (Base) and the
+// currency that is bought and sold (Currency). Its canonical name is
+// BASE_CURRENCY, e.g. BTC_XYZ, which is how markets are named in configs, the
+// ledger and the database whichever exchange they trade on.
+type Symbol struct {
+	Base     string
+	Currency string
+}
+
+func NewSymbol(base, currency string) Symbol {
+	return Symbol{Base: base, Currency: currency}
+}
+
+// ParseSymbol parses a canonical market name.
+func ParseSymbol(name string) (Symbol, error) {
+	return CANONICAL_SYMBOLS.ParseExchangeSymbol(name)
+}
+
+func (symbol Symbol) String() string {
+	return CANONICAL_SYMBOLS.ExchangeSymbol(symbol)
+}
+
+// SymbolMapper translates between canonical symbols and the market names of
+// an exchange.
+type SymbolMapper interface {
+	ExchangeSymbol(symbol Symbol) string
+	ParseExchangeSymbol(name string) (Symbol, error)
+}
+
+// DelimitedSymbols maps symbols to names with the base currency first and the
+// two currencies joined by Separator.
+type DelimitedSymbols struct {
+	Separator string
+}
+
+var CANONICAL_SYMBOLS = &DelimitedSymbols{Separator: "_"}
+var PLX_SYMBOLS = &DelimitedSymbols{Separator: "_"}
+var BITTREX_SYMBOLS = &DelimitedSymbols{Separator: "-"}
+
+func (mapper *DelimitedSymbols) ExchangeSymbol(symbol Symbol) string {
+	return symbol.Base + mapper.Separator + symbol.Currency
+}
+
+func (mapper *DelimitedSymbols) ParseExchangeSymbol(name string) (Symbol, error) {
+	parts := strings.Split(name, mapper.Separator)
+
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return Symbol{}, fmt.Errorf("invalid market name: %s", name)
+	}
+
+	return NewSymbol(parts[0], parts[1]), nil
+}
+
+// marketSymbol parses the canonical name of a market that is already known to
+// exist, so the name is assumed to be valid.
+func marketSymbol(name string) Symbol {
+	symbol, _ := ParseSymbol(name)
+	return symbol
+}
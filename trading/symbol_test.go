@@ -0,0 +1,27 @@
+package trading
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSymbol(t *testing.T) {
+	symbol, err := ParseSymbol("BTC_XYZ")
+	require.Nil(t, err)
+	assert.Equal(t, NewSymbol("BTC", "XYZ"), symbol)
+	assert.Equal(t, "BTC_XYZ", symbol.String())
+	assert.Equal(t, "BTC-XYZ", BITTREX_SYMBOLS.ExchangeSymbol(symbol))
+
+	symbol, err = BITTREX_SYMBOLS.ParseExchangeSymbol("ETH-XYZ")
+	require.Nil(t, err)
+	assert.Equal(t, "ETH_XYZ", symbol.String())
+
+	for _, name := range []string{"BTCXYZ", "BTC_", "_XYZ", "BTC_XYZ_ABC"} {
+		_, err = ParseSymbol(name)
+		assert.NotNil(t, err, name)
+	}
+
+	assert.True(t, ShouldTradeMarket("BTC_XYZ", MIN_MARKET_BASE_VOLUME))
+	assert.False(t, ShouldTradeMarket("USDT_BTC", MIN_MARKET_BASE_VOLUME))
+}
@@ -0,0 +1,138 @@
+package trading
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/jbgo/sftbot/data"
+	"github.com/jbgo/sftbot/plx"
+	"io"
+	"sort"
+	"time"
+)
+
+// TaxReport lists every acquisition and disposal within a calendar year
+// (UTC). Disposals are matched against lots from the full trade history, so
+// lots acquired in earlier years still provide the cost basis.
+type TaxReport struct {
+	Year         int
+	Method       string
+	Acquisitions []*Lot
+	Disposals    []*Disposal
+}
+
+func BuildTaxReport(trades []*Trade, year int, method string) (*TaxReport, error) {
+	if !ValidLotMethod(method) {
+		return nil, fmt.Errorf("unknown lot method: %s", method)
+	}
+
+	yearStart := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	yearEnd := time.Date(year+1, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	inYear := func(date int64) bool { return date >= yearStart && date < yearEnd }
+
+	sorted := make([]*Trade, len(trades))
+	copy(sorted, trades)
+	sort.Stable(ByTradeDate(sorted))
+
+	report := &TaxReport{Year: year, Method: method}
+	books := make(map[string]*LotBook)
+
+	for _, trade := range sorted {
+		book, ok := books[trade.Market]
+		if !ok {
+			book = NewLotBook(trade.Market)
+			book.Method = method
+			books[trade.Market] = book
+		}
+
+		if trade.Type == "buy" {
+			book.Buy(trade)
+
+			if inYear(trade.Date) {
+				lot := *book.Lots[len(book.Lots)-1]
+				report.Acquisitions = append(report.Acquisitions, &lot)
+			}
+
+			continue
+		}
+
+		for _, d := range book.Sell(trade) {
+			if inYear(d.Disposed) {
+				report.Disposals = append(report.Disposals, d)
+			}
+		}
+	}
+
+	return report, nil
+}
+
+func (report *TaxReport) TotalGain() (gain data.Decimal) {
+	for _, d := range report.Disposals {
+		gain += d.Gain()
+	}
+	return gain
+}
+
+// WriteCSV writes acquisitions followed by disposals. All values are in BTC.
+func (report *TaxReport) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+
+	out.Write([]string{
+		"type", "market", "currency", "trade_id", "date_acquired", "date_disposed",
+		"amount", "proceeds_btc", "cost_basis_btc", "gain_btc",
+	})
+
+	for _, lot := range report.Acquisitions {
+		out.Write([]string{
+			"acquisition",
+			lot.Market,
+			marketCurrency(lot.Market),
+			lot.TradeId,
+			formatReportDate(lot.Date),
+			"",
+			lot.Amount.Format(),
+			"",
+			lot.Cost.Format(),
+			"",
+		})
+	}
+
+	for _, d := range report.Disposals {
+		out.Write([]string{
+			"disposal",
+			d.Market,
+			marketCurrency(d.Market),
+			d.TradeId,
+			formatReportDate(d.Acquired),
+			formatReportDate(d.Disposed),
+			d.Amount.Format(),
+			d.Proceeds.Format(),
+			d.CostBasis.Format(),
+			d.Gain().Format(),
+		})
+	}
+
+	out.Flush()
+
+	return out.Error()
+}
+
+// TradesFromPlxHistory flattens the result of plx.Client.MyTradeHistory.
+func TradesFromPlxHistory(history map[string][]*plx.PlxPrivateTrade) []*Trade {
+	trades := make([]*Trade, 0)
+
+	for marketName, plxTrades := range history {
+		for _, t := range plxTrades {
+			trades = append(trades, NewTradeFromPlx(marketName, t))
+		}
+	}
+
+	return trades
+}
+
+func marketCurrency(marketName string) string {
+	return marketSymbol(marketName).Currency
+}
+
+func formatReportDate(date int64) string {
+	return time.Unix(date, 0).UTC().Format("2006-01-02 15:04:05")
+}
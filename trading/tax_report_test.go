@@ -0,0 +1,64 @@
+package trading
+
+import (
+	"bytes"
+	"github.com/jbgo/sftbot/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTaxReport(t *testing.T) {
+	date := func(year int, month time.Month) int64 {
+		return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC).Unix()
+	}
+
+	trades := []*Trade{
+		&Trade{Id: "1", Market: "BTC_ABC", Date: date(2025, 6), Type: "buy", Amount: data.NewDecimal(2.0), Total: data.NewDecimal(0.2)},
+		&Trade{Id: "2", Market: "BTC_ABC", Date: date(2026, 2), Type: "buy", Amount: data.NewDecimal(2.0), Total: data.NewDecimal(0.4)},
+		&Trade{Id: "3", Market: "BTC_ABC", Date: date(2026, 3), Type: "sell", Amount: data.NewDecimal(1.0), Total: data.NewDecimal(0.3)},
+		&Trade{Id: "4", Market: "BTC_XYZ", Date: date(2025, 12), Type: "sell", Amount: data.NewDecimal(1.0), Total: data.NewDecimal(0.3)},
+	}
+
+	t.Run("fifo", func(t *testing.T) {
+		report, err := BuildTaxReport(trades, 2026, LOT_METHOD_FIFO)
+		require.Nil(t, err)
+
+		require.Equal(t, 1, len(report.Acquisitions))
+		assert.Equal(t, "2", report.Acquisitions[0].TradeId)
+
+		require.Equal(t, 1, len(report.Disposals))
+		assert.Equal(t, date(2025, 6), report.Disposals[0].Acquired)
+		assert.Equal(t, data.NewDecimal(0.2), report.TotalGain())
+	})
+
+	t.Run("hifo", func(t *testing.T) {
+		report, err := BuildTaxReport(trades, 2026, LOT_METHOD_HIFO)
+		require.Nil(t, err)
+
+		require.Equal(t, 1, len(report.Disposals))
+		assert.Equal(t, date(2026, 2), report.Disposals[0].Acquired)
+		assert.Equal(t, data.NewDecimal(0.1), report.TotalGain())
+	})
+
+	t.Run("unknown method", func(t *testing.T) {
+		_, err := BuildTaxReport(trades, 2026, "avg")
+		require.NotNil(t, err)
+	})
+
+	t.Run("WriteCSV", func(t *testing.T) {
+		report, err := BuildTaxReport(trades, 2026, LOT_METHOD_FIFO)
+		require.Nil(t, err)
+
+		buf := &bytes.Buffer{}
+		err = report.WriteCSV(buf)
+		require.Nil(t, err)
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Equal(t, 3, len(lines))
+		assert.Equal(t, "acquisition,BTC_ABC,ABC,2,2026-02-01 00:00:00,,2.00000000,,0.40000000,", lines[1])
+		assert.Equal(t, "disposal,BTC_ABC,ABC,3,2025-06-01 00:00:00,2026-03-01 00:00:00,1.00000000,0.30000000,0.10000000,0.20000000", lines[2])
+	})
+}
@@ -0,0 +1,114 @@
+package trading
+
+import (
+	"fmt"
+	"github.com/jbgo/sftbot/db"
+	"github.com/jbgo/sftbot/plx"
+	"time"
+)
+
+// The most trades returnTradeHistory returns for a single request.
+const TRADE_IMPORT_PAGE_SIZE = 10000
+const TRADE_IMPORT_WINDOW = 30 * 24 * 60 * 60
+const TRADE_IMPORT_CURSOR_KEY = "trade_import.cursor"
+
+// TradeImporter copies the private trade history of every market into the
+// ledger. Requests are split into windows, and a window is paged backwards
+// whenever it holds more trades than a single request returns. The end of the
+// last completed window is saved so the next import can resume from there.
+type TradeImporter struct {
+	Client     *plx.Client
+	Ledger     *Ledger
+	DB         db.Store
+	PageSize   int64
+	WindowSize int64
+	// Pause between API requests to stay below the rate limit.
+	Delay time.Duration
+}
+
+func NewTradeImporter(client *plx.Client, ledger *Ledger, dbStore db.Store) *TradeImporter {
+	return &TradeImporter{
+		Client:     client,
+		Ledger:     ledger,
+		DB:         dbStore,
+		PageSize:   TRADE_IMPORT_PAGE_SIZE,
+		WindowSize: TRADE_IMPORT_WINDOW,
+		Delay:      200 * time.Millisecond,
+	}
+}
+
+// Cursor returns the time up to which trades have been imported, or zero if
+// nothing has been imported yet.
+func (importer *TradeImporter) Cursor() (cursor int64, err error) {
+	err, hasData := importer.DB.HasData(TRADE_IMPORT_CURSOR_KEY)
+	if err != nil || !hasData {
+		return 0, err
+	}
+
+	err = importer.DB.Read(TRADE_IMPORT_CURSOR_KEY, &cursor)
+
+	return cursor, err
+}
+
+// Import fetches all trades between startTime and endTime and returns the
+// number of trades fetched. Trades already in the ledger are not duplicated.
+func (importer *TradeImporter) Import(startTime, endTime int64) (fetched int, err error) {
+	for windowStart := startTime; windowStart < endTime; windowStart += importer.WindowSize {
+		windowEnd := windowStart + importer.WindowSize
+		if windowEnd > endTime {
+			windowEnd = endTime
+		}
+
+		n, err := importer.importWindow(windowStart, windowEnd)
+		fetched += n
+
+		if err != nil {
+			return fetched, err
+		}
+
+		err = importer.DB.Write(TRADE_IMPORT_CURSOR_KEY, windowEnd)
+		if err != nil {
+			return fetched, err
+		}
+	}
+
+	return fetched, nil
+}
+
+func (importer *TradeImporter) importWindow(startTime, endTime int64) (fetched int, err error) {
+	for {
+		history, err := importer.Client.MyTradeHistoryLimit("all", startTime, endTime, importer.PageSize)
+		if err != nil {
+			return fetched, err
+		}
+
+		trades := TradesFromPlxHistory(history)
+		fetched += len(trades)
+
+		err = importer.Ledger.Record(trades...)
+		if err != nil {
+			return fetched, err
+		}
+
+		time.Sleep(importer.Delay)
+
+		if int64(len(trades)) < importer.PageSize {
+			return fetched, nil
+		}
+
+		// Results are newest first, so the next page ends at the oldest trade
+		// seen. Trades at that second are fetched again and deduplicated.
+		oldest := endTime
+		for _, trade := range trades {
+			if trade.Date < oldest {
+				oldest = trade.Date
+			}
+		}
+
+		if oldest >= endTime {
+			return fetched, fmt.Errorf("more than %d trades at %d, cannot page further", importer.PageSize, endTime)
+		}
+
+		endTime = oldest
+	}
+}
@@ -0,0 +1,92 @@
+package trading
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jbgo/sftbot/data"
+	"github.com/jbgo/sftbot/db"
+	"github.com/jbgo/sftbot/plx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTradeImporter(t *testing.T) {
+	history := make([]map[string]interface{}, 0)
+	for i := 1; i <= 7; i += 1 {
+		history = append(history, map[string]interface{}{
+			"globalTradeID": i,
+			"date":          time.Unix(int64(i*100), 0).UTC().Format("2006-01-02 15:04:05"),
+			"type":          "buy",
+			"rate":          "0.1",
+			"amount":        "1.0",
+			"total":         "0.1",
+			"fee":           "0.0025",
+		})
+	}
+
+	requests := 0
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+
+		start, _ := strconv.ParseInt(r.PostFormValue("start"), 10, 64)
+		end, _ := strconv.ParseInt(r.PostFormValue("end"), 10, 64)
+		limit, _ := strconv.Atoi(r.PostFormValue("limit"))
+
+		page := make([]map[string]interface{}, 0)
+		for i := len(history) - 1; i >= 0 && len(page) < limit; i -= 1 {
+			date := int64((i + 1) * 100)
+			if date >= start && date <= end {
+				page = append(page, history[i])
+			}
+		}
+
+		encoded, _ := json.Marshal(map[string]interface{}{"BTC_ABC": page})
+		fmt.Fprintln(w, string(encoded))
+	}))
+
+	defer testServer.Close()
+
+	ledgerStore, err := db.NewBoltStore("trade_import_test_ledger", "test.db")
+	require.Nil(t, err)
+	ledgerStore.Delete("BTC_ABC")
+
+	importStore, err := db.NewBoltStore("trade_import_test", "test.db")
+	require.Nil(t, err)
+	importStore.Delete(TRADE_IMPORT_CURSOR_KEY)
+
+	client := plx.NewClient(testServer.URL, credentialsPath())
+	ledger := NewLedger(ledgerStore)
+
+	importer := NewTradeImporter(client, ledger, importStore)
+	importer.PageSize = 3
+	importer.WindowSize = 500
+	importer.Delay = 0
+
+	cursor, err := importer.Cursor()
+	require.Nil(t, err)
+	assert.Equal(t, int64(0), cursor)
+
+	_, err = importer.Import(0, 1000)
+	require.Nil(t, err)
+
+	// window 0-500 holds 5 trades and needs 3 pages, window 500-1000 holds 3
+	// trades (including the one at 500 again) and needs 2 pages
+	assert.Equal(t, 5, requests)
+
+	trades, err := ledger.Trades("BTC_ABC")
+	require.Nil(t, err)
+	require.Equal(t, 7, len(trades))
+	assert.Equal(t, "1", trades[0].Id)
+	assert.Equal(t, int64(700), trades[6].Date)
+	assert.Equal(t, data.NewDecimal(0.00025), trades[0].Fee)
+
+	cursor, err = importer.Cursor()
+	require.Nil(t, err)
+	assert.Equal(t, int64(1000), cursor)
+}
@@ -2,13 +2,15 @@ package trading
 
 import (
 	"fmt"
+	"github.com/jbgo/sftbot/data"
 	"github.com/jbgo/sftbot/db"
 	"log"
-	"sort"
 	"strings"
 	"time"
 )
 
+// BTC_Balance and BTC_BuyAmount are in the base currency of the market, which
+// is BTC for BTC_XYZ markets but e.g. USDT for USDT_XYZ markets.
 type Trader struct {
 	Market           Market
 	Exchange         Exchange
@@ -23,10 +25,24 @@ type Trader struct {
 	ALT_SellRatio    float64
 	TimeWindow       int64
 	EstimatedFee     float64
+	BuyExecution     string
+	SellExecution    string
 	Bids             []*Order
 	Asks             []*Order
 	DB               db.Store
 	StateKey         string
+	// When set, orders are rounded to the precision of the market, and orders
+	// the market would reject are skipped.
+	Info *MarketInfo
+	// When set, every fill detected by Reconcile is recorded here.
+	Ledger *Ledger
+	// When set, every order placed is recorded here with the market data and
+	// thresholds it was based on.
+	Journal *Journal
+	// Clock and Logger default to time.Now and the standard logger. Backtests
+	// replace them to replay history quietly.
+	Clock  func() time.Time
+	Logger *log.Logger
 }
 
 type TraderConfig struct {
@@ -46,6 +62,11 @@ type TraderConfig struct {
 	EstimatedFee                   float64
 	StateKey                       string
 	Simulate                       bool
+	// How buy and sell orders may fill, see Order.Execution. E.g. post-only
+	// buys only pay the maker fee, and immediate-or-cancel sells never leave
+	// an exit waiting on the book.
+	BuyExecution  string
+	SellExecution string
 }
 
 func DefaultTraderConfig() *TraderConfig {
@@ -77,22 +98,21 @@ type TraderState struct {
 	Asks          []*Order
 }
 
+// RollingMarket is implemented by markets that keep the percentiles and
+// indicators of a sliding window up to date as candles arrive, such as
+// SimulatedMarket, so the trader does not recompute them over the whole window
+// on every run. ok is false when the market does not track a window of that
+// length.
+type RollingMarket interface {
+	RollingData(window int64) (percentiles []float64, indicators map[string]float64, ok bool)
+}
+
 type MarketData struct {
 	CurrentPrice    float64
 	Percentiles     []float64
 	VolatilityIndex float64
-}
-
-type Trade struct {
-	Date     int64
-	Type     string
-	Price    float64
-	Amount   float64
-	Total    float64
-	Fee      float64
-	Exchange string
-	// For storing data specific to a particular exchange
-	Metadata interface{}
+	// Latest indicator values by name, see calculateIndicators.
+	Indicators map[string]float64
 }
 
 func NewTrader(marketName string, exchange Exchange, dbStore db.Store, config *TraderConfig) (trader *Trader, err error) {
@@ -105,6 +125,11 @@ func NewTrader(marketName string, exchange Exchange, dbStore db.Store, config *T
 		return nil, fmt.Errorf("market not found: %s", market.GetName())
 	}
 
+	info, err := market.GetInfo()
+	if err != nil {
+		return nil, err
+	}
+
 	return &Trader{
 		Market:           market,
 		Config:           config,
@@ -116,9 +141,12 @@ func NewTrader(marketName string, exchange Exchange, dbStore db.Store, config *T
 		ALT_SellRatio:    config.ALT_SellRatio,
 		TimeWindow:       config.TimeWindow,
 		EstimatedFee:     config.EstimatedFee,
+		BuyExecution:     config.BuyExecution,
+		SellExecution:    config.SellExecution,
 		Exchange:         exchange,
 		DB:               dbStore,
 		StateKey:         config.StateKey + "_" + market.GetName(),
+		Info:             info,
 	}, nil
 }
 
@@ -184,10 +212,10 @@ func (t *Trader) logState(marketData *MarketData) {
 
 	askPrice := 0.0
 	if len(t.Bids) > 0 {
-		askPrice = t.Bids[len(t.Bids)-1].Price * t.SellThreshold
+		askPrice = t.Bids[len(t.Bids)-1].Price.Float64() * t.SellThreshold
 	}
 
-	log.Printf(formatString+"\n",
+	t.logf(formatString+"\n",
 		t.Market.GetName(),
 		marketData.CurrentPrice,
 		marketData.Percentiles[t.BuyThreshold],
@@ -204,7 +232,7 @@ func (t *Trader) logOrder(order *Order) {
 		return
 	}
 
-	log.Printf("market=%s evt=order id=%s type=%s price=%0.9f amount=%0.9f total=%0.9f\n",
+	t.logf("market=%s evt=order id=%s type=%s price=%s amount=%s total=%s\n",
 		t.Market.GetName(),
 		order.Id,
 		order.Type,
@@ -214,7 +242,7 @@ func (t *Trader) logOrder(order *Order) {
 }
 
 func (t *Trader) LoadBalances() (err error) {
-	t.BTC_Balance, err = t.Exchange.GetBalance("BTC")
+	t.BTC_Balance, err = t.Exchange.GetBalance(t.Market.GetBaseCurrency())
 	if err != nil {
 		return err
 	}
@@ -234,15 +262,19 @@ func (t *Trader) Reconcile() error {
 		return err
 	}
 
-	markFilledBids(pendingOrders, t.Bids)
+	filledOrders := t.removeCancelledBids(markFilledBids(pendingOrders, t.Bids))
+	filledOrders = append(filledOrders, t.restoreCancelledAsks(findFilledOrders(pendingOrders, t.Asks))...)
 
 	t.Asks = removeFilledAsks(pendingOrders, t.Asks)
 
-	return nil
+	return t.RecordFills(filledOrders)
 }
 
-func markFilledBids(pendingOrders, bids []*Order) {
+// markFilledBids marks bids that are no longer pending as filled and returns
+// the ones that were filled since the last time they were checked.
+func markFilledBids(pendingOrders, bids []*Order) (newlyFilled []*Order) {
 	for _, bid := range bids {
+		wasFilled := bid.Filled
 		bid.Filled = true
 
 		for _, order := range pendingOrders {
@@ -251,7 +283,102 @@ func markFilledBids(pendingOrders, bids []*Order) {
 				break
 			}
 		}
+
+		if bid.Filled && !wasFilled {
+			newlyFilled = append(newlyFilled, bid)
+		}
+	}
+
+	return newlyFilled
+}
+
+// removeCancelledBids removes the bids among newlyFilled that left the book
+// without a single trade, undoing their threshold steps, and returns the
+// others.
+func (t *Trader) removeCancelledBids(newlyFilled []*Order) []*Order {
+	filled := make([]*Order, 0, len(newlyFilled))
+
+	for _, bid := range newlyFilled {
+		if !t.cancelledWithoutFill(bid) {
+			filled = append(filled, bid)
+			continue
+		}
+
+		t.undoThresholds(bid)
+
+		for i, b := range t.Bids {
+			if b == bid {
+				t.Bids = append(t.Bids[:i], t.Bids[i+1:]...)
+				break
+			}
+		}
 	}
+
+	return filled
+}
+
+// restoreCancelledAsks undoes the asks among leftBook that left the book
+// without a single trade: the bids they sold are put back, since the position
+// is still open, and their threshold steps are reverted. It returns the other
+// asks.
+func (t *Trader) restoreCancelledAsks(leftBook []*Order) []*Order {
+	filled := make([]*Order, 0, len(leftBook))
+
+	for _, ask := range leftBook {
+		if !t.cancelledWithoutFill(ask) {
+			filled = append(filled, ask)
+			continue
+		}
+
+		t.undoThresholds(ask)
+
+		if ask.Bid != nil {
+			t.Bids = append(t.Bids, ask.Bid)
+		}
+	}
+
+	return filled
+}
+
+// cancelledWithoutFill tells whether an order that left the book did so
+// without a single trade, which only orders with an Execution can do.
+func (t *Trader) cancelledWithoutFill(order *Order) bool {
+	if order.Execution == "" || strings.HasPrefix(order.Id, "sim") {
+		return false
+	}
+
+	trades, err := t.Market.GetOrderTrades(order)
+	if err != nil || len(trades) > 0 {
+		return false
+	}
+
+	t.logCancelled(order, "left the book without a fill")
+
+	return true
+}
+
+func (t *Trader) logCancelled(order *Order, reason string) {
+	t.logf(`market=%s evt=cancelled id=%s type=%s execution=%s msg="%s"`+"\n",
+		t.Market.GetName(), order.Id, order.Type, order.Execution, reason)
+}
+
+func findFilledOrders(pendingOrders, orders []*Order) (filledOrders []*Order) {
+	for _, order := range orders {
+		pending := false
+
+		for _, p := range pendingOrders {
+			if order.Id == p.Id {
+				pending = true
+				break
+			}
+		}
+
+		if !pending {
+			filledOrders = append(filledOrders, order)
+		}
+	}
+
+	return filledOrders
 }
 
 func removeFilledAsks(pendingOrders, staleAsks []*Order) (freshAsks []*Order) {
@@ -260,6 +387,12 @@ func removeFilledAsks(pendingOrders, staleAsks []*Order) (freshAsks []*Order) {
 	for _, ask := range staleAsks {
 		for _, order := range pendingOrders {
 			if ask.Id == order.Id {
+				// the exchange knows what is left of the order, the trader
+				// knows how it was placed and which bid it sells
+				order.Execution = ask.Execution
+				order.Bid = ask.Bid
+				order.BuyThresholdStep = ask.BuyThresholdStep
+				order.SellThresholdStep = ask.SellThresholdStep
 				freshAsks = append(freshAsks, order)
 				break
 			}
@@ -269,6 +402,52 @@ func removeFilledAsks(pendingOrders, staleAsks []*Order) (freshAsks []*Order) {
 	return freshAsks
 }
 
+// RecordFills writes the trades of each filled order to the ledger. Orders
+// that cannot be looked up on the exchange are logged and skipped so a single
+// bad order does not stop the trader.
+func (t *Trader) RecordFills(orders []*Order) error {
+	if t.Ledger == nil {
+		return nil
+	}
+
+	for _, order := range orders {
+		trades, err := t.fillTrades(order)
+
+		if err != nil {
+			t.logf(`market=%s evt=error msg="%s" order=%s`+"\n", t.Market.GetName(), err.Error(), order.Id)
+			continue
+		}
+
+		err = t.Ledger.Record(trades...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Simulated orders never reach the exchange, so their fill is assumed to be
+// the whole order at the order price with the estimated fee.
+func (t *Trader) fillTrades(order *Order) ([]*Trade, error) {
+	if !strings.HasPrefix(order.Id, "sim") {
+		return t.Market.GetOrderTrades(order)
+	}
+
+	return []*Trade{&Trade{
+		Id:       order.Id + "-" + order.Type,
+		OrderId:  order.Id,
+		Market:   t.Market.GetName(),
+		Date:     t.now().Unix(),
+		Type:     order.Type,
+		Price:    order.Price,
+		Amount:   order.Amount,
+		Total:    order.Total,
+		Fee:      order.Total.MulFloat(t.EstimatedFee),
+		Exchange: "simulated",
+	}}, nil
+}
+
 func (t *Trader) Buy(marketData *MarketData) (order *Order, err error) {
 	if !t.ShouldBuy(marketData) {
 		return nil, nil
@@ -276,26 +455,34 @@ func (t *Trader) Buy(marketData *MarketData) (order *Order, err error) {
 
 	order = t.BuildBuyOrder(marketData)
 
-	if !t.CanBuy(order) {
+	if !t.CanBuy(order) || !t.IsValidOrder(order) {
 		return nil, nil
 	}
 
 	if t.Config.Simulate {
-		order.Id = fmt.Sprintf("sim%d", time.Now().Unix())
+		order.Id = fmt.Sprintf("sim%d", t.now().Unix())
 	} else {
 		err = t.Market.Buy(order)
+		if cancelled, ok := err.(*OrderCancelledError); ok {
+			t.logCancelled(order, cancelled.Reason)
+			return nil, nil
+		}
+
 		if err != nil {
 			return order, err
 		}
 	}
 
+	t.journalOrder(order, marketData)
 	t.Bids = append(t.Bids, order)
 
 	if t.BuyThreshold > t.Config.BuyThresholdMin {
-		t.BuyThreshold -= t.Config.BuyThresholdIncrement
+		order.BuyThresholdStep = -t.Config.BuyThresholdIncrement
 	}
 
-	t.SellThreshold += t.Config.SellThresholdIncrement
+	order.SellThresholdStep = t.Config.SellThresholdIncrement
+
+	t.stepThresholds(order)
 
 	return order, nil
 }
@@ -306,20 +493,44 @@ func (t *Trader) ShouldBuy(marketData *MarketData) bool {
 }
 
 func (t *Trader) CanBuy(order *Order) bool {
-	tradeValue := order.Price * order.Amount * (1 + t.EstimatedFee)
+	total := order.Price.Mul(order.Amount)
+	tradeValue := total + total.MulFloat(t.EstimatedFee)
 	return t.BTC_Balance.Available >= tradeValue
 }
 
 func (t *Trader) BuildBuyOrder(marketData *MarketData) *Order {
-	desiredPrice := marketData.CurrentPrice * (1 - t.EstimatedFee)
-	altAmount := t.BTC_BuyAmount / desiredPrice
+	desiredPrice := data.NewDecimal(marketData.CurrentPrice * (1 - t.EstimatedFee))
+	altAmount := data.NewDecimal(t.BTC_BuyAmount).Div(desiredPrice)
 
-	return &Order{
-		Type:   "buy",
-		Price:  desiredPrice,
-		Amount: altAmount,
-		Total:  desiredPrice * altAmount,
+	order := &Order{
+		Type:      "buy",
+		Price:     desiredPrice,
+		Amount:    altAmount,
+		Total:     desiredPrice.Mul(altAmount),
+		Execution: t.BuyExecution,
 	}
+
+	if t.Info != nil {
+		t.Info.Round(order)
+	}
+
+	return order
+}
+
+// IsValidOrder logs why the market would reject order, if it would.
+func (t *Trader) IsValidOrder(order *Order) bool {
+	if t.Info == nil {
+		return true
+	}
+
+	err := t.Info.Validate(order)
+	if err != nil {
+		t.logf(`market=%s evt=skip_order type=%s price=%s amount=%s total=%s reason="%s"`+"\n",
+			t.Market.GetName(), order.Type, order.Price, order.Amount, order.Total, err.Error())
+		return false
+	}
+
+	return true
 }
 
 func (t *Trader) Sell(marketData *MarketData) (order *Order, err error) {
@@ -329,33 +540,95 @@ func (t *Trader) Sell(marketData *MarketData) (order *Order, err error) {
 
 	order = t.BuildSellOrder(marketData)
 
-	if !t.CanSell(order) {
+	if !t.CanSell(order) || !t.IsValidOrder(order) {
 		return nil, nil
 	}
 
 	if t.Config.Simulate {
-		order.Id = fmt.Sprintf("sim%d", time.Now().Unix())
+		order.Id = fmt.Sprintf("sim%d", t.now().Unix())
 	} else {
 		err = t.Market.Sell(order)
+		if cancelled, ok := err.(*OrderCancelledError); ok {
+			t.logCancelled(order, cancelled.Reason)
+			return nil, nil
+		}
+
 		if err != nil {
 			return order, err
 		}
 	}
 
-	t.Bids = removeLastFilledBid(t.Bids)
+	t.journalOrder(order, marketData)
+	t.Bids, order.Bid = removeLastFilledBid(t.Bids)
+	t.Asks = append(t.Asks, order)
 
 	if t.BuyThreshold < t.Config.BuyThresholdMax {
-		t.BuyThreshold += t.Config.BuyThresholdIncrement
+		order.BuyThresholdStep = t.Config.BuyThresholdIncrement
 	}
 
 	if t.SellThreshold > t.Config.ProfitFactor {
-		t.SellThreshold -= t.Config.SellThresholdIncrement
+		order.SellThresholdStep = -t.Config.SellThresholdIncrement
 	}
 
+	t.stepThresholds(order)
+
 	return order, nil
 }
 
-func removeLastFilledBid(bids []*Order) []*Order {
+// journalOrder must be called before the thresholds and bids are updated for
+// the order.
+func (t *Trader) journalOrder(order *Order, marketData *MarketData) {
+	if t.Journal == nil {
+		return
+	}
+
+	lastBidPrice := 0.0
+	for _, bid := range t.Bids {
+		if bid.Filled {
+			lastBidPrice = bid.Price.Float64()
+		}
+	}
+
+	indicators := make(map[string]float64)
+	for name, value := range marketData.Indicators {
+		indicators[name] = value
+	}
+
+	t.Journal.Record(&JournalOrder{
+		Date:              t.now().Unix(),
+		Market:            t.Market.GetName(),
+		OrderId:           order.Id,
+		Type:              order.Type,
+		Price:             order.Price,
+		Amount:            order.Amount,
+		Total:             order.Total,
+		CurrentPrice:      marketData.CurrentPrice,
+		BuyThreshold:      t.BuyThreshold,
+		BuyThresholdPrice: marketData.Percentiles[t.BuyThreshold],
+		SellThreshold:     t.SellThreshold,
+		LastBidPrice:      lastBidPrice,
+		VolatilityIndex:   marketData.VolatilityIndex,
+		VolatilityFactor:  t.VolatilityFactor,
+		Indicators:        indicators,
+	})
+}
+
+// stepThresholds applies the threshold steps of an order that was placed.
+func (t *Trader) stepThresholds(order *Order) {
+	t.BuyThreshold += order.BuyThresholdStep
+	t.SellThreshold += order.SellThresholdStep
+}
+
+// undoThresholds reverts the threshold steps of an order that was cancelled
+// without a fill, so they do not drift with every cancelled order.
+func (t *Trader) undoThresholds(order *Order) {
+	t.BuyThreshold -= order.BuyThresholdStep
+	t.SellThreshold -= order.SellThresholdStep
+}
+
+// removeLastFilledBid removes the most recent filled bid, which is the one a
+// sell closes, and returns it.
+func removeLastFilledBid(bids []*Order) ([]*Order, *Order) {
 	index := -1
 
 	for i, bid := range bids {
@@ -364,7 +637,9 @@ func removeLastFilledBid(bids []*Order) []*Order {
 		}
 	}
 
-	return append(bids[:index], bids[index+1:]...)
+	removed := bids[index]
+
+	return append(bids[:index], bids[index+1:]...), removed
 }
 
 func (t *Trader) ShouldSell(marketData *MarketData) bool {
@@ -380,19 +655,23 @@ func (t *Trader) ShouldSell(marketData *MarketData) bool {
 		return false
 	}
 
-	return marketData.CurrentPrice > lastTrade.Price*t.SellThreshold
+	return marketData.CurrentPrice > lastTrade.Price.Float64()*t.SellThreshold
 }
 
 func (t *Trader) BuildSellOrder(marketData *MarketData) *Order {
-	order := &Order{Type: "sell"}
-	order.Amount = t.ALT_Balance.Available * t.ALT_SellRatio
-	order.Price = marketData.CurrentPrice
+	order := &Order{Type: "sell", Execution: t.SellExecution}
+	order.Amount = t.ALT_Balance.Available.MulFloat(t.ALT_SellRatio)
+	order.Price = data.NewDecimal(marketData.CurrentPrice)
 
-	if order.Amount*order.Price < t.BTC_BuyAmount {
+	if order.Price.Mul(order.Amount) < data.NewDecimal(t.BTC_BuyAmount) {
 		order.Amount = t.ALT_Balance.Available
 	}
 
-	order.Total = order.Price * order.Amount
+	order.Total = order.Price.Mul(order.Amount)
+
+	if t.Info != nil {
+		t.Info.Round(order)
+	}
 
 	return order
 }
@@ -402,17 +681,31 @@ func (t *Trader) CanSell(order *Order) bool {
 }
 
 func (t *Trader) LoadMarketData() (marketData *MarketData, err error) {
-	endTime := time.Now().Unix()
-	startTime := endTime - t.TimeWindow
+	marketData = &MarketData{}
 
-	summaryData, err := t.Market.GetSummaryData(startTime, endTime)
+	rolling, ok := t.Market.(RollingMarket)
+	if ok {
+		marketData.Percentiles, marketData.Indicators, ok = rolling.RollingData(t.TimeWindow)
+	}
 
-	if err != nil {
-		return nil, err
+	if !ok {
+		endTime := t.now().Unix()
+		startTime := endTime - t.TimeWindow
+
+		summaryData, err := t.Market.GetSummaryData(startTime, endTime)
+
+		if err != nil {
+			return nil, err
+		}
+
+		marketData.Percentiles, err = calculatePercentiles(summaryData)
+		if err != nil {
+			return nil, err
+		}
+
+		marketData.Indicators = calculateIndicators(summaryData)
 	}
 
-	marketData = &MarketData{}
-	marketData.Percentiles = calculatePercentiles(summaryData)
 	marketData.VolatilityIndex = marketData.Percentiles[t.Config.VolatilityIndexUpperPercentile] / marketData.Percentiles[t.Config.VolatilityIndexLowerPercentile]
 
 	currentPrice, err := t.Market.GetCurrentPrice()
@@ -427,20 +720,9 @@ func (t *Trader) LoadMarketData() (marketData *MarketData, err error) {
 }
 
 func (t *Trader) LoadState() error {
-	traderState := &TraderState{}
-
-	err, hasData := t.DB.HasData(t.StateKey)
-	if err != nil {
-		return err
-	}
-
-	if !hasData {
+	traderState, err := NewTraderStateRepository(t.DB).Load(t.StateKey)
+	if err != nil || traderState == nil {
 		// No state to load when first run of a new currency
-		return nil
-	}
-
-	err = t.DB.Read(t.StateKey, &traderState)
-	if err != nil {
 		return err
 	}
 
@@ -453,7 +735,7 @@ func (t *Trader) LoadState() error {
 }
 
 func (t *Trader) SaveState() error {
-	return t.DB.Write(t.StateKey, &TraderState{
+	return NewTraderStateRepository(t.DB).Save(t.StateKey, &TraderState{
 		BuyThreshold:  t.BuyThreshold,
 		SellThreshold: t.SellThreshold,
 		Bids:          t.Bids,
@@ -461,28 +743,36 @@ func (t *Trader) SaveState() error {
 	})
 }
 
-func getSortedAverages(summaryData []*SummaryData) []float64 {
-	sortedAverages := make([]float64, 0, len(summaryData))
-
-	for _, data := range summaryData {
-		sortedAverages = append(sortedAverages, data.WeightedAverage)
+func (t *Trader) now() time.Time {
+	if t.Clock == nil {
+		return time.Now()
 	}
 
-	sort.Float64s(sortedAverages)
+	return t.Clock()
+}
+
+func (t *Trader) logf(format string, v ...interface{}) {
+	if t.Logger == nil {
+		log.Printf(format, v...)
+		return
+	}
 
-	return sortedAverages
+	t.Logger.Printf(format, v...)
 }
 
-func calculatePercentiles(summaryData []*SummaryData) []float64 {
-	percentiles := make([]float64, 101)
-	sortedAverages := getSortedAverages(summaryData)
+// calculatePercentiles returns the percentiles of the weighted averages. To
+// follow a window over time without recomputing it, use RollingPercentiles.
+// Without candles every percentile would be zero, so that is an error.
+func calculatePercentiles(summaryData []*SummaryData) ([]float64, error) {
+	if len(summaryData) == 0 {
+		return nil, fmt.Errorf("no chart data in the time window")
+	}
 
-	percentiles[0] = 0.0
-	percentiles[100] = sortedAverages[len(sortedAverages)-1]
+	window := NewRollingPercentiles(0)
 
-	for i := 1; i < 100; i += 1 {
-		percentiles[i] = sortedAverages[i*len(sortedAverages)/100]
+	for _, data := range summaryData {
+		window.Add(data.Date, data.WeightedAverage)
 	}
 
-	return percentiles
+	return window.Percentiles(), nil
 }
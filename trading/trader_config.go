@@ -0,0 +1,98 @@
+package trading
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jbgo/sftbot/db"
+	"io/ioutil"
+	"sort"
+)
+
+// TraderConfigRepository keeps named trader configs in the database, so a
+// config can be referred to by name instead of by file.
+type TraderConfigRepository struct {
+	DB db.Store
+}
+
+func NewTraderConfigRepository(dbStore db.Store) *TraderConfigRepository {
+	return &TraderConfigRepository{DB: dbStore}
+}
+
+func (repo *TraderConfigRepository) Load(name string) (*TraderConfig, error) {
+	err, hasData := repo.DB.HasData(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasData {
+		return nil, fmt.Errorf("no trader config named %s", name)
+	}
+
+	config := &TraderConfig{}
+	err = repo.DB.Read(name, config)
+
+	return config, err
+}
+
+func (repo *TraderConfigRepository) Save(name string, config *TraderConfig) error {
+	return repo.DB.Write(name, config)
+}
+
+func (repo *TraderConfigRepository) Names() ([]string, error) {
+	names, err := repo.DB.Keys()
+	sort.Strings(names)
+	return names, err
+}
+
+// LoadTraderConfigFile reads a trader config from a JSON file.
+func LoadTraderConfigFile(path string) (*TraderConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &TraderConfig{}
+	err = json.Unmarshal(data, config)
+
+	return config, err
+}
+
+// Validate checks that every percentile the trader can look up exists. The buy
+// threshold steps by BuyThresholdIncrement while it is above BuyThresholdMin
+// or below BuyThresholdMax, so it can end up one step short of either bound.
+func (config *TraderConfig) Validate() error {
+	if config.TimeWindow <= 0 {
+		return fmt.Errorf("TimeWindow must be positive: %d", config.TimeWindow)
+	}
+
+	if config.BuyThresholdIncrement < 0 {
+		return fmt.Errorf("BuyThresholdIncrement must not be negative: %d", config.BuyThresholdIncrement)
+	}
+
+	if !ValidOrderExecution(config.BuyExecution) {
+		return fmt.Errorf("unknown BuyExecution: %s", config.BuyExecution)
+	}
+
+	if !ValidOrderExecution(config.SellExecution) {
+		return fmt.Errorf("unknown SellExecution: %s", config.SellExecution)
+	}
+
+	percentiles := []struct {
+		name  string
+		value int64
+	}{
+		{"BuyThresholdStart", config.BuyThresholdStart},
+		{"lowest buy threshold", config.BuyThresholdMin - config.BuyThresholdIncrement + 1},
+		{"highest buy threshold", config.BuyThresholdMax + config.BuyThresholdIncrement - 1},
+		{"VolatilityIndexUpperPercentile", config.VolatilityIndexUpperPercentile},
+		{"VolatilityIndexLowerPercentile", config.VolatilityIndexLowerPercentile},
+	}
+
+	for _, p := range percentiles {
+		if p.value < 0 || p.value > 100 {
+			return fmt.Errorf("%s must be a percentile between 0 and 100: %d", p.name, p.value)
+		}
+	}
+
+	return nil
+}
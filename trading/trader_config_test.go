@@ -0,0 +1,60 @@
+package trading
+
+import (
+	"github.com/jbgo/sftbot/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTraderConfigRepository(t *testing.T) {
+	dbStore, err := db.NewBoltStore("trader_config_test", "test.db")
+	require.Nil(t, err)
+
+	configs := NewTraderConfigRepository(dbStore)
+
+	_, err = configs.Load("missing")
+	assert.NotNil(t, err)
+
+	config := DefaultTraderConfig()
+	config.ProfitFactor = 1.1
+
+	require.Nil(t, configs.Save("aggressive", config))
+	require.Nil(t, configs.Save("default", DefaultTraderConfig()))
+
+	loaded, err := configs.Load("aggressive")
+	require.Nil(t, err)
+	assert.Equal(t, config, loaded)
+
+	names, err := configs.Names()
+	require.Nil(t, err)
+	assert.Equal(t, []string{"aggressive", "default"}, names)
+}
+
+func TestTraderConfigValidate(t *testing.T) {
+	assert.Nil(t, DefaultTraderConfig().Validate())
+
+	config := DefaultTraderConfig()
+	config.BuyThresholdMax = 100
+	assert.NotNil(t, config.Validate())
+
+	config = DefaultTraderConfig()
+	config.BuyThresholdMin = 0
+	assert.NotNil(t, config.Validate())
+
+	config = DefaultTraderConfig()
+	config.VolatilityIndexUpperPercentile = 101
+	assert.NotNil(t, config.Validate())
+
+	config = DefaultTraderConfig()
+	config.TimeWindow = 0
+	assert.NotNil(t, config.Validate())
+
+	config = DefaultTraderConfig()
+	config.BuyExecution = ORDER_POST_ONLY
+	config.SellExecution = ORDER_IMMEDIATE_OR_CANCEL
+	assert.Nil(t, config.Validate())
+
+	config.SellExecution = "stop"
+	assert.NotNil(t, config.Validate())
+}
@@ -0,0 +1,32 @@
+package trading
+
+import (
+	"github.com/jbgo/sftbot/db"
+)
+
+// TraderStateRepository persists the TraderState of each trader under its
+// state key.
+type TraderStateRepository struct {
+	DB db.Store
+}
+
+func NewTraderStateRepository(dbStore db.Store) *TraderStateRepository {
+	return &TraderStateRepository{DB: dbStore}
+}
+
+// Load returns nil when nothing has been saved under key yet.
+func (repo *TraderStateRepository) Load(key string) (*TraderState, error) {
+	err, hasData := repo.DB.HasData(key)
+	if err != nil || !hasData {
+		return nil, err
+	}
+
+	state := &TraderState{}
+	err = repo.DB.Read(key, state)
+
+	return state, err
+}
+
+func (repo *TraderStateRepository) Save(key string, state *TraderState) error {
+	return repo.DB.Write(key, state)
+}
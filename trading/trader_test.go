@@ -1,9 +1,12 @@
 package trading
 
 import (
+	"bytes"
+	"github.com/jbgo/sftbot/data"
 	"github.com/jbgo/sftbot/db"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"log"
 	"testing"
 )
 
@@ -59,14 +62,14 @@ func TestTrader(t *testing.T) {
 	})
 
 	t.Run("CanBuy", func(t *testing.T) {
-		btcBalance := &Balance{Available: 2.5}
+		btcBalance := &Balance{Available: data.NewDecimal(2.5)}
 		trader := &Trader{BTC_Balance: btcBalance}
 		trader.EstimatedFee = 0.0025
-		order := &Order{Price: 0.025, Amount: 100}
+		order := &Order{Price: data.NewDecimal(0.025), Amount: data.NewDecimal(100)}
 
 		assert.False(t, trader.CanBuy(order))
 
-		btcBalance.Available = 2.51
+		btcBalance.Available = data.NewDecimal(2.51)
 
 		assert.True(t, trader.CanBuy(order))
 	})
@@ -78,9 +81,49 @@ func TestTrader(t *testing.T) {
 		order := trader.BuildBuyOrder(marketData)
 
 		assert.Equal(t, "buy", order.Type)
-		assert.InDelta(t, 0.0039004, order.Price, 0.00000001)
-		assert.InDelta(t, 3.2047995, order.Amount, 0.00000001)
-		assert.InDelta(t, 0.0125, order.Total, 0.0001)
+		assert.Equal(t, data.NewDecimal(0.0039004), order.Price)
+		assert.Equal(t, data.NewDecimal(3.20479951), order.Amount)
+		assert.Equal(t, data.NewDecimal(0.0125), order.Total)
+		assert.Equal(t, "", order.Execution)
+
+		trader.BuyExecution = ORDER_POST_ONLY
+		assert.Equal(t, ORDER_POST_ONLY, trader.BuildBuyOrder(marketData).Execution)
+	})
+
+	t.Run("BuildBuyOrder rounds to the market's precision", func(t *testing.T) {
+		trader := Trader{BTC_BuyAmount: 0.0125, EstimatedFee: 0.005, Info: NewPlxMarketInfo()}
+		marketData := &MarketData{CurrentPrice: 0.003920001234}
+
+		order := trader.BuildBuyOrder(marketData)
+
+		assert.Equal(t, data.NewDecimal(0.00390040), order.Price)
+		assert.Equal(t, data.NewDecimal(3.20479951), order.Amount)
+		assert.Equal(t, order.Price.Mul(order.Amount), order.Total)
+	})
+
+	t.Run("orders the market would reject are skipped", func(t *testing.T) {
+		var logs bytes.Buffer
+
+		market := &FakeMarket{Name: "BTC_ABC", ExistsValue: true, Info: NewPlxMarketInfo()}
+		exchange := &FakeExchange{Market: market}
+
+		trader, err := NewTrader("BTC_ABC", exchange, dbStore, traderConfig)
+		require.Nil(t, err)
+
+		trader.Logger = log.New(&logs, "", 0)
+		trader.BTC_BuyAmount = 0.00005
+		trader.BTC_Balance = &Balance{Available: data.NewDecimal(1.0)}
+		trader.BuyThreshold = 50
+
+		percentiles := make([]float64, 101)
+		percentiles[50] = 0.2
+
+		order, err := trader.Buy(&MarketData{CurrentPrice: 0.1, Percentiles: percentiles, VolatilityIndex: 2.0})
+		require.Nil(t, err)
+		assert.Nil(t, order)
+		assert.Empty(t, trader.Bids)
+		assert.Contains(t, logs.String(), "evt=skip_order type=buy")
+		assert.Contains(t, logs.String(), "below the minimum")
 	})
 
 	t.Run("LoadMarketData", func(t *testing.T) {
@@ -123,8 +166,8 @@ func TestTrader(t *testing.T) {
 		trader := Trader{
 			SellThreshold: 1.06,
 			Bids: []*Order{
-				&Order{Price: 0.1, Filled: true},
-				&Order{Price: 0.01, Filled: false},
+				&Order{Price: data.NewDecimal(0.1), Filled: true},
+				&Order{Price: data.NewDecimal(0.01), Filled: false},
 			},
 		}
 
@@ -154,23 +197,32 @@ func TestTrader(t *testing.T) {
 
 		trader.ALT_SellRatio = 0.5
 		trader.EstimatedFee = 0.005
-		trader.ALT_Balance = &Balance{Available: 100.0}
+		trader.ALT_Balance = &Balance{Available: data.NewDecimal(100.0)}
 
 		order := trader.BuildSellOrder(marketData)
 
 		assert.Equal(t, "sell", order.Type)
-		assert.Equal(t, 50.0, order.Amount)
-		assert.InDelta(t, 0.107, order.Price, 0.00000001)
-		assert.InDelta(t, 5.35, order.Total, 0.00001)
+		assert.Equal(t, data.NewDecimal(50.0), order.Amount)
+		assert.Equal(t, data.NewDecimal(0.107), order.Price)
+		assert.Equal(t, data.NewDecimal(5.35), order.Total)
 
 		// Test minimum sell amount
 		trader.BTC_BuyAmount = 0.1
 		marketData.CurrentPrice = 0.025
-		trader.ALT_Balance.Available = 6.0
+		trader.ALT_Balance.Available = data.NewDecimal(6.0)
 
 		order = trader.BuildSellOrder(marketData)
 
-		assert.InDelta(t, 6.0, order.Amount, 0.001)
+		assert.Equal(t, data.NewDecimal(6.0), order.Amount)
+
+		config := *traderConfig
+		config.SellExecution = ORDER_IMMEDIATE_OR_CANCEL
+		trader, err = NewTrader("BTC_ABC", exchange, dbStore, &config)
+		require.Nil(t, err)
+		trader.ALT_Balance = &Balance{Available: data.NewDecimal(100.0)}
+
+		order = trader.BuildSellOrder(marketData)
+		assert.Equal(t, ORDER_IMMEDIATE_OR_CANCEL, order.Execution)
 	})
 
 	t.Run("CanSell", func(t *testing.T) {
@@ -178,13 +230,13 @@ func TestTrader(t *testing.T) {
 		order := &Order{}
 		trader := &Trader{ALT_Balance: altBalance}
 
-		altBalance.Available = 10.0
-		order.Amount = 10.0
+		altBalance.Available = data.NewDecimal(10.0)
+		order.Amount = data.NewDecimal(10.0)
 
 		assert.True(t, trader.CanSell(order))
 
-		altBalance.Available = 9.99
-		order.Amount = 10.0
+		altBalance.Available = data.NewDecimal(9.99)
+		order.Amount = data.NewDecimal(10.0)
 
 		assert.False(t, trader.CanSell(order))
 	})
@@ -196,8 +248,8 @@ func TestTrader(t *testing.T) {
 		}
 
 		balances := map[string]*Balance{
-			"BTC": &Balance{Available: 1.23},
-			"XYZ": &Balance{Available: 142.73},
+			"BTC": &Balance{Available: data.NewDecimal(1.23)},
+			"XYZ": &Balance{Available: data.NewDecimal(142.73)},
 		}
 
 		exchange := &FakeExchange{
@@ -213,6 +265,14 @@ func TestTrader(t *testing.T) {
 
 		assert.Equal(t, balances["BTC"].Available, trader.BTC_Balance.Available)
 		assert.Equal(t, balances["XYZ"].Available, trader.ALT_Balance.Available)
+
+		market.Name = "USDT_XYZ"
+		balances["USDT"] = &Balance{Available: data.NewDecimal(2500.0)}
+
+		err = trader.LoadBalances()
+		require.Nil(t, err)
+
+		assert.Equal(t, data.NewDecimal(2500.0), trader.BTC_Balance.Available)
 	})
 
 	t.Run("Reconcile", func(t *testing.T) {
@@ -223,17 +283,17 @@ func TestTrader(t *testing.T) {
 		require.Nil(t, err)
 
 		trader.Bids = []*Order{
-			&Order{Id: "foo", Price: 0.24, Filled: false},
-			&Order{Id: "bar", Price: 0.19, Filled: false},
-			&Order{Id: "baz", Price: 0.27, Filled: false},
+			&Order{Id: "foo", Price: data.NewDecimal(0.24), Filled: false},
+			&Order{Id: "bar", Price: data.NewDecimal(0.19), Filled: false},
+			&Order{Id: "baz", Price: data.NewDecimal(0.27), Filled: false},
 		}
 
 		trader.Asks = []*Order{
-			&Order{Id: "boggle", Price: 0.29},
+			&Order{Id: "boggle", Price: data.NewDecimal(0.29)},
 		}
 
 		market.PendingOrders = []*Order{
-			&Order{Id: "baz", Price: 0.27},
+			&Order{Id: "baz", Price: data.NewDecimal(0.27)},
 		}
 
 		err = trader.Reconcile()
@@ -255,6 +315,162 @@ func TestTrader(t *testing.T) {
 		assert.Equal(t, 0, len(trader.Asks))
 	})
 
+	t.Run("Reconcile drops cancelled bids", func(t *testing.T) {
+		market := &FakeMarket{Name: "BTC_XYZ", ExistsValue: true}
+		exchange := &FakeExchange{Market: market}
+
+		trader, err := NewTrader(market.Name, exchange, dbStore, traderConfig)
+		require.Nil(t, err)
+
+		trader.Bids = []*Order{
+			&Order{Id: "foo", Execution: ORDER_IMMEDIATE_OR_CANCEL},
+			&Order{Id: "bar", Execution: ORDER_POST_ONLY},
+			&Order{Id: "baz"},
+		}
+
+		market.OrderTrades = map[string][]*Trade{
+			"bar": []*Trade{&Trade{Id: "1", Market: "BTC_XYZ", Date: 100}},
+		}
+
+		err = trader.Reconcile()
+		require.Nil(t, err)
+
+		require.Equal(t, 2, len(trader.Bids))
+		assert.Equal(t, "bar", trader.Bids[0].Id)
+		assert.True(t, trader.Bids[0].Filled)
+		assert.Equal(t, "baz", trader.Bids[1].Id)
+		assert.True(t, trader.Bids[1].Filled)
+	})
+
+	t.Run("Reconcile reverts the thresholds of cancelled orders", func(t *testing.T) {
+		market := &FakeMarket{Name: "BTC_XYZ", ExistsValue: true}
+		exchange := &FakeExchange{Market: market}
+
+		trader, err := NewTrader(market.Name, exchange, dbStore, traderConfig)
+		require.Nil(t, err)
+
+		trader.BuyExecution = ORDER_POST_ONLY
+		trader.SellExecution = ORDER_IMMEDIATE_OR_CANCEL
+		trader.BTC_Balance = &Balance{Available: data.NewDecimal(0.25)}
+		trader.ALT_Balance = &Balance{Available: data.NewDecimal(400.0)}
+
+		marketData := &MarketData{VolatilityIndex: 1.03, Percentiles: make([]float64, 100), CurrentPrice: 0.05}
+		marketData.Percentiles[50] = 0.06
+
+		bid, err := trader.Buy(marketData)
+		require.Nil(t, err)
+		require.NotNil(t, bid)
+		assert.Equal(t, int64(48), trader.BuyThreshold)
+		assert.Equal(t, 1.07, trader.SellThreshold)
+
+		err = trader.Reconcile()
+		require.Nil(t, err)
+
+		assert.Equal(t, 0, len(trader.Bids))
+		assert.Equal(t, int64(50), trader.BuyThreshold)
+		assert.Equal(t, 1.06, trader.SellThreshold)
+
+		trader.Bids = []*Order{&Order{Id: "foo", Price: data.NewDecimal(0.04), Filled: true}}
+		trader.BuyThreshold = 42
+		trader.SellThreshold = 1.08
+
+		ask, err := trader.Sell(marketData)
+		require.Nil(t, err)
+		require.NotNil(t, ask)
+		assert.Equal(t, int64(44), trader.BuyThreshold)
+		assert.InDelta(t, 1.07, trader.SellThreshold, 0.0000001)
+
+		err = trader.Reconcile()
+		require.Nil(t, err)
+
+		assert.Equal(t, 1, len(trader.Bids))
+		assert.Equal(t, int64(42), trader.BuyThreshold)
+		assert.InDelta(t, 1.08, trader.SellThreshold, 0.0000001)
+	})
+
+	t.Run("Reconcile restores the bid of a cancelled ask", func(t *testing.T) {
+		market := &FakeMarket{Name: "BTC_XYZ", ExistsValue: true}
+		exchange := &FakeExchange{Market: market}
+
+		trader, err := NewTrader(market.Name, exchange, dbStore, traderConfig)
+		require.Nil(t, err)
+
+		trader.SellExecution = ORDER_IMMEDIATE_OR_CANCEL
+		trader.ALT_Balance = &Balance{Available: data.NewDecimal(400.0)}
+
+		cancelledBid := &Order{Id: "cancelled", Price: data.NewDecimal(0.04), Filled: true}
+		filledBid := &Order{Id: "filled", Price: data.NewDecimal(0.04), Filled: true}
+		marketData := &MarketData{CurrentPrice: 0.05}
+
+		trader.Bids = []*Order{filledBid}
+		filledAsk, err := trader.Sell(marketData)
+		require.Nil(t, err)
+		require.NotNil(t, filledAsk)
+
+		trader.Bids = []*Order{cancelledBid}
+		cancelledAsk, err := trader.Sell(marketData)
+		require.Nil(t, err)
+		require.NotNil(t, cancelledAsk)
+
+		assert.Equal(t, 0, len(trader.Bids))
+		assert.Equal(t, 2, len(trader.Asks))
+
+		market.OrderTrades = map[string][]*Trade{
+			filledAsk.Id: []*Trade{&Trade{Id: "1", Market: "BTC_XYZ", Date: 100}},
+		}
+
+		err = trader.Reconcile()
+		require.Nil(t, err)
+
+		require.Equal(t, 1, len(trader.Bids))
+		assert.Equal(t, "cancelled", trader.Bids[0].Id)
+		assert.True(t, trader.Bids[0].Filled)
+		assert.Equal(t, 0, len(trader.Asks))
+	})
+
+	t.Run("Reconcile records fills", func(t *testing.T) {
+		market := &FakeMarket{Name: "BTC_XYZ", ExistsValue: true}
+		exchange := &FakeExchange{Market: market}
+
+		ledgerStore, err := db.NewBoltStore("trader_test_ledger", "test.db")
+		require.Nil(t, err)
+		ledgerStore.Delete("BTC_XYZ")
+
+		trader, err := NewTrader(market.Name, exchange, dbStore, traderConfig)
+		require.Nil(t, err)
+
+		trader.Ledger = NewLedger(ledgerStore)
+
+		trader.Bids = []*Order{
+			&Order{Id: "foo", Type: "buy", Filled: true},
+			&Order{Id: "bar", Type: "buy", Filled: false},
+			&Order{Id: "sim123", Type: "buy", Price: data.NewDecimal(0.1), Amount: data.NewDecimal(2.0), Total: data.NewDecimal(0.2), Filled: false},
+		}
+
+		trader.Asks = []*Order{
+			&Order{Id: "baz", Type: "sell"},
+		}
+
+		market.OrderTrades = map[string][]*Trade{
+			"foo": []*Trade{&Trade{Id: "1", Market: "BTC_XYZ", Date: 100}},
+			"bar": []*Trade{&Trade{Id: "2", Market: "BTC_XYZ", Date: 200}},
+			"baz": []*Trade{&Trade{Id: "3", Market: "BTC_XYZ", Date: 300}},
+		}
+
+		err = trader.Reconcile()
+		require.Nil(t, err)
+
+		trades, err := trader.Ledger.Trades("BTC_XYZ")
+		require.Nil(t, err)
+
+		// "foo" was already filled, so only new fills are recorded
+		require.Equal(t, 3, len(trades))
+		assert.Equal(t, "2", trades[0].Id)
+		assert.Equal(t, "3", trades[1].Id)
+		assert.Equal(t, "sim123-buy", trades[2].Id)
+		assert.Equal(t, data.NewDecimal(0.2*trader.EstimatedFee), trades[2].Fee)
+	})
+
 	t.Run("LoadState+SaveState", func(t *testing.T) {
 		market := &FakeMarket{Name: "BTC_TESTING", ExistsValue: true}
 		exchange := &FakeExchange{Market: market}
@@ -270,11 +486,11 @@ func TestTrader(t *testing.T) {
 		trader.BuyThreshold = 36
 		trader.SellThreshold = 1.123
 		trader.Bids = []*Order{
-			&Order{Price: 0.1},
-			&Order{Price: 0.2},
+			&Order{Price: data.NewDecimal(0.1)},
+			&Order{Price: data.NewDecimal(0.2)},
 		}
 		trader.Asks = []*Order{
-			&Order{Price: 0.3},
+			&Order{Price: data.NewDecimal(0.3)},
 		}
 
 		err = trader.SaveState()
@@ -290,7 +506,7 @@ func TestTrader(t *testing.T) {
 		assert.Equal(t, 1.123, trader.SellThreshold)
 		assert.Equal(t, 2, len(trader.Bids))
 		assert.Equal(t, 1, len(trader.Asks))
-		assert.Equal(t, 0.2, trader.Bids[1].Price)
+		assert.Equal(t, data.NewDecimal(0.2), trader.Bids[1].Price)
 	})
 
 	t.Run("Buy", func(t *testing.T) {
@@ -300,7 +516,7 @@ func TestTrader(t *testing.T) {
 		trader, err := NewTrader(market.Name, exchange, dbStore, traderConfig)
 		require.Nil(t, err)
 
-		trader.BTC_Balance = &Balance{Available: 0.25}
+		trader.BTC_Balance = &Balance{Available: data.NewDecimal(0.25)}
 
 		marketData := &MarketData{}
 		marketData.VolatilityIndex = 1.03
@@ -349,7 +565,7 @@ func TestTrader(t *testing.T) {
 
 		t.Run("cannot buy", func(t *testing.T) {
 			marketData.CurrentPrice = 0.05
-			trader.BTC_Balance.Available = 0.001
+			trader.BTC_Balance.Available = data.NewDecimal(0.001)
 
 			order, err := trader.Buy(marketData)
 
@@ -361,7 +577,7 @@ func TestTrader(t *testing.T) {
 
 		t.Run("buy error", func(t *testing.T) {
 			market.TriggerBuyError = true
-			trader.BTC_Balance.Available = 0.1
+			trader.BTC_Balance.Available = data.NewDecimal(0.1)
 			marketData.CurrentPrice = 0.05
 
 			order, err := trader.Buy(marketData)
@@ -370,7 +586,21 @@ func TestTrader(t *testing.T) {
 			require.NotNil(t, order)
 			assert.Equal(t, "fake buy error", err.Error())
 			assert.Equal(t, 0, len(order.Id))
-			assert.Equal(t, 0.04975, order.Price)
+			assert.Equal(t, data.NewDecimal(0.04975), order.Price)
+		})
+
+		t.Run("buy cancelled by the exchange", func(t *testing.T) {
+			market.TriggerBuyError = false
+			market.TriggerCancel = true
+			bids := len(trader.Bids)
+
+			order, err := trader.Buy(marketData)
+
+			require.Nil(t, err)
+			require.Nil(t, order)
+			assert.Equal(t, bids, len(trader.Bids))
+			assert.Equal(t, int64(50), trader.BuyThreshold)
+			assert.Equal(t, 1.08, trader.SellThreshold)
 		})
 	})
 
@@ -388,7 +618,7 @@ func TestTrader(t *testing.T) {
 
 		t.Run("should not sell", func(t *testing.T) {
 			trader.Bids = []*Order{lastBid}
-			lastBid.Price = 0.053
+			lastBid.Price = data.NewDecimal(0.053)
 
 			order, err := trader.Sell(marketData)
 
@@ -400,8 +630,8 @@ func TestTrader(t *testing.T) {
 
 		t.Run("cannot sell", func(t *testing.T) {
 			trader.Bids = []*Order{lastBid}
-			trader.ALT_Balance.Available = 0.0
-			lastBid.Price = 0.04
+			trader.ALT_Balance.Available = 0
+			lastBid.Price = data.NewDecimal(0.04)
 
 			order, err := trader.Sell(marketData)
 
@@ -414,8 +644,8 @@ func TestTrader(t *testing.T) {
 		t.Run("sell error", func(t *testing.T) {
 			trader.Bids = []*Order{lastBid}
 			market.TriggerSellError = true
-			trader.ALT_Balance.Available = 400.0
-			lastBid.Price = 0.04
+			trader.ALT_Balance.Available = data.NewDecimal(400.0)
+			lastBid.Price = data.NewDecimal(0.04)
 
 			order, err := trader.Sell(marketData)
 
@@ -427,16 +657,32 @@ func TestTrader(t *testing.T) {
 			assert.Equal(t, 1, len(trader.Bids))
 		})
 
+		t.Run("sell cancelled by the exchange", func(t *testing.T) {
+			trader.Bids = []*Order{lastBid}
+			market.TriggerSellError = false
+			market.TriggerCancel = true
+
+			order, err := trader.Sell(marketData)
+
+			require.Nil(t, err)
+			require.Nil(t, order)
+			assert.Equal(t, 1.06, trader.SellThreshold)
+			assert.Equal(t, int64(50), trader.BuyThreshold)
+			assert.Equal(t, 1, len(trader.Bids))
+
+			market.TriggerCancel = false
+		})
+
 		t.Run("sell your coins", func(t *testing.T) {
 			trader.Bids = []*Order{
-				&Order{Price: 0.06, Filled: true},
+				&Order{Price: data.NewDecimal(0.06), Filled: true},
 				lastBid,
-				&Order{Price: 0.03, Filled: false},
+				&Order{Price: data.NewDecimal(0.03), Filled: false},
 			}
 			market.TriggerSellError = false
 			trader.SellThreshold = 1.08
 			trader.BuyThreshold = 42
-			lastBid.Price = 0.04
+			lastBid.Price = data.NewDecimal(0.04)
 
 			order, err := trader.Sell(marketData)
 
@@ -445,8 +691,9 @@ func TestTrader(t *testing.T) {
 			assert.Equal(t, 1.07, trader.SellThreshold)
 			assert.Equal(t, int64(44), trader.BuyThreshold)
 			assert.Equal(t, 2, len(trader.Bids))
-			assert.Equal(t, 0.06, trader.Bids[0].Price)
-			assert.Equal(t, 0.03, trader.Bids[1].Price)
+			assert.Equal(t, data.NewDecimal(0.06), trader.Bids[0].Price)
+			assert.Equal(t, data.NewDecimal(0.03), trader.Bids[1].Price)
+			assert.Equal(t, order, trader.Asks[len(trader.Asks)-1])
 		})
 	})
 }
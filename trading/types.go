@@ -1,11 +1,17 @@
 package trading
 
-import ()
+import (
+	"fmt"
+	"github.com/jbgo/sftbot/data"
+)
 
+// Balances, orders and trades are amounts of money, so they are kept as
+// data.Decimal to add up exactly. Market data such as candles and the ticker
+// are float64.
 type Balance struct {
-	Available float64
-	OnOrders  float64
-	BtcValue  float64
+	Available data.Decimal
+	OnOrders  data.Decimal
+	BtcValue  data.Decimal
 }
 
 type SummaryData struct {
@@ -29,27 +35,96 @@ type TickerEntry struct {
 	QuoteVolume   float64
 }
 
+// How an order may fill, see Order.Execution.
+const ORDER_POST_ONLY = "post_only"
+const ORDER_FILL_OR_KILL = "fill_or_kill"
+const ORDER_IMMEDIATE_OR_CANCEL = "immediate_or_cancel"
+
 type Order struct {
 	Id     string
 	Type   string
-	Price  float64
-	Amount float64
-	Total  float64
+	Price  data.Decimal
+	Amount data.Decimal
+	Total  data.Decimal
 	Filled bool
+	// Empty for a limit order that rests on the book until it fills.
+	// ORDER_POST_ONLY orders are cancelled instead of taking liquidity, so
+	// they only ever pay the maker fee. ORDER_IMMEDIATE_OR_CANCEL orders
+	// cancel whatever does not fill as soon as they reach the book, and
+	// ORDER_FILL_OR_KILL orders are cancelled unless they fill in full then.
+	Execution string
+	// Kept by the trader to undo an order the exchange cancels without a
+	// fill: for an ask, the bid it sells, and the threshold steps taken when
+	// the order was placed.
+	Bid               *Order  `json:",omitempty"`
+	BuyThresholdStep  int64   `json:",omitempty"`
+	SellThresholdStep float64 `json:",omitempty"`
+}
+
+func ValidOrderExecution(execution string) bool {
+	return execution == "" ||
+		execution == ORDER_POST_ONLY ||
+		execution == ORDER_FILL_OR_KILL ||
+		execution == ORDER_IMMEDIATE_OR_CANCEL
+}
+
+// Immediate tells whether the part of the order that does not fill as soon as
+// it reaches the book is cancelled.
+func (order *Order) Immediate() bool {
+	return order.Execution == ORDER_FILL_OR_KILL || order.Execution == ORDER_IMMEDIATE_OR_CANCEL
+}
+
+// OrderCancelledError is returned by Market.Buy and Market.Sell when an order
+// with an Execution is cancelled as soon as it reaches the book, e.g. an
+// immediate-or-cancel order with nothing to fill against. That is how such
+// orders are meant to behave, so the trader logs it and carries on.
+type OrderCancelledError struct {
+	Order  *Order
+	Reason string
+}
+
+func (err *OrderCancelledError) Error() string {
+	return fmt.Sprintf("%s order %s", err.Order.Execution, err.Reason)
+}
+
+// A single fill of an order. Fee is always denominated in BTC, so the BTC
+// cost of a buy is Total + Fee and the BTC proceeds of a sell are Total - Fee.
+type Trade struct {
+	Id       string
+	OrderId  string
+	Market   string
+	Date     int64
+	Type     string
+	Price    data.Decimal
+	Amount   data.Decimal
+	Total    data.Decimal
+	Fee      data.Decimal
+	Exchange string
+	// For storing data specific to a particular exchange
+	Metadata interface{}
 }
 
+// Exchange adapts a trading venue to the trader. Markets are always named by
+// their canonical Symbol; an adapter maps them to its own names.
 type Exchange interface {
 	GetMarket(marketName string) (market Market, err error)
 	GetBalance(currency string) (*Balance, error)
+	// GetTicker returns every market of the exchange by canonical name.
+	GetTicker() (map[string]*TickerEntry, error)
 }
 
 type Market interface {
 	Buy(order *Order) error
 	Exists() bool
+	GetBaseCurrency() string
 	GetCurrency() string
 	GetCurrentPrice() (float64, error)
+	// GetInfo returns the precision, minimum total and fees of orders.
+	GetInfo() (*MarketInfo, error)
 	GetName() string
+	GetOrderTrades(order *Order) ([]*Trade, error)
 	GetPendingOrders() ([]*Order, error)
+	// GetSummaryData returns candles of data.BASE_RESOLUTION seconds.
 	GetSummaryData(startTime, endTime int64) (summaryData []*SummaryData, err error)
 	Sell(order *Order) error
 }
@@ -0,0 +1,261 @@
+package trading
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+/**
+ * WalkForward
+ *
+ * Splits the date range of the optimizer's backtest into rolling windows. The
+ * optimizer picks the best config on each in-sample slice, and that config is
+ * then backtested on the out-of-sample slice that follows it, which it has
+ * never seen. Windows move forward by the out-of-sample length, so the
+ * out-of-sample slices cover the range after the first in-sample slice
+ * exactly once.
+ */
+
+type WalkForward struct {
+	Optimizer *Optimizer
+	// Lengths of the slices in seconds.
+	InSample    int64
+	OutOfSample int64
+}
+
+type WalkForwardWindow struct {
+	InSampleStart    int64
+	InSampleEnd      int64
+	OutOfSampleStart int64
+	OutOfSampleEnd   int64
+	Best             *OptimizeResult
+	OutOfSample      *BacktestResult
+}
+
+// ParameterStability describes how much the value chosen for a parameter
+// moved between windows. A config with real edge should not need very
+// different values from one window to the next.
+type ParameterStability struct {
+	Field  string
+	Mean   float64
+	StdDev float64
+	Min    float64
+	Max    float64
+	// Number of windows whose value differs from the window before.
+	Changes int
+}
+
+type WalkForwardReport struct {
+	Ranges    []*ParameterRange
+	Objective string
+	Windows   []*WalkForwardWindow
+	// The out-of-sample results chained together, each window starting from
+	// the NAV the previous one ended with.
+	OutOfSample *BacktestResult
+	Stability   []*ParameterStability
+	// Average daily out-of-sample return divided by average daily in-sample
+	// return. Close to 1 means the in-sample performance carried over, close
+	// to 0 or negative means it was curve fitting.
+	Efficiency float64
+}
+
+// Windows returns the window dates, without results. Every window has a full
+// in-sample slice; the last out-of-sample slice may be cut short.
+func (wf *WalkForward) Windows() []*WalkForwardWindow {
+	backtest := wf.Optimizer.Backtest
+	windows := make([]*WalkForwardWindow, 0)
+
+	if wf.InSample <= 0 || wf.OutOfSample <= 0 {
+		return windows
+	}
+
+	for start := backtest.StartTime; start+wf.InSample < backtest.EndTime; start += wf.OutOfSample {
+		window := &WalkForwardWindow{
+			InSampleStart:    start,
+			InSampleEnd:      start + wf.InSample - 1,
+			OutOfSampleStart: start + wf.InSample,
+			OutOfSampleEnd:   start + wf.InSample + wf.OutOfSample - 1,
+		}
+
+		if window.OutOfSampleEnd > backtest.EndTime {
+			window.OutOfSampleEnd = backtest.EndTime
+		}
+
+		windows = append(windows, window)
+	}
+
+	return windows
+}
+
+func (wf *WalkForward) Run() (*WalkForwardReport, error) {
+	windows := wf.Windows()
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("date range is shorter than one in-sample and out-of-sample window")
+	}
+
+	report := &WalkForwardReport{
+		Ranges:    wf.Optimizer.Ranges,
+		Objective: wf.Optimizer.Objective,
+		Windows:   windows,
+	}
+
+	for _, window := range windows {
+		inSample := *wf.Optimizer.Backtest
+		inSample.StartTime, inSample.EndTime = window.InSampleStart, window.InSampleEnd
+
+		optimizer := *wf.Optimizer
+		optimizer.Backtest = &inSample
+
+		optimized, err := optimizer.Run()
+		if err != nil {
+			return nil, err
+		}
+
+		window.Best = optimized.Results[0]
+		if window.Best.Err != nil {
+			return nil, fmt.Errorf("in-sample window starting %d: %s", window.InSampleStart, window.Best.Err.Error())
+		}
+
+		outOfSample := *wf.Optimizer.Backtest
+		outOfSample.Config = window.Best.Config
+		outOfSample.StartTime, outOfSample.EndTime = window.OutOfSampleStart, window.OutOfSampleEnd
+
+		window.OutOfSample, err = outOfSample.Run()
+		if err != nil {
+			return nil, fmt.Errorf("out-of-sample window starting %d: %s", window.OutOfSampleStart, err.Error())
+		}
+	}
+
+	report.OutOfSample = wf.stitch(windows)
+	report.Stability = parameterStability(report.Ranges, windows)
+	report.Efficiency = walkForwardEfficiency(windows)
+
+	return report, nil
+}
+
+// stitch chains the out-of-sample equity curves and fills, scaling each
+// window to the NAV the previous window ended with. Every window starts with
+// only the base currency, so a position still open at the end of a window is
+// valued at the last price rather than sold.
+func (wf *WalkForward) stitch(windows []*WalkForwardWindow) *BacktestResult {
+	backtest := wf.Optimizer.Backtest
+	nav := backtest.StartingBalance
+	equity := make([]*EquityPoint, 0)
+	fills := make([]*Trade, 0)
+
+	for _, window := range windows {
+		result := window.OutOfSample
+		scale := nav / result.StartingNAV
+
+		for _, point := range result.Equity {
+			equity = append(equity, &EquityPoint{
+				Date:  point.Date,
+				Price: point.Price,
+				Base:  point.Base * scale,
+				Alt:   point.Alt * scale,
+				NAV:   point.NAV * scale,
+			})
+		}
+
+		for _, fill := range result.Fills {
+			scaled := *fill
+			scaled.Amount = scaled.Amount.MulFloat(scale)
+			scaled.Total = scaled.Total.MulFloat(scale)
+			scaled.Fee = scaled.Fee.MulFloat(scale)
+			fills = append(fills, &scaled)
+		}
+
+		nav = result.EndingNAV * scale
+	}
+
+	return NewBacktestResult(backtest.Market, backtest.Resolution, backtest.StartingBalance, equity, fills)
+}
+
+func parameterStability(ranges []*ParameterRange, windows []*WalkForwardWindow) []*ParameterStability {
+	stability := make([]*ParameterStability, 0, len(ranges))
+	n := float64(len(windows))
+
+	for i, r := range ranges {
+		s := &ParameterStability{Field: r.Field, Min: math.Inf(1), Max: math.Inf(-1)}
+
+		for j, window := range windows {
+			value := window.Best.Params[i]
+			s.Mean += value / n
+			s.Min = math.Min(s.Min, value)
+			s.Max = math.Max(s.Max, value)
+
+			if j > 0 && value != windows[j-1].Best.Params[i] {
+				s.Changes += 1
+			}
+		}
+
+		for _, window := range windows {
+			s.StdDev += math.Pow(window.Best.Params[i]-s.Mean, 2) / n
+		}
+		s.StdDev = math.Sqrt(s.StdDev)
+
+		stability = append(stability, s)
+	}
+
+	return stability
+}
+
+func walkForwardEfficiency(windows []*WalkForwardWindow) float64 {
+	inSample, outOfSample := 0.0, 0.0
+
+	for _, window := range windows {
+		inSample += dailyReturn(window.Best.Result)
+		outOfSample += dailyReturn(window.OutOfSample)
+	}
+
+	if inSample == 0 {
+		return 0.0
+	}
+
+	return outOfSample / inSample
+}
+
+func dailyReturn(result *BacktestResult) float64 {
+	days := float64(result.EndTime-result.StartTime) / (24 * 60 * 60)
+	if days <= 0 {
+		return 0.0
+	}
+
+	return result.TotalReturn / days
+}
+
+func (report *WalkForwardReport) WriteTable(w io.Writer) {
+	fmt.Fprintf(w, "%-10s %-10s %-10s %10s %10s  %s\n", "is start", "oos start", "oos end", "is return", "oos return", "params")
+
+	for _, window := range report.Windows {
+		params := make([]string, 0, len(window.Best.Params))
+		for i, param := range window.Best.Params {
+			params = append(params, fmt.Sprintf("%s=%g", report.Ranges[i].Field, param))
+		}
+
+		fmt.Fprintf(w, "%-10s %-10s %-10s %9.2f%% %9.2f%%  %s\n",
+			formatWindowDate(window.InSampleStart),
+			formatWindowDate(window.OutOfSampleStart),
+			formatWindowDate(window.OutOfSampleEnd),
+			window.Best.Result.TotalReturn*100,
+			window.OutOfSample.TotalReturn*100,
+			strings.Join(params, " "))
+	}
+
+	fmt.Fprintln(w, "\nstitched out-of-sample performance:")
+	report.OutOfSample.WriteTable(w)
+	fmt.Fprintf(w, "%-24s %0.3f\n", "walk-forward efficiency", report.Efficiency)
+
+	fmt.Fprintln(w, "\nparameter stability:")
+	fmt.Fprintf(w, "%-32s %12s %12s %12s %12s %8s\n", "field", "mean", "std dev", "min", "max", "changes")
+	for _, s := range report.Stability {
+		fmt.Fprintf(w, "%-32s %12g %12g %12g %12g %8d\n", s.Field, s.Mean, s.StdDev, s.Min, s.Max, s.Changes)
+	}
+}
+
+func formatWindowDate(date int64) string {
+	return time.Unix(date, 0).UTC().Format("2006-01-02")
+}
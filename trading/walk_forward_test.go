@@ -0,0 +1,94 @@
+package trading
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestWalkForward(t *testing.T) {
+	candles := oscillatingCandles(0, 288*6)
+
+	wf := &WalkForward{
+		Optimizer: &Optimizer{
+			Backtest: &Backtest{
+				Market:          "BTC_ABC",
+				Config:          DefaultTraderConfig(),
+				Candles:         candles,
+				Resolution:      300,
+				StartTime:       86400,
+				EndTime:         candles[len(candles)-1].Date,
+				StartingBalance: 0.1,
+				Fees:            FeeSchedule{Maker: 0.0015, Taker: 0.0025},
+			},
+			Ranges: []*ParameterRange{
+				&ParameterRange{Field: "BuyThresholdStart", Min: 30, Max: 50, Step: 20},
+			},
+			Objective: OBJECTIVE_RETURN,
+			Workers:   2,
+		},
+		InSample:    2 * 86400,
+		OutOfSample: 86400,
+	}
+
+	windows := wf.Windows()
+	require.Equal(t, 3, len(windows))
+	assert.Equal(t, int64(86400), windows[0].InSampleStart)
+	assert.Equal(t, int64(3*86400-1), windows[0].InSampleEnd)
+	assert.Equal(t, int64(3*86400), windows[0].OutOfSampleStart)
+	assert.Equal(t, windows[0].OutOfSampleEnd+1, windows[1].OutOfSampleStart)
+	assert.Equal(t, candles[len(candles)-1].Date, windows[2].OutOfSampleEnd)
+
+	report, err := wf.Run()
+	require.Nil(t, err)
+
+	steps := 0
+	nav := 0.1
+	for _, window := range report.Windows {
+		require.NotNil(t, window.Best)
+		require.NotNil(t, window.OutOfSample)
+		assert.Equal(t, window.OutOfSampleStart, window.OutOfSample.StartTime)
+
+		steps += window.OutOfSample.Steps
+		nav *= 1 + window.OutOfSample.TotalReturn
+	}
+
+	assert.Equal(t, steps, report.OutOfSample.Steps)
+	assert.InDelta(t, nav, report.OutOfSample.EndingNAV, 1e-12)
+	assert.Equal(t, int64(3*86400), report.OutOfSample.StartTime)
+
+	require.Equal(t, 1, len(report.Stability))
+	stability := report.Stability[0]
+	assert.Equal(t, "BuyThresholdStart", stability.Field)
+	assert.True(t, stability.Min >= 30 && stability.Max <= 50)
+	assert.True(t, stability.Mean >= stability.Min && stability.Mean <= stability.Max)
+
+	var buffer bytes.Buffer
+	report.WriteTable(&buffer)
+	assert.Contains(t, buffer.String(), "walk-forward efficiency")
+
+	t.Run("range too short", func(t *testing.T) {
+		short := *wf
+		short.InSample = 10 * 86400
+
+		_, err := short.Run()
+		assert.NotNil(t, err)
+	})
+}
+
+func TestParameterStability(t *testing.T) {
+	windows := []*WalkForwardWindow{
+		&WalkForwardWindow{Best: &OptimizeResult{Params: []float64{1.0, 10}}},
+		&WalkForwardWindow{Best: &OptimizeResult{Params: []float64{3.0, 10}}},
+		&WalkForwardWindow{Best: &OptimizeResult{Params: []float64{3.0, 10}}},
+		&WalkForwardWindow{Best: &OptimizeResult{Params: []float64{1.0, 10}}},
+	}
+
+	ranges := []*ParameterRange{&ParameterRange{Field: "A"}, &ParameterRange{Field: "B"}}
+
+	stability := parameterStability(ranges, windows)
+
+	assert.Equal(t, &ParameterStability{Field: "A", Mean: 2.0, StdDev: 1.0, Min: 1.0, Max: 3.0, Changes: 2}, stability[0])
+	assert.Equal(t, &ParameterStability{Field: "B", Mean: 10.0, StdDev: 0.0, Min: 10.0, Max: 10.0, Changes: 0}, stability[1])
+}